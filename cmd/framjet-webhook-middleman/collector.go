@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/tracing"
+	"github.com/urfave/cli/v3"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+func traceCollectorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "trace-collector",
+		Usage: "Run a local OTLP/HTTP collector that prints received spans, for trying out tracing",
+		Description: `Accepts spans on /v1/traces like an OpenTelemetry collector and prints each one as a JSON line.
+Start the server with --otlp-endpoint pointing at it, for example http://127.0.0.1:4318.`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "addr",
+				Value: "127.0.0.1:4318",
+				Usage: "Address to listen on",
+			},
+		},
+		Action: runTraceCollector,
+	}
+}
+
+func runTraceCollector(ctx context.Context, c *cli.Command) error {
+	var mu sync.Mutex
+	enc := json.NewEncoder(os.Stdout)
+	collector := tracing.NewCollector(func(span tracing.Span) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(span)
+	})
+
+	httpServer := &http.Server{Addr: c.String("addr"), Handler: collector}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		httpServer.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "Collecting spans on http://%s/v1/traces\n", httpServer.Addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
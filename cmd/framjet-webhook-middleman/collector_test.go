@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestTraceCollectorFailsOnBusyAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if _, err := runApp(t, "trace-collector", "--addr", listener.Addr().String()); err == nil {
+		t.Error("collector started on a busy address")
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/deadletter"
+	"github.com/framjet/go-webhook-middleman/internal/redact"
+	"github.com/framjet/go-webhook-middleman/internal/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v3"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func deadLetterCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "deadletter",
+		Aliases: []string{"dlq"},
+		Usage:   "Inspect, replay and purge dead-lettered deliveries",
+		Commands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List dead-lettered deliveries",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print as JSON",
+					},
+				},
+				Action: listDeadLetters,
+			},
+			{
+				Name:      "show",
+				Usage:     "Show a dead-lettered delivery including its body",
+				ArgsUsage: "<id>",
+				Action:    showDeadLetter,
+			},
+			{
+				Name:      "replay",
+				Usage:     "Forward dead-lettered deliveries again, successful ones are removed",
+				ArgsUsage: "<id>...",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Replay every dead-lettered delivery",
+					},
+				},
+				Action: replayDeadLetters,
+			},
+			{
+				Name:      "purge",
+				Usage:     "Delete dead-lettered deliveries",
+				ArgsUsage: "<id>...",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Delete every dead-lettered delivery",
+					},
+				},
+				Action: purgeDeadLetters,
+			},
+		},
+	}
+}
+
+func openDeadLetters(c *cli.Command) (*deadletter.Store, error) {
+	return deadletter.Open(server.DeadLetterDir(c.String("data-dir")))
+}
+
+// deadLetterSecrets returns the secret values of the config, which are
+// redacted from the dead letters shown
+func deadLetterSecrets(c *cli.Command) (*redact.Secrets, error) {
+	cfg, err := config.LoadConfig(c.String("config"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config to redact secrets: %w", err)
+	}
+
+	secrets := &redact.Secrets{}
+	secrets.Set(cfg.Secrets())
+	return secrets, nil
+}
+
+// deadLetterIDs returns the IDs given as arguments, or all stored IDs with --all
+func deadLetterIDs(c *cli.Command, store *deadletter.Store) ([]string, error) {
+	if !c.Bool("all") {
+		if c.NArg() == 0 {
+			return nil, fmt.Errorf("no dead letter IDs given, pass IDs or --all")
+		}
+		return c.Args().Slice(), nil
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+
+	return ids, nil
+}
+
+func listDeadLetters(_ context.Context, c *cli.Command) error {
+	store, err := openDeadLetters(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := deadLetterSecrets(c)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		entries[i] = e.Redacted(secrets)
+	}
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tFAILED AT\tDESTINATION\tMETHOD\tURL\tATTEMPTS\tSTATUS\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			e.ID,
+			e.FailedAt.Format(time.RFC3339),
+			e.Destination.Name,
+			e.Destination.Method,
+			e.Destination.URL,
+			e.Attempts+e.Replays,
+			e.LastStatus,
+			e.LastError)
+	}
+
+	return tw.Flush()
+}
+
+func showDeadLetter(_ context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected exactly one dead letter ID")
+	}
+
+	store, err := openDeadLetters(c)
+	if err != nil {
+		return err
+	}
+
+	entry, err := store.Get(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	secrets, err := deadLetterSecrets(c)
+	if err != nil {
+		return err
+	}
+	entry = entry.Redacted(secrets)
+
+	// Show the body as text rather than base64 when printing for humans
+	view := struct {
+		*deadletter.Entry
+		Body string `json:"body"`
+	}{entry, string(entry.Destination.Body)}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(view)
+}
+
+func replayDeadLetters(ctx context.Context, c *cli.Command) error {
+	logger := setupLogger(c.String("log-level"), c.Bool("json-log"))
+
+	srv, err := server.NewWebhookServer(server.Options{
+		ConfigPath: c.String("config"),
+		Timeout:    c.Duration("timeout"),
+		DataDir:    c.String("data-dir"),
+		Registry:   prometheus.NewRegistry(),
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	ids, err := deadLetterIDs(c, srv.DeadLetters())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, id := range ids {
+		result, err := srv.ReplayDeadLetter(ctx, id)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("%s\terror\t%v\n", id, err)
+		case result.Success:
+			fmt.Printf("%s\treplayed\t%d\n", id, result.StatusCode)
+		default:
+			failed++
+			fmt.Printf("%s\tfailed\t%d %s\n", id, result.StatusCode, result.Error)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d replays failed", failed, len(ids))
+	}
+
+	return nil
+}
+
+func purgeDeadLetters(_ context.Context, c *cli.Command) error {
+	store, err := openDeadLetters(c)
+	if err != nil {
+		return err
+	}
+
+	ids, err := deadLetterIDs(c, store)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := store.Get(id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		if err := store.Remove(id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+	}
+
+	fmt.Printf("Purged %d dead letters\n", len(ids))
+
+	return nil
+}
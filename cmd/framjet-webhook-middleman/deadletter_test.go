@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/deadletter"
+	"github.com/framjet/go-webhook-middleman/internal/server"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func putDeadLetters(t *testing.T, dataDir string, entries ...*deadletter.Entry) *deadletter.Store {
+	t.Helper()
+
+	store, err := deadletter.Open(server.DeadLetterDir(dataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := store.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+// writeDeadLetterConfig writes a config forwarding to dest and returns its path
+func writeDeadLetterConfig(t *testing.T, dir, dest string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, `
+variables:
+  token:
+    secret_env: WEBHOOK_DLQ_TOKEN
+destinations:
+  echo: "`+dest+`"
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`)
+	return path
+}
+
+func TestDeadLetterListAndPurge(t *testing.T) {
+	t.Setenv("WEBHOOK_DLQ_TOKEN", "s3cret-token")
+	dataDir := t.TempDir()
+	configPath := writeDeadLetterConfig(t, dataDir, "http://localhost")
+	store := putDeadLetters(t, dataDir,
+		&deadletter.Entry{ID: "a", FailedAt: time.Now(), LastStatus: 500},
+		&deadletter.Entry{ID: "b", FailedAt: time.Now().Add(-time.Minute)})
+
+	out, err := runApp(t, "--config", configPath, "--data-dir", dataDir, "deadletter", "list", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []deadletter.Entry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("list output %q: %v", out, err)
+	}
+	if len(entries) != 2 || entries[0].ID != "a" {
+		t.Fatalf("listed %+v", entries)
+	}
+
+	if _, err := runApp(t, "--data-dir", dataDir, "deadletter", "purge"); err == nil {
+		t.Error("purge without IDs or --all succeeded")
+	}
+	if _, err := runApp(t, "--data-dir", dataDir, "deadletter", "purge", "missing"); err == nil {
+		t.Error("purge of an unknown ID succeeded")
+	}
+	if _, err := runApp(t, "--data-dir", dataDir, "deadletter", "purge", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := store.List(); len(left) != 1 || left[0].ID != "b" {
+		t.Errorf("left after purging a: %+v", left)
+	}
+	if _, err := runApp(t, "--data-dir", dataDir, "deadletter", "purge", "--all"); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := store.List(); len(left) != 0 {
+		t.Errorf("left after purging all: %+v", left)
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	var received []string
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path)
+	}))
+	defer dest.Close()
+
+	t.Setenv("WEBHOOK_DLQ_TOKEN", "s3cret-token")
+	dir := t.TempDir()
+	configPath := writeDeadLetterConfig(t, dir, dest.URL)
+
+	dataDir := filepath.Join(dir, "data")
+	store := putDeadLetters(t, dataDir, &deadletter.Entry{
+		ID:          "a",
+		Destination: config.ResolvedDestination{Name: "echo", URL: dest.URL + "/a", Method: "POST"},
+		FailedAt:    time.Now(),
+	})
+
+	out, err := runApp(t, "--config", configPath, "--data-dir", dataDir, "deadletter", "replay", "a")
+	if err != nil {
+		t.Fatalf("replay: %v, output %s", err, out)
+	}
+	if !strings.Contains(out, "a\treplayed\t200") {
+		t.Errorf("output %q", out)
+	}
+	if len(received) != 1 || received[0] != "/a" {
+		t.Errorf("destination received %v", received)
+	}
+	if left, _ := store.List(); len(left) != 0 {
+		t.Errorf("replayed entry kept: %+v", left)
+	}
+}
+
+func TestDeadLetterListAndShowRedactSecrets(t *testing.T) {
+	t.Setenv("WEBHOOK_DLQ_TOKEN", "s3cret-token")
+	dataDir := t.TempDir()
+	configPath := writeDeadLetterConfig(t, dataDir, "http://localhost")
+	putDeadLetters(t, dataDir, &deadletter.Entry{
+		ID: "a",
+		Destination: config.ResolvedDestination{
+			Name:    "echo",
+			URL:     "http://localhost/hook?token=s3cret-token",
+			Method:  "POST",
+			Headers: map[string]string{"Authorization": "Bearer literal-token", "X-Token": "s3cret-token"},
+			Body:    []byte(`{"token": "s3cret-token"}`),
+		},
+		LastError: "request failed: Post \"http://localhost/hook?token=s3cret-token\"",
+		FailedAt:  time.Now(),
+	})
+
+	for _, args := range [][]string{{"list"}, {"list", "--json"}, {"show", "a"}} {
+		out, err := runApp(t, append([]string{"--config", configPath, "--data-dir", dataDir, "deadletter"}, args...)...)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if strings.Contains(out, "s3cret-token") || strings.Contains(out, "literal-token") {
+			t.Errorf("%v shows a secret: %s", args, out)
+		}
+		if !strings.Contains(out, "token=[REDACTED]") {
+			t.Errorf("%v output %s", args, out)
+		}
+	}
+
+	if _, err := runApp(t, "--config", filepath.Join(dataDir, "missing.yaml"), "--data-dir", dataDir, "deadletter", "list"); err == nil {
+		t.Error("listed without a config to redact secrets with")
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/framjet/go-webhook-middleman/internal/cliutil"
+	"github.com/framjet/go-webhook-middleman/internal/config"
 	"github.com/framjet/go-webhook-middleman/internal/server"
+	"github.com/framjet/go-webhook-middleman/internal/tlsconfig"
+	"github.com/framjet/go-webhook-middleman/internal/tracing"
 	"github.com/urfave/cli/v3"
+	"io"
 	"log"
 	"log/slog"
 	"net/http"
@@ -25,6 +29,10 @@ var (
 )
 
 func setupLogger(level string, jsonFormat bool) *slog.Logger {
+	return newLogger(os.Stdout, level, jsonFormat)
+}
+
+func newLogger(w io.Writer, level string, jsonFormat bool) *slog.Logger {
 	var logLevel slog.Level
 	switch strings.ToLower(level) {
 	case "debug":
@@ -45,9 +53,9 @@ func setupLogger(level string, jsonFormat bool) *slog.Logger {
 
 	var handler slog.Handler
 	if jsonFormat {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(w, opts)
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(w, opts)
 	}
 
 	return slog.New(handler)
@@ -60,16 +68,52 @@ func runServer(ctx context.Context, c *cli.Command) error {
 	logLevel := c.String("log-level")
 	jsonFormat := c.Bool("json-log")
 	timeout := c.Duration("timeout")
+	watchInterval := c.Duration("watch-interval")
+	dataDir := c.String("data-dir")
+	asyncWorkers := c.Int("async-workers")
+	asyncQueueSize := c.Int("async-queue-size")
+	adminToken := c.String("admin-token")
+	adminAddr := c.String("admin-addr")
+	shutdownDelay := c.Duration("shutdown-delay")
+	tlsCert := c.String("tls-cert")
+	tlsKey := c.String("tls-key")
+	tlsClientCA := c.String("tls-client-ca")
 
 	logger := setupLogger(logLevel, jsonFormat)
 
-	// Validate config file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		logger.Error("Configuration file not found", "path", configPath)
+	// Export spans when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Endpoint:       c.String("otlp-endpoint"),
+		ServiceName:    "framjet-webhook-middleman",
+		ServiceVersion: Version,
+		SampleRatio:    c.Float("trace-sample-ratio"),
+	}, logger)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		return err
+	}
+
+	// Validate config files exist
+	if _, err := config.ConfigFiles(configPath); err != nil {
+		logger.Error("Configuration file not found", "path", configPath, "error", err)
 		return fmt.Errorf("configuration file not found: %s", configPath)
 	}
 
-	srv, err := server.NewWebhookServer(configPath, timeout, logger)
+	srv, err := server.NewWebhookServer(server.Options{
+		ConfigPath: configPath,
+		Timeout:    timeout,
+		DataDir:    dataDir,
+		AdminToken: adminToken,
+
+		SeparateAdmin: adminAddr != "",
+
+		AsyncWorkers:   asyncWorkers,
+		AsyncQueueSize: asyncQueueSize,
+
+		TrustedProxies: c.StringSlice("trusted-proxies"),
+
+		RequestIDHeader: c.String("request-id-header"),
+	}, logger)
 	if err != nil {
 		logger.Error("Failed to create webhook srv", "error", err)
 		return err
@@ -84,10 +128,39 @@ func runServer(ctx context.Context, c *cli.Command) error {
 		Addr:         addr,
 		Handler:      router,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: timeout + 10*time.Second, // Allow extra time for forwarding
+		WriteTimeout: timeout + 10*time.Second, // Allow extra time for forwarding on sync routes
 		IdleTimeout:  120 * time.Second,
 	}
 
+	// Serve TLS when a certificate is configured, reloading it when the files change
+	var certs *tlsconfig.Reloader
+	if tlsCert != "" || tlsKey != "" {
+		certs, err = tlsconfig.NewReloader(tlsconfig.Options{
+			CertFile:     tlsCert,
+			KeyFile:      tlsKey,
+			ClientCAFile: tlsClientCA,
+			ClientAuth:   c.String("tls-client-auth"),
+		}, logger)
+		if err != nil {
+			logger.Error("Failed to set up TLS", "error", err)
+			return err
+		}
+		httpServer.TLSConfig = certs.TLSConfig()
+	}
+
+	// Serve health, metrics, profiling and admin endpoints on their own
+	// listener, keeping them off the public port
+	var adminServer *http.Server
+	if adminAddr != "" {
+		adminServer = &http.Server{
+			Addr:         adminAddr,
+			Handler:      srv.AdminHandler(),
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: timeout + 10*time.Second, // Test fires wait for the destination
+			IdleTimeout:  120 * time.Second,
+		}
+	}
+
 	// Start srv in goroutine
 	serverErr := make(chan error, 1)
 	go func() {
@@ -95,28 +168,89 @@ func runServer(ctx context.Context, c *cli.Command) error {
 			"host", host,
 			"port", port,
 			"config", configPath,
-			"destinations", len(srv.Config.Destinations),
-			"routes", len(srv.Config.Routes),
+			"destinations", len(srv.Config().Destinations),
+			"routes", len(srv.Config().Routes),
 			"timeout", timeout,
+			"watch_interval", watchInterval,
+			"data_dir", dataDir,
+			"tls", certs != nil,
+			"tls_client_ca", tlsClientCA,
+			"otlp_endpoint", c.String("otlp-endpoint"),
 			"json_log", jsonFormat)
 
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if certs != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			serverErr <- err
 		}
 	}()
 
+	if adminServer != nil {
+		go func() {
+			logger.Info("Starting admin server", "addr", adminAddr, "admin_api", adminToken != "", "pprof", true)
+
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+
+	// Watch the config file for changes
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+	go srv.WatchConfig(bgCtx, watchInterval)
+
+	// Pick up rotated certificates
+	if certs != nil {
+		go certs.Watch(bgCtx, watchInterval)
+	}
+
+	// Process queued retries in the background
+	go srv.RunRetryWorker(bgCtx, time.Second)
+
+	// Probe destinations that readiness depends on
+	go srv.RunProbes(bgCtx)
+
+	// Reload config on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	// Setup graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	select {
-	case <-quit:
-		logger.Info("Shutting down srv...")
-	case err := <-serverErr:
-		logger.Error("Server error", "error", err)
-		return err
+wait:
+	for {
+		select {
+		case <-hup:
+			_ = srv.Reload("signal")
+			if certs != nil {
+				if err := certs.Reload(); err != nil {
+					logger.Error("TLS certificate reload failed, keeping previous certificate", "error", err)
+				}
+			}
+		case <-quit:
+			logger.Info("Shutting down srv...")
+			srv.Drain()
+			if shutdownDelay > 0 {
+				// Keep serving until load balancers notice the failing readiness
+				logger.Info("Waiting before shutdown", "delay", shutdownDelay)
+				time.Sleep(shutdownDelay)
+			}
+			break wait
+		case err := <-serverErr:
+			logger.Error("Server error", "error", err)
+			return err
+		}
 	}
 
+	stopBackground()
+
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -126,14 +260,33 @@ func runServer(ctx context.Context, c *cli.Command) error {
 		return err
 	}
 
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Error("Admin server forced to shutdown", "error", err)
+			return err
+		}
+	}
+
+	// Finish async deliveries accepted before shutdown
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Async deliveries not drained", "error", err)
+		return err
+	}
+
+	// Export the spans still buffered
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Warn("Failed to flush spans", "error", err)
+	}
+
 	logger.Info("Server stopped")
 	return nil
 }
 
-func main() {
+// newApp builds the command line with the server as its default action
+func newApp() *cli.Command {
 	bInfo := cliutil.GetBuildInfo(BuildType, Version)
 
-	cmd := &cli.Command{
+	return &cli.Command{
 		Name:      "FramJet WebHook Router Middleman Server",
 		Usage:     "A simple HTTP server that routes webhook calls to multiple destinations based on YAML configuration.",
 		UsageText: "framjet-webhook-middleman [global options] [command options]",
@@ -163,7 +316,7 @@ func main() {
 				Name:    "config",
 				Aliases: []string{"c"},
 				Value:   "config.yaml",
-				Usage:   "Path to configuration file",
+				Usage:   "Path to configuration file, or a directory or glob of configuration files",
 				Sources: cli.EnvVars("CONFIG_FILE"),
 			},
 			&cli.StringFlag{
@@ -187,6 +340,89 @@ func main() {
 				Usage:   "HTTP client timeout",
 				Sources: cli.EnvVars("HTTP_TIMEOUT"),
 			},
+			&cli.DurationFlag{
+				Name:    "watch-interval",
+				Aliases: []string{"w"},
+				Value:   5 * time.Second,
+				Usage:   "How often to check the configuration file for changes (0 disables watching, SIGHUP still reloads)",
+				Sources: cli.EnvVars("CONFIG_WATCH_INTERVAL"),
+			},
+			&cli.StringFlag{
+				Name:    "data-dir",
+				Aliases: []string{"d"},
+				Value:   "data",
+				Usage:   "Directory for persistent state such as the retry queue",
+				Sources: cli.EnvVars("DATA_DIR"),
+			},
+			&cli.IntFlag{
+				Name:    "async-workers",
+				Value:   16,
+				Usage:   "Number of background workers forwarding webhooks for async routes, also the number of queued retries sent at once",
+				Sources: cli.EnvVars("ASYNC_WORKERS"),
+			},
+			&cli.IntFlag{
+				Name:    "async-queue-size",
+				Value:   1000,
+				Usage:   "Maximum number of accepted async webhooks waiting for a worker",
+				Sources: cli.EnvVars("ASYNC_QUEUE_SIZE"),
+			},
+			&cli.StringFlag{
+				Name:    "admin-token",
+				Usage:   "Bearer token for the /admin endpoints, they are disabled when empty",
+				Sources: cli.EnvVars("ADMIN_TOKEN"),
+			},
+			&cli.StringFlag{
+				Name:    "admin-addr",
+				Usage:   "Internal address such as 127.0.0.1:9090 serving health, readiness, metrics, pprof and /admin instead of the webhook port",
+				Sources: cli.EnvVars("ADMIN_ADDR"),
+			},
+			&cli.DurationFlag{
+				Name:    "shutdown-delay",
+				Usage:   "Time to keep serving after a shutdown signal while /readyz reports not ready",
+				Sources: cli.EnvVars("SHUTDOWN_DELAY"),
+			},
+			&cli.StringSliceFlag{
+				Name:    "trusted-proxies",
+				Usage:   "Proxy addresses or CIDR ranges whose X-Forwarded-For and X-Forwarded-Proto headers are trusted",
+				Sources: cli.EnvVars("TRUSTED_PROXIES"),
+			},
+			&cli.StringFlag{
+				Name:    "request-id-header",
+				Value:   server.DefaultRequestIDHeader,
+				Usage:   "Header taking the request ID from callers, returning it in responses and passing it to destinations",
+				Sources: cli.EnvVars("REQUEST_ID_HEADER"),
+			},
+			&cli.StringFlag{
+				Name:    "tls-cert",
+				Usage:   "TLS certificate file, serves HTTPS when set together with --tls-key",
+				Sources: cli.EnvVars("TLS_CERT_FILE"),
+			},
+			&cli.StringFlag{
+				Name:    "tls-key",
+				Usage:   "TLS private key file",
+				Sources: cli.EnvVars("TLS_KEY_FILE"),
+			},
+			&cli.StringFlag{
+				Name:    "tls-client-ca",
+				Usage:   "CA bundle to verify client certificates against, enables mTLS",
+				Sources: cli.EnvVars("TLS_CLIENT_CA_FILE"),
+			},
+			&cli.StringFlag{
+				Name:    "tls-client-auth",
+				Usage:   "Client certificate policy: none, verify-if-given or require (default: require when --tls-client-ca is set)",
+				Sources: cli.EnvVars("TLS_CLIENT_AUTH"),
+			},
+			&cli.StringFlag{
+				Name:    "otlp-endpoint",
+				Usage:   "OTLP/HTTP endpoint such as http://localhost:4318 to export trace spans to, tracing is disabled when empty",
+				Sources: cli.EnvVars("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			},
+			&cli.FloatFlag{
+				Name:    "trace-sample-ratio",
+				Value:   1,
+				Usage:   "Share of traces to record that callers didn't already sample, between 0 and 1",
+				Sources: cli.EnvVars("TRACE_SAMPLE_RATIO"),
+			},
 		},
 		Action: runServer,
 		Commands: []*cli.Command{
@@ -198,6 +434,11 @@ func main() {
 					return nil
 				},
 			},
+			deadLetterCommand(),
+			validateCommand(),
+			testCommand(),
+			testSuiteCommand(),
+			traceCollectorCommand(),
 		},
 		Description: `Webhook Middleman Server routes single webhook calls to multiple destinations based on YAML configuration.
 
@@ -242,8 +483,10 @@ Matching rules:
   - Regex: "/api-.+/"
   - Arrays: ["frontend", "backend", "/api-.+/"]]`,
 	}
+}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+func main() {
+	if err := newApp().Run(context.Background(), os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
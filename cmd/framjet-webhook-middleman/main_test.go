@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"github.com/urfave/cli/v3"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runApp runs the command line with args and returns what it printed. Exit
+// codes are returned as errors rather than exiting the test binary.
+func runApp(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	app := newApp()
+	app.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+	err = app.Run(context.Background(), append([]string{"framjet-webhook-middleman"}, args...))
+	os.Stdout = stdout
+
+	printed, readErr := os.ReadFile(out.Name())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	return string(printed), err
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
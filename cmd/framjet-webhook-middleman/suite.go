@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/httpclient"
+	"github.com/framjet/go-webhook-middleman/internal/server"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Exit codes of the test-suite command
+const (
+	suiteExitFailed     = 1 // At least one test case failed
+	suiteExitUnreadable = 2 // The suite or the config couldn't be loaded
+)
+
+func testSuiteCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "test-suite",
+		Usage:     "Run the test cases of a suite file against the configuration",
+		ArgsUsage: "<suite.yaml>",
+		Description: `Sends each test case's request through the configuration like the test command does, with
+outbound requests stubbed, and compares the route, destinations, rendered bodies and response
+status with what the case expects. Differences are reported per case.
+
+Cases run in order against one server, so rate limits, deduplication and circuit breakers carry
+over from one case to the next like they would for real requests.
+
+Exit codes: 0 when every case passes, 1 when a case fails, 2 when the suite or the config can't
+be loaded.`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "run",
+				Usage: "Only run the cases whose name matches the regular expression",
+			},
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "Print the full dry-run result of failed cases",
+			},
+		},
+		Action: runTestSuite,
+	}
+}
+
+// testSuite is a file of test cases for a configuration
+type testSuite struct {
+	Config string     `yaml:"config"` // Relative to the suite file, --config when empty
+	Tests  []testCase `yaml:"tests"`
+}
+
+type testCase struct {
+	Name      string                   `yaml:"name"`
+	Request   suiteRequest             `yaml:"request"`
+	Responses map[string]suiteResponse `yaml:"responses"` // Stubbed answers by destination name
+	Expect    suiteExpectation         `yaml:"expect"`
+}
+
+type suiteRequest struct {
+	Method   string            `yaml:"method"`
+	Path     string            `yaml:"path"`
+	Headers  map[string]string `yaml:"headers"`
+	Body     string            `yaml:"body"`
+	BodyFile string            `yaml:"body_file"` // Relative to the suite file
+}
+
+type suiteResponse struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+// suiteExpectation lists what to check, fields that are left out aren't
+type suiteExpectation struct {
+	Route        *int                   `yaml:"route"` // -1 for no route
+	Status       int                    `yaml:"status"`
+	Body         *string                `yaml:"body"`
+	Destinations *[]expectedDestination `yaml:"destinations"` // All destinations the request resolves to
+}
+
+type expectedDestination struct {
+	Name    string            `yaml:"name"` // "inline" for inline destinations
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"` // Only the listed headers are compared
+	Body    *string           `yaml:"body"`
+}
+
+func runTestSuite(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("expected exactly one suite file", suiteExitUnreadable)
+	}
+	suitePath := c.Args().First()
+
+	suite, err := loadTestSuite(suitePath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to load test suite: %v", err), suiteExitUnreadable)
+	}
+
+	var filter *regexp.Regexp
+	if c.IsSet("run") {
+		if filter, err = regexp.Compile(c.String("run")); err != nil {
+			return cli.Exit(fmt.Sprintf("invalid --run pattern: %v", err), suiteExitUnreadable)
+		}
+	}
+
+	configPath := c.String("config")
+	if suite.Config != "" {
+		configPath = filepath.Join(filepath.Dir(suitePath), suite.Config)
+	}
+
+	srv, stub, cleanup, err := newDryRunServer(configPath, c.Duration("timeout"), dryRunLogger(c))
+	if err != nil {
+		return cli.Exit(err.Error(), suiteExitUnreadable)
+	}
+	defer cleanup()
+
+	passed, failed := 0, 0
+	for i, tc := range suite.Tests {
+		name := tc.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if filter != nil && !filter.MatchString(name) {
+			continue
+		}
+
+		result, failures, err := runTestCase(ctx, srv, stub, filepath.Dir(suitePath), tc)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+
+		if len(failures) == 0 {
+			passed++
+			fmt.Printf("PASS  %s\n", name)
+			continue
+		}
+
+		failed++
+		fmt.Printf("FAIL  %s\n", name)
+		for _, failure := range failures {
+			fmt.Println(indentLines(failure, "      "))
+		}
+		if c.Bool("verbose") && result != nil {
+			var out bytes.Buffer
+			printTestResult(&out, result)
+			fmt.Println(indentLines(strings.TrimRight(out.String(), "\n"), "      "))
+		}
+	}
+
+	fmt.Printf("\n%s: %d passed, %d failed\n", suitePath, passed, failed)
+
+	if failed > 0 {
+		return cli.Exit("", suiteExitFailed)
+	}
+
+	return nil
+}
+
+func loadTestSuite(path string) (*testSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite testSuite
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&suite); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	for i, tc := range suite.Tests {
+		if tc.Request.Body != "" && tc.Request.BodyFile != "" {
+			return nil, fmt.Errorf("test %d sets both body and body_file", i)
+		}
+	}
+
+	return &suite, nil
+}
+
+// runTestCase dry-runs the case's request and returns what differs from its
+// expectations
+func runTestCase(ctx context.Context, srv *server.WebhookServer, stub *httpclient.Stub, dir string, tc testCase) (*testResult, []string, error) {
+	body := tc.Request.Body
+	if tc.Request.BodyFile != "" {
+		data, err := os.ReadFile(filepath.Join(dir, tc.Request.BodyFile))
+		if err != nil {
+			return nil, nil, err
+		}
+		body = string(data)
+	}
+
+	method := tc.Request.Method
+	if method == "" {
+		method = "POST"
+	}
+
+	var headers []string
+	for name, value := range tc.Request.Headers {
+		headers = append(headers, name+": "+value)
+	}
+
+	request, err := buildTestRequest(method, tc.Request.Path, headers, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	explanation := &server.Explanation{}
+	stub.Respond = func(r *http.Request) httpclient.StubResponse {
+		name, _ := explanation.DestinationName(r.Method, r.URL.String())
+		response, ok := tc.Responses[name]
+		if !ok {
+			return httpclient.StubResponse{Status: http.StatusOK}
+		}
+
+		stubbed := httpclient.StubResponse{Status: response.Status, Headers: make(http.Header), Body: response.Body}
+		for name, value := range response.Headers {
+			stubbed.Headers.Set(name, value)
+		}
+		return stubbed
+	}
+
+	result, err := dryRun(ctx, srv, stub, request, explanation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, checkExpectation(tc.Expect, result), nil
+}
+
+func checkExpectation(expect suiteExpectation, result *testResult) []string {
+	var failures []string
+	e := result.Explanation
+
+	if expect.Route != nil && *expect.Route != e.RouteIndex {
+		failures = append(failures, fmt.Sprintf("route: expected %s, got %s", routeName(*expect.Route), routeName(e.RouteIndex)))
+	}
+
+	if expect.Status != 0 && expect.Status != result.Response.Status {
+		failures = append(failures, fmt.Sprintf("status: expected %d, got %d", expect.Status, result.Response.Status))
+	}
+
+	if expect.Body != nil {
+		if diff := diffBodies(*expect.Body, result.Response.Body); diff != "" {
+			failures = append(failures, "response body:\n"+diff)
+		}
+	}
+
+	if expect.Destinations != nil {
+		failures = append(failures, checkDestinations(*expect.Destinations, e)...)
+	}
+
+	return failures
+}
+
+func checkDestinations(expected []expectedDestination, e *server.Explanation) []string {
+	var failures []string
+
+	for _, err := range e.Errors {
+		failures = append(failures, "destination error: "+err)
+	}
+
+	expectedNames := make([]string, len(expected))
+	for i, dest := range expected {
+		expectedNames[i] = dest.Name
+	}
+	actualNames := make([]string, len(e.Destinations))
+	for i, dest := range e.Destinations {
+		actualNames[i] = dest.Name
+	}
+	if !slices.Equal(slices.Sorted(slices.Values(expectedNames)), slices.Sorted(slices.Values(actualNames))) {
+		failures = append(failures, fmt.Sprintf("destinations: expected [%s], got [%s]", strings.Join(expectedNames, ", "), strings.Join(actualNames, ", ")))
+	}
+
+	// Destinations are paired up in order among those with the same name
+	used := make([]bool, len(e.Destinations))
+	for _, want := range expected {
+		index := -1
+		for i, dest := range e.Destinations {
+			if !used[i] && dest.Name == want.Name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		used[index] = true
+		got := e.Destinations[index]
+
+		if want.URL != "" && want.URL != got.URL {
+			failures = append(failures, fmt.Sprintf("destination %s url: expected %s, got %s", want.Name, want.URL, got.URL))
+		}
+		if want.Method != "" && !strings.EqualFold(want.Method, got.Method) {
+			failures = append(failures, fmt.Sprintf("destination %s method: expected %s, got %s", want.Name, strings.ToUpper(want.Method), got.Method))
+		}
+		for _, header := range slices.Sorted(maps.Keys(want.Headers)) {
+			value, ok := lookupHeader(got.Headers, header)
+			switch {
+			case !ok:
+				failures = append(failures, fmt.Sprintf("destination %s header %s: expected %q, not set", want.Name, header, want.Headers[header]))
+			case value != want.Headers[header]:
+				failures = append(failures, fmt.Sprintf("destination %s header %s: expected %q, got %q", want.Name, header, want.Headers[header], value))
+			}
+		}
+		if want.Body != nil {
+			if diff := diffBodies(*want.Body, string(got.Body)); diff != "" {
+				failures = append(failures, fmt.Sprintf("destination %s body:\n%s", want.Name, diff))
+			}
+		}
+	}
+
+	return failures
+}
+
+// indentLines prefixes every non-empty line
+func indentLines(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func routeName(index int) string {
+	if index < 0 {
+		return "no route"
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// diffBodies returns a line diff of the bodies, or "" when they're equal.
+// JSON bodies are compared by value, so formatting and key order don't matter.
+func diffBodies(expected, actual string) string {
+	expected = normalizeBody(expected)
+	actual = normalizeBody(actual)
+	if expected == actual {
+		return ""
+	}
+
+	return strings.Join(lineDiff(strings.Split(expected, "\n"), strings.Split(actual, "\n")), "\n")
+}
+
+// normalizeBody indents JSON consistently and drops trailing whitespace,
+// which YAML block scalars tend to add
+func normalizeBody(body string) string {
+	body = strings.TrimRight(body, " \t\r\n")
+
+	var value any
+	if err := json.Unmarshal([]byte(body), &value); err == nil {
+		if indented, err := json.MarshalIndent(value, "", "  "); err == nil {
+			return string(indented)
+		}
+	}
+
+	return body
+}
+
+// lineDiff marks lines only in expected with "-" and lines only in actual
+// with "+", based on their longest common subsequence
+func lineDiff(expected, actual []string) []string {
+	// common[i][j] is the length of the common subsequence of expected[i:] and actual[j:]
+	common := make([][]int, len(expected)+1)
+	for i := range common {
+		common[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, "  "+expected[i])
+			i++
+			j++
+		case i < len(expected) && (j == len(actual) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "- "+expected[i])
+			i++
+		default:
+			lines = append(lines, "+ "+actual[j])
+			j++
+		}
+	}
+
+	return lines
+}
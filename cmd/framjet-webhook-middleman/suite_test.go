@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const suiteConfig = `
+destinations:
+  discord:
+    url: "https://discord.example.com/{{.params.org}}"
+    body: '{"content": "{{.params.repo}}: {{.json.ref}}"}'
+routes:
+  - path: "/github/{org}/{repo}/{event}"
+    matchers:
+      - expr: 'params.event == "push"'
+        to:
+          - discord
+          - url: "https://audit.example.com/{{.params.repo}}"
+            method: PUT
+            headers:
+              X-Org: "{{.params.org}}"
+`
+
+// writeSuite writes the config and a suite with the given tests next to it
+// and returns the suite's path
+func writeSuite(t *testing.T, tests string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), suiteConfig)
+	if err := os.Mkdir(filepath.Join(dir, "payloads"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "payloads", "push.json"), `{"ref": "refs/heads/main"}`)
+
+	path := filepath.Join(dir, "config.test.yaml")
+	writeFile(t, path, "config: config.yaml\ntests:\n"+tests)
+
+	return path
+}
+
+const passingCases = `
+  - name: push notifies discord
+    request:
+      path: /github/acme/web/push
+      body_file: payloads/push.json
+    expect:
+      route: 0
+      status: 200
+      destinations:
+        - name: discord
+          url: https://discord.example.com/acme
+          body: |
+            {
+              "content": "web: refs/heads/main"
+            }
+        - name: inline
+          method: put
+          headers: {x-org: acme}
+  - name: failing destination
+    request:
+      path: /github/acme/web/push
+      body: '{"ref": "v1"}'
+    responses:
+      discord: {status: 500, body: boom}
+    expect:
+      status: 502
+  - name: tags are ignored
+    request:
+      path: /github/acme/web/tag
+    expect:
+      status: 404
+      destinations: []
+  - name: unknown path
+    request:
+      path: /gitlab
+    expect:
+      route: -1
+`
+
+func TestTestSuitePasses(t *testing.T) {
+	out, err := runApp(t, "test-suite", writeSuite(t, passingCases))
+	if err != nil {
+		t.Fatalf("test-suite: %v\n%s", err, out)
+	}
+
+	for _, want := range []string{
+		"PASS  push notifies discord\n",
+		"PASS  failing destination\n",
+		"PASS  tags are ignored\n",
+		"PASS  unknown path\n",
+		": 4 passed, 0 failed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTestSuiteReportsDifferences(t *testing.T) {
+	suite := writeSuite(t, `
+  - name: wrong body
+    request:
+      path: /github/acme/api/push
+      body_file: payloads/push.json
+    expect:
+      route: 1
+      status: 202
+      destinations:
+        - name: discord
+          url: https://discord.example.com/other
+          body: '{"content": "web: refs/heads/main"}'
+        - name: slack
+`+passingCases)
+
+	out, err := runApp(t, "test-suite", suite)
+	if code := exitCode(t, err); code != suiteExitFailed {
+		t.Fatalf("exit code %d\n%s", code, out)
+	}
+
+	for _, want := range []string{
+		"FAIL  wrong body\n",
+		"      route: expected #1, got #0\n",
+		"      status: expected 202, got 200\n",
+		"      destinations: expected [discord, slack], got [discord, inline]\n",
+		"      destination discord url: expected https://discord.example.com/other, got https://discord.example.com/acme\n",
+		"      destination discord body:\n        {\n      -   \"content\": \"web: refs/heads/main\"\n      +   \"content\": \"api: refs/heads/main\"\n        }\n",
+		": 4 passed, 1 failed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runApp(t, "test-suite", "--run", "^tags", suite)
+	if err != nil || !strings.Contains(out, ": 1 passed, 0 failed\n") {
+		t.Errorf("--run: %v\n%s", err, out)
+	}
+}
+
+func TestTestSuiteUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	badConfig := filepath.Join(dir, "bad-config.test.yaml")
+	writeFile(t, badConfig, "config: missing.yaml\ntests: []\n")
+	unknownKey := filepath.Join(dir, "unknown.test.yaml")
+	writeFile(t, unknownKey, "tests:\n  - name: x\n    expects: {}\n")
+	bothBodies := filepath.Join(dir, "both.test.yaml")
+	writeFile(t, bothBodies, "tests:\n  - request: {path: /, body: x, body_file: y}\n")
+
+	for _, args := range [][]string{
+		{"test-suite"},
+		{"test-suite", filepath.Join(dir, "missing.test.yaml")},
+		{"test-suite", badConfig},
+		{"test-suite", unknownKey},
+		{"test-suite", bothBodies},
+		{"test-suite", "--run", "(", writeSuite(t, passingCases)},
+	} {
+		if _, err := runApp(t, args...); exitCode(t, err) != suiteExitUnreadable {
+			t.Errorf("%v: exit code %d", args, exitCode(t, err))
+		}
+	}
+}
+
+func TestDiffBodies(t *testing.T) {
+	if diff := diffBodies(`{"a": 1, "b": [1, 2]}`, "{\n  \"b\": [1,2],\n  \"a\": 1\n}\n"); diff != "" {
+		t.Errorf("equal JSON differs:\n%s", diff)
+	}
+	if diff := diffBodies("same\n\n", "same"); diff != "" {
+		t.Errorf("trailing whitespace differs:\n%s", diff)
+	}
+
+	want := "  one\n- two\n+ 2\n  three\n+ four"
+	if diff := diffBodies("one\ntwo\nthree", "one\n2\nthree\nfour"); diff != want {
+		t.Errorf("diff =\n%s\nwant\n%s", diff, want)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/httpclient"
+	"github.com/framjet/go-webhook-middleman/internal/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v3"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+func testCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "test",
+		Usage: "Dry-run a request against the configuration without calling any destination",
+		UsageText: `framjet-webhook-middleman test --path /github/org/repo/push \
+    --header "X-GitHub-Event: push" --header "Content-Type: application/json" --body @payload.json`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "method",
+				Aliases: []string{"X"},
+				Value:   "POST",
+				Usage:   "Request method",
+			},
+			&cli.StringFlag{
+				Name:     "path",
+				Usage:    "Request path including the query string",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:    "header",
+				Aliases: []string{"H"},
+				Usage:   `Request header as "Name: value", may be repeated`,
+			},
+			&cli.StringFlag{
+				Name:  "body",
+				Usage: "Request body, @file reads it from a file and @- from stdin",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the result as JSON",
+			},
+		},
+		Action: testRequest,
+	}
+}
+
+// testResult is what a dry run found out about a request
+type testResult struct {
+	Explanation *server.Explanation          `json:"explanation"`
+	Outbound    []httpclient.RecordedRequest `json:"outbound"` // Requests that would have been sent
+	Response    testResponse                 `json:"response"`
+}
+
+type testResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+func testRequest(ctx context.Context, c *cli.Command) error {
+	body, err := readBodyArg(c.String("body"))
+	if err != nil {
+		return err
+	}
+
+	request, err := buildTestRequest(c.String("method"), c.String("path"), c.StringSlice("header"), body)
+	if err != nil {
+		return err
+	}
+
+	srv, stub, cleanup, err := newDryRunServer(c.String("config"), c.Duration("timeout"), dryRunLogger(c))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	result, err := dryRun(ctx, srv, stub, request, &server.Explanation{})
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	printTestResult(os.Stdout, result)
+
+	return nil
+}
+
+// dryRunLogger logs to stderr and, as logs would get in the way of the
+// result, only shows problems unless asked for more
+func dryRunLogger(c *cli.Command) *slog.Logger {
+	level := "warn"
+	if c.IsSet("log-level") {
+		level = c.String("log-level")
+	}
+	return newLogger(os.Stderr, level, c.Bool("json-log"))
+}
+
+// newDryRunServer creates a server whose outbound requests go to a stub and
+// whose state lives in a temporary directory removed by cleanup
+func newDryRunServer(configPath string, timeout time.Duration, logger *slog.Logger) (*server.WebhookServer, *httpclient.Stub, func(), error) {
+	dataDir, err := os.MkdirTemp("", "webhook-middleman-test-")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dataDir) }
+
+	stub := &httpclient.Stub{}
+	srv, err := server.NewWebhookServer(server.Options{
+		ConfigPath: configPath,
+		Timeout:    timeout,
+		DataDir:    dataDir,
+		Transport:  stub,
+		Registry:   prometheus.NewRegistry(),
+	}, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, err
+	}
+
+	return srv, stub, cleanup, nil
+}
+
+// dryRun handles the request, explaining its routing into explanation, and
+// waits for async deliveries to finish so every outbound request is recorded
+func dryRun(ctx context.Context, srv *server.WebhookServer, stub *httpclient.Stub, request *http.Request, explanation *server.Explanation) (*testResult, error) {
+	request = request.WithContext(server.WithExplanation(ctx, explanation))
+	stub.Reset()
+
+	recorder := httptest.NewRecorder()
+	srv.SetupRoutes().ServeHTTP(recorder, request)
+
+	drainCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := srv.WaitIdle(drainCtx); err != nil {
+		return nil, err
+	}
+
+	return &testResult{
+		Explanation: explanation,
+		Outbound:    stub.Requests(),
+		Response: testResponse{
+			Status:  recorder.Code,
+			Headers: recorder.Header(),
+			Body:    recorder.Body.String(),
+		},
+	}, nil
+}
+
+func readBodyArg(value string) (string, error) {
+	switch {
+	case value == "@-":
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	case strings.HasPrefix(value, "@"):
+		data, err := os.ReadFile(value[1:])
+		return string(data), err
+	default:
+		return value, nil
+	}
+}
+
+func buildTestRequest(method, path string, headers []string, body string) (*http.Request, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+
+	request := httptest.NewRequest(strings.ToUpper(method), path, strings.NewReader(body))
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header '%s', expected \"Name: value\"", header)
+		}
+		request.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return request, nil
+}
+
+func printTestResult(w io.Writer, result *testResult) {
+	e := result.Explanation
+
+	if e.RouteIndex < 0 {
+		fmt.Fprintln(w, "Route: no route matched")
+	} else {
+		fmt.Fprintf(w, "Route: #%d %s\n", e.RouteIndex, e.Route)
+		for _, name := range slices.Sorted(maps.Keys(e.Params)) {
+			fmt.Fprintf(w, "  %s = %s\n", name, e.Params[name])
+		}
+	}
+
+	if len(e.Matchers) > 0 {
+		fmt.Fprintln(w, "\nMatchers:")
+	}
+	for _, m := range e.Matchers {
+		outcome := "no match"
+		if m.Matched {
+			outcome = "matched"
+		}
+		fmt.Fprintf(w, "  #%d %s\n", m.Index, outcome)
+		for _, expression := range m.Expressions {
+			if expression.Error != "" {
+				fmt.Fprintf(w, "    %s => error: %s\n", expression.Expr, expression.Error)
+				continue
+			}
+			fmt.Fprintf(w, "    %s => %v\n", expression.Expr, expression.Result)
+		}
+	}
+
+	if len(e.Destinations) > 0 || len(e.Errors) > 0 {
+		fmt.Fprintln(w, "\nDestinations:")
+	}
+	for _, dest := range e.Destinations {
+		fmt.Fprintf(w, "  %s: %s %s\n", dest.Name, dest.Method, dest.URL)
+		for _, name := range slices.Sorted(maps.Keys(dest.Headers)) {
+			fmt.Fprintf(w, "    %s: %s\n", name, dest.Headers[name])
+		}
+		if len(dest.Body) > 0 {
+			fmt.Fprintf(w, "    body: %s\n", indentBody(string(dest.Body)))
+		}
+	}
+	for _, err := range e.Errors {
+		fmt.Fprintf(w, "  error: %s\n", err)
+	}
+
+	if len(result.Outbound) > 0 {
+		fmt.Fprintln(w, "\nOutbound requests (stubbed, not sent):")
+	}
+	for _, r := range result.Outbound {
+		fmt.Fprintf(w, "  %s %s\n", r.Method, r.URL)
+		for _, name := range slices.Sorted(maps.Keys(r.Headers)) {
+			fmt.Fprintf(w, "    %s: %s\n", name, strings.Join(r.Headers[name], ", "))
+		}
+	}
+
+	fmt.Fprintf(w, "\nResponse: %d %s\n", result.Response.Status, http.StatusText(result.Response.Status))
+	for _, name := range slices.Sorted(maps.Keys(result.Response.Headers)) {
+		fmt.Fprintf(w, "  %s: %s\n", name, strings.Join(result.Response.Headers[name], ", "))
+	}
+	if result.Response.Body != "" {
+		fmt.Fprintf(w, "  %s\n", indentBody(strings.TrimRight(result.Response.Body, "\n")))
+	}
+}
+
+// indentBody keeps multi-line bodies aligned under their heading
+func indentBody(body string) string {
+	return strings.ReplaceAll(body, "\n", "\n      ")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// dryRunConfig writes a config forwarding to a live server and fails the
+// test if anything reaches it
+func dryRunConfig(t *testing.T) string {
+	t.Helper()
+
+	var calls atomic.Int32
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	t.Cleanup(func() {
+		dest.Close()
+		if calls.Load() > 0 {
+			t.Errorf("destination received %d requests during a dry run", calls.Load())
+		}
+	})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `
+destinations:
+  deploys:
+    url: "`+dest.URL+`/{{.params.service}}"
+    body: '{"service": "{{.params.service}}", "ref": "{{.json.ref}}"}'
+routes:
+  - path: "/{service}/{event}"
+    response:
+      body: '{"forwarded": {{.forwardedTo}}}'
+    matchers:
+      - exprs:
+          - 'params.event == "tag"'
+          - 'params.event == "push"'
+        to:
+          - deploys
+          - url: "`+dest.URL+`/audit"
+            headers:
+              X-Event: "{{.params.event}}"
+`)
+
+	return path
+}
+
+func TestTestCommandJSON(t *testing.T) {
+	config := dryRunConfig(t)
+	payload := filepath.Join(t.TempDir(), "payload.json")
+	writeFile(t, payload, `{"ref": "main"}`)
+
+	out, err := runApp(t, "-c", config, "test", "--path", "/api/push",
+		"-H", "Content-Type: application/json", "--body", "@"+payload, "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result testResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("output %q: %v", out, err)
+	}
+
+	e := result.Explanation
+	if e.RouteIndex != 0 || e.Params["service"] != "api" || e.Params["event"] != "push" {
+		t.Errorf("route = %d %v", e.RouteIndex, e.Params)
+	}
+	if len(e.Matchers) != 1 || !e.Matchers[0].Matched || len(e.Matchers[0].Expressions) != 2 ||
+		e.Matchers[0].Expressions[0].Result != false || e.Matchers[0].Expressions[1].Result != true {
+		t.Errorf("matchers = %+v", e.Matchers)
+	}
+	if len(e.Destinations) != 2 || e.Destinations[0].Name != "deploys" || string(e.Destinations[0].Body) != `{"service": "api", "ref": "main"}` ||
+		e.Destinations[1].Headers["X-Event"] != "push" {
+		t.Errorf("destinations = %+v", e.Destinations)
+	}
+	// Destinations are called concurrently, so the order isn't fixed
+	outbound := map[string]string{}
+	for _, r := range result.Outbound {
+		outbound[r.URL[strings.LastIndex(r.URL, "/"):]] = r.Body
+	}
+	if len(outbound) != 2 || outbound["/api"] != `{"service": "api", "ref": "main"}` || outbound["/audit"] != `{"ref": "main"}` {
+		t.Errorf("outbound = %+v", result.Outbound)
+	}
+	if result.Response.Status != http.StatusOK || result.Response.Body != `{"forwarded": 2}` {
+		t.Errorf("response = %+v", result.Response)
+	}
+}
+
+func TestTestCommandOutput(t *testing.T) {
+	config := dryRunConfig(t)
+
+	out, err := runApp(t, "-c", config, "test", "--path", "/api/push", "--body", `{"ref": "v1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Route: #0 POST /{service}/{event}\n  event = push\n  service = api\n",
+		"  #0 matched\n    params.event == \"tag\" => false\n    params.event == \"push\" => true\n",
+		"Outbound requests (stubbed, not sent):",
+		"Response: 200 OK\n",
+		"  {\"forwarded\": 2}\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runApp(t, "-c", config, "test", "--path", "/api/push/extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Route: no route matched") || !strings.Contains(out, "Response: 404 Not Found") {
+		t.Errorf("unmatched output:\n%s", out)
+	}
+}
+
+func TestTestCommandRejectsBadInput(t *testing.T) {
+	config := dryRunConfig(t)
+
+	for _, args := range [][]string{
+		{"--path", "api/push"},
+		{"--path", "/api/push", "-H", "Content-Type application/json"},
+		{"--path", "/api/push", "--body", "@" + filepath.Join(t.TempDir(), "missing.json")},
+	} {
+		if _, err := runApp(t, append([]string{"-c", config, "test"}, args...)...); err == nil {
+			t.Errorf("%v succeeded", args)
+		}
+	}
+}
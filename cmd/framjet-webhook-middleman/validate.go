@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/urfave/cli/v3"
+	"os"
+)
+
+// Exit codes of the validate command
+const (
+	validateExitInvalid    = 1 // The config has errors, or warnings with --strict
+	validateExitUnreadable = 2 // The config file couldn't be read
+)
+
+func validateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "Check the configuration file and report every problem without starting the server",
+		Description: `Loads the configuration like the server does and reports all problems at once with their line
+and column: YAML errors, unknown keys, undefined destinations, invalid settings, expression and
+template errors, and routes shadowed by earlier ones (as warnings).
+
+Exit codes: 0 when valid, 1 when there are errors (or warnings with --strict), 2 when the file
+can't be read.`,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the diagnostics as JSON",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "Treat warnings as errors",
+			},
+		},
+		Action: validateConfig,
+	}
+}
+
+func validateConfig(_ context.Context, c *cli.Command) error {
+	path := c.String("config")
+
+	diagnostics, err := config.Diagnose(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read configuration: %v", err), validateExitUnreadable)
+	}
+
+	// The server loads with LoadConfig, make sure nothing it rejects goes unreported
+	if _, err := config.LoadConfig(path); err != nil && countSeverity(diagnostics, config.SeverityError) == 0 {
+		diagnostics = append(diagnostics, config.Diagnostic{Severity: config.SeverityError, File: path, Message: err.Error()})
+	}
+
+	errorCount := countSeverity(diagnostics, config.SeverityError)
+	warningCount := countSeverity(diagnostics, config.SeverityWarning)
+	valid := errorCount == 0 && (warningCount == 0 || !c.Bool("strict"))
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			File        string              `json:"file"`
+			Valid       bool                `json:"valid"`
+			Errors      int                 `json:"errors"`
+			Warnings    int                 `json:"warnings"`
+			Diagnostics []config.Diagnostic `json:"diagnostics"`
+		}{path, valid, errorCount, warningCount, nonNil(diagnostics)})
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+		fmt.Printf("%s: %d errors, %d warnings\n", path, errorCount, warningCount)
+	}
+
+	if !valid {
+		return cli.Exit("", validateExitInvalid)
+	}
+
+	return nil
+}
+
+func countSeverity(diagnostics []config.Diagnostic, severity string) int {
+	count := 0
+	for _, d := range diagnostics {
+		if d.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// nonNil makes an empty list encode as [] rather than null
+func nonNil(diagnostics []config.Diagnostic) []config.Diagnostic {
+	if diagnostics == nil {
+		return []config.Diagnostic{}
+	}
+	return diagnostics
+}
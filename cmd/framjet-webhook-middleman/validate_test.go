@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/urfave/cli/v3"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const shadowedConfig = `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook/{tenant}"
+    matchers:
+      - expr: "true"
+        to: echo
+  - path: "/hook/acme"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+
+// exitCode returns the exit code of an error returned by the app, 0 for nil
+func exitCode(t *testing.T, err error) int {
+	t.Helper()
+
+	if err == nil {
+		return 0
+	}
+	var exitErr cli.ExitCoder
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("error %v has no exit code", err)
+	}
+	return exitErr.ExitCode()
+}
+
+func TestValidateExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yaml")
+	writeFile(t, valid, `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`)
+	invalid := filepath.Join(dir, "invalid.yaml")
+	writeFile(t, invalid, `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: missing
+`)
+	shadowed := filepath.Join(dir, "shadowed.yaml")
+	writeFile(t, shadowed, shadowedConfig)
+
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-c", valid, "validate"}, 0},
+		{[]string{"-c", invalid, "validate"}, validateExitInvalid},
+		{[]string{"-c", shadowed, "validate"}, 0},
+		{[]string{"-c", shadowed, "validate", "--strict"}, validateExitInvalid},
+		{[]string{"-c", filepath.Join(dir, "missing.yaml"), "validate"}, validateExitUnreadable},
+	} {
+		_, err := runApp(t, tc.args...)
+		if code := exitCode(t, err); code != tc.code {
+			t.Errorf("%v: exit code %d, want %d", tc.args, code, tc.code)
+		}
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, shadowedConfig)
+
+	out, err := runApp(t, "-c", path, "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, path+":9:5: warning [routes[1].path]: route 1 path '/hook/acme' is unreachable") {
+		t.Errorf("output lacks the warning:\n%s", out)
+	}
+	if !strings.HasSuffix(out, path+": 0 errors, 1 warnings\n") {
+		t.Errorf("output lacks the summary:\n%s", out)
+	}
+
+	out, err = runApp(t, "-c", path, "validate", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Valid       bool                `json:"valid"`
+		Errors      int                 `json:"errors"`
+		Warnings    int                 `json:"warnings"`
+		Diagnostics []config.Diagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("output %q: %v", out, err)
+	}
+	if !report.Valid || report.Errors != 0 || report.Warnings != 1 || len(report.Diagnostics) != 1 || report.Diagnostics[0].Line != 9 {
+		t.Errorf("report = %+v", report)
+	}
+}
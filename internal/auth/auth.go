@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrMisconfigured      = errors.New("credentials misconfigured") // A configured secret failed to render
+)
+
+// RenderFunc renders a configured key, token or secret that may use templates.
+// It must fail rather than return an empty or placeholder value.
+type RenderFunc func(string) (string, error)
+
+// Authenticate checks the request against every configured method and returns
+// the caller of the first one that accepts it. It returns ErrMissingCredentials
+// when the request carries none of the configured credentials.
+func Authenticate(cfg *config.AuthConfig, render RenderFunc, r *http.Request) (*config.AuthInfo, error) {
+	var errs []error
+
+	if cfg.APIKey != nil {
+		info, err := authenticateAPIKey(cfg.APIKey, render, r)
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if cfg.Basic != nil {
+		info, err := authenticateBasic(cfg.Basic, r)
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if cfg.Bearer != nil {
+		info, err := authenticateBearer(cfg.Bearer, render, r)
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if cfg.JWT != nil {
+		info, err := authenticateJWT(cfg.JWT, render, r, time.Now())
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, err)
+	}
+
+	// Report why the presented credentials were rejected rather than which were absent
+	var rejected []error
+	for _, err := range errs {
+		if !errors.Is(err, ErrMissingCredentials) {
+			rejected = append(rejected, err)
+		}
+	}
+	if len(rejected) == 0 {
+		return nil, ErrMissingCredentials
+	}
+
+	return nil, errors.Join(rejected...)
+}
+
+// Headers returns the headers the configured methods read credentials from
+func Headers(cfg *config.AuthConfig) []string {
+	var headers []string
+	if cfg.APIKey != nil && cfg.APIKey.GetHeader() != "" {
+		headers = append(headers, cfg.APIKey.GetHeader())
+	}
+	if cfg.Basic != nil || cfg.Bearer != nil || cfg.JWT != nil {
+		headers = append(headers, "Authorization")
+	}
+	return headers
+}
+
+func authenticateAPIKey(cfg *config.APIKeyAuth, render RenderFunc, r *http.Request) (*config.AuthInfo, error) {
+	var key string
+	if header := cfg.GetHeader(); header != "" {
+		key = r.Header.Get(header)
+	}
+	if key == "" && cfg.Query != "" {
+		key = r.URL.Query().Get(cfg.Query)
+	}
+	if key == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	name, err := matchSecret(cfg.Keys, key, render)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.AuthInfo{Method: config.AuthMethodAPIKey, Subject: name}, nil
+}
+
+// dummyHash is a bcrypt hash at the default cost of a password no user has
+const dummyHash = "$2a$10$lf2QlB3rZ/UmJt/Ax0dWmevZmt2QeLW/Vv/Y1HXWJhIKyAkRH/xiW"
+
+func authenticateBasic(cfg *config.BasicAuth, r *http.Request) (*config.AuthInfo, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrMissingCredentials
+	}
+
+	// An unknown user is checked against a dummy hash so the time taken
+	// doesn't tell which users exist. The username isn't part of the errors
+	// as they are logged, and callers sometimes send the password instead.
+	hash, known := cfg.Users[username]
+	if !known {
+		hash = dummyHash
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if !known {
+		return nil, fmt.Errorf("%w: unknown user", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong password", ErrInvalidCredentials)
+	}
+
+	return &config.AuthInfo{Method: config.AuthMethodBasic, Subject: username}, nil
+}
+
+func authenticateBearer(cfg *config.BearerAuth, render RenderFunc, r *http.Request) (*config.AuthInfo, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrMissingCredentials
+	}
+
+	name, err := matchSecret(cfg.Tokens, token, render)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.AuthInfo{Method: config.AuthMethodBearer, Subject: name}, nil
+}
+
+// matchSecret returns the name of the configured secret equal to value. Every
+// secret is compared so the timing doesn't reveal which one matched.
+func matchSecret(secrets map[string]string, value string, render RenderFunc) (string, error) {
+	matched := ""
+	for name, secret := range secrets {
+		rendered, err := render(secret)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to render '%s': %w", ErrMisconfigured, name, err)
+		}
+		if rendered != "" && subtle.ConstantTimeCompare([]byte(rendered), []byte(value)) == 1 {
+			matched = name
+		}
+	}
+
+	if matched == "" {
+		return "", ErrInvalidCredentials
+	}
+
+	return matched, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
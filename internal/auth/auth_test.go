@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// plain renders secrets as they are, failing on empty ones like the server does
+func plain(secret string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("secret rendered empty")
+	}
+	return secret, nil
+}
+
+func request(target string, headers ...string) *http.Request {
+	r := httptest.NewRequest("POST", target, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+func TestAPIKey(t *testing.T) {
+	keys := map[string]string{"ci": "k1", "ops": "k2"}
+	header := &config.AuthConfig{APIKey: &config.APIKeyAuth{Keys: keys}}
+	query := &config.AuthConfig{APIKey: &config.APIKeyAuth{Query: "key", Keys: keys}}
+
+	info, err := Authenticate(header, plain, request("/hook", "X-API-Key", "k2"))
+	if err != nil || info.Method != config.AuthMethodAPIKey || info.Subject != "ops" {
+		t.Errorf("header: %+v, %v", info, err)
+	}
+	info, err = Authenticate(query, plain, request("/hook?key=k2"))
+	if err != nil || info.Subject != "ops" {
+		t.Errorf("query: %+v, %v", info, err)
+	}
+	if _, err := Authenticate(query, plain, request("/hook", "X-API-Key", "k2")); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("default header with query set: %v", err)
+	}
+
+	cfg := query
+
+	if _, err := Authenticate(cfg, plain, request("/hook?key=nope")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong key: %v", err)
+	}
+	if _, err := Authenticate(cfg, plain, request("/hook")); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("no key: %v", err)
+	}
+}
+
+func TestBasic(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.AuthConfig{Basic: &config.BasicAuth{Users: map[string]string{"alice": string(hash)}}}
+
+	r := request("/hook")
+	r.SetBasicAuth("alice", "pw")
+	if info, err := Authenticate(cfg, plain, r); err != nil || info.Subject != "alice" {
+		t.Errorf("Authenticate = %+v, %v", info, err)
+	}
+
+	for user, password := range map[string]string{"alice": "wrong", "bob": "pw"} {
+		r := request("/hook")
+		r.SetBasicAuth(user, password)
+		_, err := Authenticate(cfg, plain, r)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s:%s: %v", user, password, err)
+		}
+		// Errors end up in logs, where the username could be a mistyped password
+		if strings.Contains(err.Error(), user) {
+			t.Errorf("error %q names the user", err)
+		}
+	}
+}
+
+func TestBasicUnknownUserChecksDummyHash(t *testing.T) {
+	if _, err := bcrypt.Cost([]byte(dummyHash)); err != nil {
+		t.Fatalf("dummy hash isn't a bcrypt hash: %v", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte("")); !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		t.Errorf("dummy hash compare = %v", err)
+	}
+}
+
+func TestBearer(t *testing.T) {
+	cfg := &config.AuthConfig{Bearer: &config.BearerAuth{Tokens: map[string]string{"deploy": "t1"}}}
+
+	if info, err := Authenticate(cfg, plain, request("/hook", "Authorization", "bearer  t1")); err != nil || info.Subject != "deploy" {
+		t.Errorf("Authenticate = %+v, %v", info, err)
+	}
+	if _, err := Authenticate(cfg, plain, request("/hook", "Authorization", "Basic dDE=")); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("other scheme: %v", err)
+	}
+}
+
+func TestMisconfiguredSecretIsNotMatched(t *testing.T) {
+	cfg := &config.AuthConfig{Bearer: &config.BearerAuth{Tokens: map[string]string{"deploy": ""}}}
+
+	// A secret that renders empty must not accept anything, not even an empty token
+	_, err := Authenticate(cfg, plain, request("/hook", "Authorization", "Bearer x"))
+	if !errors.Is(err, ErrMisconfigured) {
+		t.Errorf("Authenticate = %v", err)
+	}
+}
+
+func TestRejectionWinsOverMissingCredentials(t *testing.T) {
+	cfg := &config.AuthConfig{
+		APIKey: &config.APIKeyAuth{Keys: map[string]string{"ci": "k1"}},
+		Bearer: &config.BearerAuth{Tokens: map[string]string{"deploy": "t1"}},
+	}
+
+	if _, err := Authenticate(cfg, plain, request("/hook", "Authorization", "Bearer nope")); !errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("Authenticate = %v", err)
+	}
+	if info, err := Authenticate(cfg, plain, request("/hook", "X-API-Key", "k1", "Authorization", "Bearer nope")); err != nil || info.Subject != "ci" {
+		t.Errorf("one accepted method: %+v, %v", info, err)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	cfg := &config.AuthConfig{
+		APIKey: &config.APIKeyAuth{Header: "X-Key", Keys: map[string]string{"ci": "k1"}},
+		JWT:    &config.JWTAuth{Secret: "s"},
+	}
+	if got := Headers(cfg); !slices.Equal(got, []string{"X-Key", "Authorization"}) {
+		t.Errorf("Headers = %v", got)
+	}
+
+	queryOnly := &config.AuthConfig{APIKey: &config.APIKeyAuth{Query: "key", Keys: map[string]string{"ci": "k1"}}}
+	if got := Headers(queryOnly); len(got) != 0 {
+		t.Errorf("query only Headers = %v", got)
+	}
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // Registers SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func authenticateJWT(cfg *config.JWTAuth, render RenderFunc, r *http.Request, now time.Time) (*config.AuthInfo, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrMissingCredentials
+	}
+
+	claims, err := parseJWT(cfg, render, token, now)
+	if errors.Is(err, ErrMisconfigured) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	return &config.AuthInfo{Method: config.AuthMethodJWT, Subject: subject, Claims: claims}, nil
+}
+
+// parseJWT verifies the signature and registered claims of a compact JWS token
+// and returns its claims
+func parseJWT(cfg *config.JWTAuth, render RenderFunc, token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	if err := verifySignature(cfg, render, header, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	if err := checkClaims(cfg, claims, now); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks the signature with the shared secret for HMAC
+// algorithms and with the JWKS keys otherwise, so a token can't switch a
+// public key into an HMAC secret
+func verifySignature(cfg *config.JWTAuth, render RenderFunc, header jwtHeader, input, signature []byte) error {
+	if strings.HasPrefix(header.Alg, "HS") {
+		if cfg.Secret == "" {
+			return fmt.Errorf("algorithm %s needs a shared secret", header.Alg)
+		}
+
+		secret, err := render(cfg.Secret)
+		if err != nil {
+			return fmt.Errorf("%w: failed to render secret: %w", ErrMisconfigured, err)
+		}
+
+		hash, err := hashFor(header.Alg)
+		if err != nil {
+			return err
+		}
+
+		mac := hmac.New(hash.New, []byte(secret))
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+
+		return nil
+	}
+
+	if cfg.JWKSFile == "" {
+		return fmt.Errorf("algorithm %s needs a jwks_file", header.Alg)
+	}
+
+	keys, err := loadJWKS(cfg.JWKSFile)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if header.Kid != "" && key.ID != header.Kid {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != header.Alg {
+			continue
+		}
+		if verifyWithKey(header.Alg, key.Key, input, signature) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid signature")
+}
+
+func verifyWithKey(alg string, key crypto.PublicKey, input, signature []byte) error {
+	switch alg {
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, input, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+
+	hash, err := hashFor(alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an RSA key")
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an RSA key")
+		}
+		return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an EC key")
+		}
+		// JWS encodes ECDSA signatures as the fixed size concatenation of r and s
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid signature length")
+		}
+		rInt := new(big.Int).SetBytes(signature[:size])
+		sInt := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, rInt, sInt) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+}
+
+func hashFor(alg string) (crypto.Hash, error) {
+	switch {
+	case strings.HasSuffix(alg, "256"):
+		return crypto.SHA256, nil
+	case strings.HasSuffix(alg, "384"):
+		return crypto.SHA384, nil
+	case strings.HasSuffix(alg, "512"):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+}
+
+// checkClaims validates exp, nbf, aud and iss
+func checkClaims(cfg *config.JWTAuth, claims map[string]any, now time.Time) error {
+	if exp, ok := claims["exp"].(float64); ok {
+		if now.After(time.Unix(int64(exp), 0).Add(cfg.Leeway)) {
+			return fmt.Errorf("token expired")
+		}
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if now.Before(time.Unix(int64(nbf), 0).Add(-cfg.Leeway)) {
+			return fmt.Errorf("token not valid yet")
+		}
+	}
+
+	if cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != cfg.Issuer {
+			return fmt.Errorf("unexpected issuer '%s'", iss)
+		}
+	}
+
+	if cfg.Audience != "" {
+		var audiences []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []string{aud}
+		case []any:
+			for _, a := range aud {
+				if s, ok := a.(string); ok {
+					audiences = append(audiences, s)
+				}
+			}
+		}
+		if !slices.Contains(audiences, cfg.Audience) {
+			return fmt.Errorf("token not issued for audience '%s'", cfg.Audience)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var jwtNow = time.Unix(1700000000, 0)
+
+func segment(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// token builds a compact JWS, signing "header.claims" with sign
+func token(header, claims map[string]any, sign func(input []byte) []byte) string {
+	input := segment(header) + "." + segment(claims)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(input []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+}
+
+func authenticateToken(cfg *config.JWTAuth, tok string) (*config.AuthInfo, error) {
+	return authenticateJWT(cfg, plain, request("/hook", "Authorization", "Bearer "+tok), jwtNow)
+}
+
+func TestJWTWithSharedSecret(t *testing.T) {
+	cfg := &config.JWTAuth{Secret: "s3cret", Audience: "hooks", Issuer: "ci", Leeway: time.Minute}
+	header := map[string]any{"alg": "HS256"}
+	valid := map[string]any{"sub": "builder", "aud": []string{"other", "hooks"}, "iss": "ci", "exp": jwtNow.Unix() - 30}
+
+	info, err := authenticateToken(cfg, token(header, valid, hs256("s3cret")))
+	if err != nil || info.Subject != "builder" || info.Method != config.AuthMethodJWT || info.Claims["iss"] != "ci" {
+		t.Fatalf("valid token: %+v, %v", info, err)
+	}
+
+	tests := map[string]struct {
+		claims map[string]any
+		secret string
+	}{
+		"wrong secret":  {valid, "other"},
+		"expired":       {map[string]any{"aud": "hooks", "iss": "ci", "exp": jwtNow.Unix() - 120}, "s3cret"},
+		"not yet valid": {map[string]any{"aud": "hooks", "iss": "ci", "nbf": jwtNow.Unix() + 120}, "s3cret"},
+		"wrong issuer":  {map[string]any{"aud": "hooks", "iss": "prod"}, "s3cret"},
+		"wrong aud":     {map[string]any{"aud": "other", "iss": "ci"}, "s3cret"},
+	}
+	for name, tt := range tests {
+		if _, err := authenticateToken(cfg, token(header, tt.claims, hs256(tt.secret))); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if _, err := authenticateToken(cfg, "not.a-token"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("malformed token: %v", err)
+	}
+}
+
+func TestJWTMisconfiguredSecret(t *testing.T) {
+	cfg := &config.JWTAuth{Secret: "{{.var.unset}}"}
+	failing := func(string) (string, error) { return "", errors.New("no value") }
+
+	tok := token(map[string]any{"alg": "HS256"}, map[string]any{}, hs256(""))
+	_, err := authenticateJWT(cfg, failing, request("/hook", "Authorization", "Bearer "+tok), jwtNow)
+	if !errors.Is(err, ErrMisconfigured) || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate = %v", err)
+	}
+}
+
+// writeJWKS writes the public keys as a JWKS file
+func writeJWKS(t *testing.T, keys ...map[string]any) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestJWTWithJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := writeJWKS(t,
+		map[string]any{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		map[string]any{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		map[string]any{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPublic)},
+		map[string]any{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	)
+	cfg := &config.JWTAuth{JWKSFile: jwks}
+	claims := map[string]any{"sub": "svc"}
+
+	digest := func(input []byte) []byte {
+		sum := sha256.Sum256(input)
+		return sum[:]
+	}
+	signers := map[string]func([]byte) []byte{
+		"RS256": func(input []byte) []byte {
+			sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest(input))
+			return sig
+		},
+		"PS256": func(input []byte) []byte {
+			sig, _ := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest(input), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+			return sig
+		},
+		"ES256": func(input []byte) []byte {
+			r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest(input))
+			return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		},
+		"EdDSA": func(input []byte) []byte {
+			return ed25519.Sign(edPrivate, input)
+		},
+	}
+
+	for alg, sign := range signers {
+		info, err := authenticateToken(cfg, token(map[string]any{"alg": alg}, claims, sign))
+		if alg == "PS256" {
+			// The RSA key is pinned to RS256 in the JWKS
+			if err == nil {
+				t.Errorf("%s accepted with a key limited to RS256", alg)
+			}
+			continue
+		}
+		if err != nil || info.Subject != "svc" {
+			t.Errorf("%s: %+v, %v", alg, info, err)
+		}
+	}
+
+	if _, err := authenticateToken(cfg, token(map[string]any{"alg": "ES256", "kid": "rsa"}, claims, signers["ES256"])); err == nil {
+		t.Error("token accepted with a kid of another key")
+	}
+}
+
+func TestJWTAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := b64(rsaKey.N.Bytes())
+	jwks := writeJWKS(t, map[string]any{"kty": "RSA", "n": n, "e": "AQAB"})
+	cfg := &config.JWTAuth{JWKSFile: jwks}
+
+	// Signing HS256 with the public key material must not work without a shared secret
+	if _, err := authenticateToken(cfg, token(map[string]any{"alg": "HS256"}, map[string]any{}, hs256(n))); err == nil {
+		t.Error("HS256 token accepted with only a JWKS configured")
+	}
+
+	unsigned := segment(map[string]any{"alg": "none"}) + "." + segment(map[string]any{"sub": "x"}) + "."
+	if _, err := authenticateToken(cfg, unsigned); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("alg none: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings control when a breaker opens and how it recovers
+type Settings struct {
+	FailureRatio     float64       // Ratio of failed requests in a window that opens the circuit
+	MinRequests      int           // Requests needed in a window before the ratio is considered
+	Window           time.Duration // Length of the counting window while closed
+	Cooldown         time.Duration // Time the circuit stays open before probing
+	HalfOpenRequests int           // Probe requests allowed while half-open, all must succeed to close
+}
+
+// Breaker is a circuit breaker with closed, open and half-open states
+type Breaker struct {
+	mu       sync.Mutex
+	settings Settings
+	state    State
+	onChange func(from, to State)
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt          time.Time
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+// New creates a closed breaker. onChange, if set, is called on every state
+// transition while the breaker's lock is held, so it must not call back into it.
+func New(settings Settings, onChange func(from, to State)) *Breaker {
+	return &Breaker{
+		settings:    settings,
+		onChange:    onChange,
+		windowStart: time.Now(),
+	}
+}
+
+// Update replaces the settings while keeping the current state
+func (b *Breaker) Update(settings Settings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.settings = settings
+}
+
+// Allow reports whether a request may be made now. Every allowed request must
+// be followed by a call to Record.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < b.settings.Cooldown {
+			return false
+		}
+		b.setState(StateHalfOpen, now)
+		fallthrough
+	case StateHalfOpen:
+		if b.halfOpenInFlight+b.halfOpenSuccesses >= b.settings.HalfOpenRequests {
+			return false
+		}
+		b.halfOpenInFlight++
+		return true
+	default:
+		if now.Sub(b.windowStart) >= b.settings.Window {
+			b.resetWindow(now)
+		}
+		return true
+	}
+}
+
+// Record reports the outcome of an allowed request
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	switch b.state {
+	case StateHalfOpen:
+		b.halfOpenInFlight--
+		if !success {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.settings.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	case StateClosed:
+		b.requests++
+		if !success {
+			b.failures++
+		}
+		if b.requests >= b.settings.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.settings.FailureRatio {
+			b.setState(StateOpen, now)
+		}
+	}
+}
+
+// Abandon gives back an allowed request that ended without telling anything
+// about the destination, such as one cancelled on our side
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen && b.halfOpenInFlight > 0 {
+		b.halfOpenInFlight--
+	}
+}
+
+// State returns the current state, moving an open breaker whose cooldown has
+// elapsed to half-open
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.settings.Cooldown {
+		b.setState(StateHalfOpen, time.Now())
+	}
+
+	return b.state
+}
+
+// RetryAt returns when an open breaker will allow probe requests again
+func (b *Breaker) RetryAt() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateOpen {
+		return time.Now()
+	}
+
+	return b.openedAt.Add(b.settings.Cooldown)
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccesses = 0
+	b.resetWindow(now)
+	if state == StateOpen {
+		b.openedAt = now
+	}
+
+	if b.onChange != nil {
+		b.onChange(from, state)
+	}
+}
+
+func (b *Breaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
+
+// Registry holds one breaker per name
+type Registry struct {
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+	onChange func(name string, from, to State)
+}
+
+func NewRegistry(onChange func(name string, from, to State)) *Registry {
+	return &Registry{
+		breakers: make(map[string]*Breaker),
+		onChange: onChange,
+	}
+}
+
+// Get returns the breaker for name, creating it or updating its settings
+func (r *Registry) Get(name string, settings Settings) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b, ok := r.breakers[name]; ok {
+		b.Update(settings)
+		return b
+	}
+
+	var onChange func(from, to State)
+	if r.onChange != nil {
+		onChange = func(from, to State) {
+			r.onChange(name, from, to)
+		}
+	}
+
+	b := New(settings, onChange)
+	r.breakers[name] = b
+
+	return b
+}
+
+// States returns the current state of every breaker by name
+func (r *Registry) States() map[string]State {
+	r.mu.Lock()
+	breakers := make(map[string]*Breaker, len(r.breakers))
+	for name, b := range r.breakers {
+		breakers[name] = b
+	}
+	r.mu.Unlock()
+
+	states := make(map[string]State, len(breakers))
+	for name, b := range breakers {
+		states[name] = b.State()
+	}
+
+	return states
+}
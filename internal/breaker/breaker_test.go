@@ -0,0 +1,133 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func testSettings() Settings {
+	return Settings{
+		FailureRatio:     0.5,
+		MinRequests:      4,
+		Window:           time.Minute,
+		Cooldown:         20 * time.Millisecond,
+		HalfOpenRequests: 1,
+	}
+}
+
+func record(b *Breaker, outcomes ...bool) {
+	for _, success := range outcomes {
+		b.Allow()
+		b.Record(success)
+	}
+}
+
+func TestOpensOnFailureRatio(t *testing.T) {
+	var transitions []State
+	b := New(testSettings(), func(_, to State) { transitions = append(transitions, to) })
+
+	record(b, false, false, true)
+	if b.State() != StateClosed {
+		t.Fatal("opened before min_requests")
+	}
+
+	record(b, true)
+	if b.State() != StateOpen || b.Allow() {
+		t.Fatalf("state = %s after 2 of 4 failed", b.State())
+	}
+	if !b.RetryAt().After(time.Now()) {
+		t.Error("RetryAt isn't in the future")
+	}
+	if len(transitions) != 1 || transitions[0] != StateOpen {
+		t.Errorf("transitions = %v", transitions)
+	}
+}
+
+func TestHalfOpenProbe(t *testing.T) {
+	b := New(testSettings(), nil)
+	record(b, false, false, false, false)
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("probe not allowed after cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("second probe allowed while the first is in flight")
+	}
+
+	b.Record(false)
+	if b.State() != StateOpen {
+		t.Fatalf("failed probe left state %s", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	record(b, true)
+	if b.State() != StateClosed {
+		t.Fatalf("successful probe left state %s", b.State())
+	}
+}
+
+func TestAbandonReleasesHalfOpenProbe(t *testing.T) {
+	b := New(testSettings(), nil)
+	record(b, false, false, false, false)
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("probe not allowed after cooldown")
+	}
+	b.Abandon()
+
+	if b.State() != StateHalfOpen {
+		t.Fatalf("abandoned probe changed state to %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("abandoned probe still holds the half-open slot")
+	}
+}
+
+func TestAbandonIsNotCountedWhileClosed(t *testing.T) {
+	b := New(testSettings(), nil)
+
+	for range 10 {
+		b.Allow()
+		b.Abandon()
+	}
+	record(b, false, true, true)
+
+	if b.State() != StateClosed {
+		t.Errorf("state = %s", b.State())
+	}
+}
+
+func TestFailuresOutsideWindowAreForgotten(t *testing.T) {
+	settings := testSettings()
+	settings.Window = 20 * time.Millisecond
+	b := New(settings, nil)
+
+	record(b, false, false, false)
+	time.Sleep(30 * time.Millisecond)
+	record(b, false, true, true, true)
+
+	if b.State() != StateClosed {
+		t.Errorf("state = %s", b.State())
+	}
+}
+
+func TestRegistryKeepsStateAcrossUpdates(t *testing.T) {
+	var changed []string
+	r := NewRegistry(func(name string, _, _ State) { changed = append(changed, name) })
+
+	record(r.Get("api", testSettings()), false, false, false, false)
+
+	settings := testSettings()
+	settings.Cooldown = time.Hour
+	if r.Get("api", settings).State() != StateOpen {
+		t.Error("updating settings reset the state")
+	}
+	if states := r.States(); states["api"] != StateOpen || len(states) != 1 {
+		t.Errorf("States = %v", states)
+	}
+	if len(changed) != 1 || changed[0] != "api" {
+		t.Errorf("changes = %v", changed)
+	}
+}
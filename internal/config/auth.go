@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodBasic  = "basic"
+	AuthMethodBearer = "bearer"
+	AuthMethodJWT    = "jwt"
+)
+
+// AuthConfig describes how inbound requests on a route are authenticated.
+// When several methods are configured a request passing any one of them is accepted.
+type AuthConfig struct {
+	APIKey *APIKeyAuth `yaml:"api_key,omitempty" expr:"api_key"`
+	Basic  *BasicAuth  `yaml:"basic,omitempty" expr:"basic"`
+	Bearer *BearerAuth `yaml:"bearer,omitempty" expr:"bearer"`
+	JWT    *JWTAuth    `yaml:"jwt,omitempty" expr:"jwt"`
+}
+
+// APIKeyAuth accepts static keys sent in a header or query parameter
+type APIKeyAuth struct {
+	Header string            `yaml:"header,omitempty" expr:"header"` // Header carrying the key, default X-API-Key unless query is set
+	Query  string            `yaml:"query,omitempty" expr:"query"`   // Query parameter carrying the key
+	Keys   map[string]string `yaml:"keys" expr:"-" secret:"true"`    // Key name to key, keys may use templates such as {{.var.ci_key}}
+}
+
+// BasicAuth accepts HTTP Basic credentials checked against bcrypt hashes
+type BasicAuth struct {
+	Realm string            `yaml:"realm,omitempty" expr:"realm"` // Realm sent in WWW-Authenticate, default "webhook"
+	Users map[string]string `yaml:"users" expr:"-" secret:"true"` // Username to bcrypt hash
+}
+
+// BearerAuth accepts static tokens sent as "Authorization: Bearer <token>"
+type BearerAuth struct {
+	Tokens map[string]string `yaml:"tokens" expr:"-" secret:"true"` // Token name to token, tokens may use templates
+}
+
+// JWTAuth accepts JSON Web Tokens sent as "Authorization: Bearer <token>"
+type JWTAuth struct {
+	Secret   string        `yaml:"secret,omitempty" expr:"-" secret:"true"` // Shared secret for HS256, HS384 and HS512, may use templates
+	JWKSFile string        `yaml:"jwks_file,omitempty" expr:"jwks_file"`    // Local JWKS file with public keys for RS*, PS*, ES* and EdDSA
+	Audience string        `yaml:"audience,omitempty" expr:"audience"`      // Required "aud" claim
+	Issuer   string        `yaml:"issuer,omitempty" expr:"issuer"`          // Required "iss" claim
+	Leeway   time.Duration `yaml:"leeway,omitempty" expr:"leeway"`          // Allowed clock skew for "exp" and "nbf"
+}
+
+// AuthInfo describes the authenticated caller of a request
+type AuthInfo struct {
+	Method  string         `json:"method" expr:"method"`           // api_key, basic, bearer or jwt
+	Subject string         `json:"subject" expr:"subject"`         // Key or token name, username, or the JWT "sub" claim
+	Claims  map[string]any `json:"claims,omitempty" expr:"claims"` // Verified JWT claims
+}
+
+func (a *AuthConfig) Validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.APIKey == nil && a.Basic == nil && a.Bearer == nil && a.JWT == nil {
+		return fmt.Errorf("auth has no methods configured")
+	}
+	if a.APIKey != nil && len(a.APIKey.Keys) == 0 {
+		return fmt.Errorf("auth api_key has no keys")
+	}
+	if a.Basic != nil {
+		if len(a.Basic.Users) == 0 {
+			return fmt.Errorf("auth basic has no users")
+		}
+		for user, hash := range a.Basic.Users {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return fmt.Errorf("auth basic user '%s' has no valid bcrypt hash: %w", user, err)
+			}
+		}
+	}
+	if a.Bearer != nil && len(a.Bearer.Tokens) == 0 {
+		return fmt.Errorf("auth bearer has no tokens")
+	}
+	if a.JWT != nil {
+		if a.JWT.Secret == "" && a.JWT.JWKSFile == "" {
+			return fmt.Errorf("auth jwt needs a secret or jwks_file")
+		}
+		if a.JWT.Leeway < 0 {
+			return fmt.Errorf("auth jwt leeway must not be negative")
+		}
+	}
+	return nil
+}
+
+// GetHeader returns the header carrying the API key, empty when only the query is used
+func (k *APIKeyAuth) GetHeader() string {
+	if k.Header == "" && k.Query == "" {
+		return "X-API-Key"
+	}
+	return k.Header
+}
+
+// GetRealm returns the realm announced to clients
+func (b *BasicAuth) GetRealm() string {
+	if b.Realm == "" {
+		return "webhook"
+	}
+	return b.Realm
+}
@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func authRoute(auth string) string {
+	return `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook"
+    auth:
+` + auth + `
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestAuthValidation(t *testing.T) {
+	wantLoadError(t, authRoute(`
+      api_key: {}`), "auth api_key has no keys")
+	wantLoadError(t, authRoute(`
+      basic:
+        users:
+          alice: plaintext`), "auth basic user 'alice' has no valid bcrypt hash")
+	wantLoadError(t, authRoute(`
+      bearer:
+        tokens:
+          ci: "{{.var.missing}}"`), "missing")
+}
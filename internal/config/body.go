@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxFormMemory bounds how much of a multipart body is held in memory while
+// parsing, larger file parts spill to temporary files that are removed again
+const maxFormMemory = 32 << 20
+
+// ParsedBody is the request body together with its structured forms. Each
+// form is parsed on first use and shared by every matcher and template of the
+// request.
+type ParsedBody struct {
+	raw         string
+	contentType string
+
+	jsonOnce sync.Once
+	json     any
+
+	formOnce sync.Once
+	form     map[string][]string
+}
+
+func NewParsedBody(raw, contentType string) *ParsedBody {
+	return &ParsedBody{raw: raw, contentType: contentType}
+}
+
+// Raw returns the body as received
+func (b *ParsedBody) Raw() string {
+	if b == nil {
+		return ""
+	}
+	return b.raw
+}
+
+// JSON returns the decoded JSON body, nil if the body isn't valid JSON
+func (b *ParsedBody) JSON() any {
+	if b == nil {
+		return nil
+	}
+
+	b.jsonOnce.Do(func() {
+		var value any
+		if err := json.Unmarshal([]byte(b.raw), &value); err == nil {
+			b.json = value
+		}
+	})
+
+	return b.json
+}
+
+// Form returns the fields of a urlencoded or multipart form body, nil for
+// other content types. File parts are not included.
+func (b *ParsedBody) Form() map[string][]string {
+	if b == nil {
+		return nil
+	}
+
+	b.formOnce.Do(func() {
+		b.form = parseForm(b.raw, b.contentType)
+	})
+
+	return b.form
+}
+
+// SetBody sets the body read by request.json and request.form
+func (r *RequestData) SetBody(body *ParsedBody) {
+	r.parsed = body
+}
+
+// JSON returns the decoded JSON body, nil if the body isn't valid JSON
+func (r RequestData) JSON() any {
+	return r.parsed.JSON()
+}
+
+// Form returns the urlencoded or multipart form fields, nil for other bodies
+func (r RequestData) Form() map[string][]string {
+	return r.parsed.Form()
+}
+
+// compileExpression compiles an expression run against a MatcherEnv
+func compileExpression(src string) (*vm.Program, error) {
+	return expr.Compile(src, expr.Env(MatcherEnv{}), expr.Patch(bodyPatcher{}))
+}
+
+// bodyPatcher turns request.json and request.form in expressions into calls
+// of RequestData.JSON and RequestData.Form, so the body is parsed by the first
+// expression reading it rather than for every request
+type bodyPatcher struct{}
+
+func (bodyPatcher) Visit(node *ast.Node) {
+	member, ok := (*node).(*ast.MemberNode)
+	if !ok {
+		return
+	}
+	object, ok := member.Node.(*ast.IdentifierNode)
+	property, isString := member.Property.(*ast.StringNode)
+	if !ok || !isString || object.Value != "request" {
+		return
+	}
+
+	method := map[string]string{"json": "JSON", "form": "Form"}[property.Value]
+	if method == "" {
+		return
+	}
+
+	ast.Patch(node, &ast.CallNode{
+		Callee: &ast.MemberNode{
+			Node:     member.Node,
+			Property: &ast.StringNode{Value: method},
+			Optional: member.Optional,
+			Method:   true,
+		},
+	})
+}
+
+func parseForm(raw, contentType string) map[string][]string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			return nil
+		}
+		return values
+	case "multipart/form-data":
+		form, err := multipart.NewReader(strings.NewReader(raw), params["boundary"]).ReadForm(maxFormMemory)
+		if err != nil {
+			return nil
+		}
+		defer form.RemoveAll()
+		return form.Value
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"github.com/expr-lang/expr"
+	"mime/multipart"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParsedBodyJSON(t *testing.T) {
+	body := NewParsedBody(`{"action": "opened", "number": 7}`, "application/json")
+
+	want := map[string]any{"action": "opened", "number": float64(7)}
+	if got := body.JSON(); !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON = %v", got)
+	}
+	if body.Form() != nil {
+		t.Errorf("Form = %v for a JSON body", body.Form())
+	}
+
+	if got := NewParsedBody(`not json`, "application/json").JSON(); got != nil {
+		t.Errorf("JSON of invalid body = %v", got)
+	}
+}
+
+func TestParsedBodyURLEncodedForm(t *testing.T) {
+	body := NewParsedBody("command=%2Fdeploy&text=api+prod&text=now", "application/x-www-form-urlencoded; charset=utf-8")
+
+	want := map[string][]string{"command": {"/deploy"}, "text": {"api prod", "now"}}
+	if got := body.Form(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Form = %v", got)
+	}
+	if body.JSON() != nil {
+		t.Errorf("JSON = %v for a form body", body.JSON())
+	}
+}
+
+func TestParsedBodyMultipartForm(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("event", "push"); err != nil {
+		t.Fatal(err)
+	}
+	file, err := writer.CreateFormFile("attachment", "report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.Write([]byte("file contents"))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	body := NewParsedBody(buf.String(), writer.FormDataContentType())
+
+	want := map[string][]string{"event": {"push"}}
+	if got := body.Form(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Form = %v, file parts should be left out", got)
+	}
+
+	if got := NewParsedBody(buf.String(), "multipart/form-data; boundary=wrong").Form(); got != nil {
+		t.Errorf("Form with the wrong boundary = %v", got)
+	}
+}
+
+func TestParsedBodyOtherContentTypes(t *testing.T) {
+	for _, contentType := range []string{"", "text/plain", "not a media type;;"} {
+		if got := NewParsedBody("a=b", contentType).Form(); got != nil {
+			t.Errorf("Form for %q = %v", contentType, got)
+		}
+	}
+}
+
+func TestParsedBodyNil(t *testing.T) {
+	var body *ParsedBody
+	if body.Raw() != "" || body.JSON() != nil || body.Form() != nil {
+		t.Error("nil body isn't empty")
+	}
+}
+
+func TestParsedBodyIsParsedOnce(t *testing.T) {
+	body := NewParsedBody(`{"items": [1, 2]}`, "application/json")
+
+	var wg sync.WaitGroup
+	results := make([]any, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = body.JSON()
+		}()
+	}
+	wg.Wait()
+
+	first := results[0].(map[string]any)
+	for _, result := range results[1:] {
+		if reflect.ValueOf(result).Pointer() != reflect.ValueOf(first).Pointer() {
+			t.Fatal("JSON was decoded more than once")
+		}
+	}
+}
+
+func TestExpressionsParseTheBodyOnUse(t *testing.T) {
+	body := NewParsedBody(`{"action": "opened"}`, "application/json")
+	env := &MatcherEnv{Request: RequestData{Body: body.Raw()}}
+	env.Request.SetBody(body)
+
+	run := func(src string) any {
+		t.Helper()
+		program, err := compileExpression(src)
+		if err != nil {
+			t.Fatalf("compileExpression(%q): %v", src, err)
+		}
+		result, err := expr.Run(program, env)
+		if err != nil {
+			t.Fatalf("Run(%q): %v", src, err)
+		}
+		return result
+	}
+
+	if run(`request.body != ""`) != true || body.json != nil {
+		t.Fatal("body parsed by an expression that doesn't read it")
+	}
+	if run(`request.json?.action == "opened" && request.form == nil`) != true {
+		t.Error("request.json didn't read the parsed body")
+	}
+	if body.json == nil {
+		t.Error("body not parsed by an expression reading request.json")
+	}
+}
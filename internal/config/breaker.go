@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	CircuitOpenQueue = "queue"
+	CircuitOpenFail  = "fail"
+)
+
+// CircuitBreakerConfig configures the circuit breaker of a named destination
+type CircuitBreakerConfig struct {
+	FailureRatio     float64       `yaml:"failure_ratio,omitempty" expr:"failure_ratio"`           // Default 0.5
+	MinRequests      int           `yaml:"min_requests,omitempty" expr:"min_requests"`             // Default 10
+	Window           time.Duration `yaml:"window,omitempty" expr:"window"`                         // Default 1m
+	Cooldown         time.Duration `yaml:"cooldown,omitempty" expr:"cooldown"`                     // Default 30s
+	HalfOpenRequests int           `yaml:"half_open_requests,omitempty" expr:"half_open_requests"` // Default 1
+	OnOpen           string        `yaml:"on_open,omitempty" expr:"on_open"`                       // "queue" (default) sends deliveries to the retry/dead-letter path, "fail" just fails them
+}
+
+func (c *CircuitBreakerConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.FailureRatio < 0 || c.FailureRatio > 1 {
+		return fmt.Errorf("circuit_breaker failure_ratio must be between 0 and 1")
+	}
+	if c.MinRequests < 0 || c.HalfOpenRequests < 0 {
+		return fmt.Errorf("circuit_breaker request counts must not be negative")
+	}
+	if c.Window < 0 || c.Cooldown < 0 {
+		return fmt.Errorf("circuit_breaker durations must not be negative")
+	}
+	if c.OnOpen != "" && c.OnOpen != CircuitOpenQueue && c.OnOpen != CircuitOpenFail {
+		return fmt.Errorf("circuit_breaker has invalid on_open '%s'", c.OnOpen)
+	}
+	return nil
+}
+
+// WithDefaults returns a copy with unset fields filled in
+func (c CircuitBreakerConfig) WithDefaults() CircuitBreakerConfig {
+	if c.FailureRatio == 0 {
+		c.FailureRatio = 0.5
+	}
+	if c.MinRequests == 0 {
+		c.MinRequests = 10
+	}
+	if c.Window == 0 {
+		c.Window = time.Minute
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = 30 * time.Second
+	}
+	if c.HalfOpenRequests == 0 {
+		c.HalfOpenRequests = 1
+	}
+	if c.OnOpen == "" {
+		c.OnOpen = CircuitOpenQueue
+	}
+	return c
+}
@@ -5,19 +5,38 @@ import (
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"gopkg.in/yaml.v3"
-	"os"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
 )
 
 type Config struct {
 	Destinations map[string]FlexibleDestination `yaml:"destinations" expr:"destinations"`
 	Variables    map[string]string              `yaml:"variables,omitempty" expr:"variables"`
 	Routes       []Route                        `yaml:"routes" expr:"routes"`
+	Include      []string                       `yaml:"include,omitempty" expr:"-"` // Further files, directories or globs, relative to the file
+
+	templates    *TemplateCache            `yaml:"-"` // Parsed templates
+	signings     map[string]*SigningConfig `yaml:"-"` // Signing configs by path
+	secrets      map[string]string         `yaml:"-"` // Secret variable name to value
+	interpolated []string                  `yaml:"-"` // Secret values interpolated from the environment
+	redacted     *Config                   `yaml:"-"` // See Redacted
+	secretFiles  []string                  `yaml:"-"`
+	origins      *origins                  `yaml:"-"` // Files the config was merged from
 }
 
 type Destination struct {
-	URL    string `yaml:"url,omitempty" expr:"url"`
-	Method string `yaml:"method,omitempty" expr:"method"`
-	Body   string `yaml:"body,omitempty" expr:"body"`
+	URL     string            `yaml:"url,omitempty" expr:"url"`
+	Method  string            `yaml:"method,omitempty" expr:"method"`
+	Body    string            `yaml:"body,omitempty" expr:"body"`
+	Retry   *RetryPolicy      `yaml:"retry,omitempty" expr:"retry"`
+	Signing *SigningConfig    `yaml:"signing,omitempty" expr:"signing"`
+	HTTP    *HTTPClientConfig `yaml:"http,omitempty" expr:"http"`
+
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker,omitempty" expr:"circuit_breaker"`
+	Throttle       *ThrottleConfig       `yaml:"throttle,omitempty" expr:"throttle"`
+	Readiness      *ReadinessConfig      `yaml:"readiness,omitempty" expr:"readiness"`
 }
 
 type Route struct {
@@ -28,8 +47,18 @@ type Route struct {
 	Matchers     []*Matcher              `yaml:"matchers,omitempty" expr:"matchers"`
 	Destinations map[string]*Destination `yaml:"destinations,omitempty" expr:"destinations"`
 	Response     *RouteResponse          `yaml:"response,omitempty" expr:"response"`
+	Mode         string                  `yaml:"mode,omitempty" expr:"mode"` // "sync" (default) or "async"
+	Verify       *VerifyConfig           `yaml:"verify,omitempty" expr:"verify"`
+	Auth         *AuthConfig             `yaml:"auth,omitempty" expr:"auth"`
+	RateLimit    *RateLimitConfig        `yaml:"rate_limit,omitempty" expr:"rate_limit"`
+	Dedupe       *DedupeConfig           `yaml:"dedupe,omitempty" expr:"dedupe"`
 }
 
+const (
+	RouteModeSync  = "sync"
+	RouteModeAsync = "async"
+)
+
 type RouteResponse struct {
 	Status  *RouteResponseStatus `yaml:"status,omitempty" expr:"status"`
 	Headers *map[string]string   `yaml:"headers,omitempty" expr:"headers"`
@@ -37,8 +66,9 @@ type RouteResponse struct {
 }
 
 type RouteResponseStatus struct {
-	Success *int `yaml:"success,omitempty" expr:"success"` // Default 200 OK
-	Failure *int `yaml:"failure,omitempty" expr:"failure"` // Default 502 Bad Gateway
+	Success  *int `yaml:"success,omitempty" expr:"success"`   // Default 200 OK
+	Failure  *int `yaml:"failure,omitempty" expr:"failure"`   // Default 502 Bad Gateway
+	Accepted *int `yaml:"accepted,omitempty" expr:"accepted"` // Default 202 Accepted, used by async routes
 }
 
 type DestinationRef struct {
@@ -47,6 +77,11 @@ type DestinationRef struct {
 	Method  string            `yaml:"method,omitempty" expr:"method"`
 	Body    string            `yaml:"body,omitempty" expr:"body"`
 	Headers map[string]string `yaml:"headers,omitempty" expr:"headers"`
+	Retry   *RetryPolicy      `yaml:"retry,omitempty" expr:"retry"`
+	Signing *SigningConfig    `yaml:"signing,omitempty" expr:"signing"`
+	HTTP    *HTTPClientConfig `yaml:"http,omitempty" expr:"http"`
+
+	signingRef string // Path of Signing in the config, see SigningAt
 }
 
 type Matcher struct {
@@ -71,14 +106,39 @@ type ForwardResult struct {
 	StatusCode  int               `json:"status_code,omitempty"`
 	Error       string            `json:"error,omitempty"`
 	Duration    int64             `json:"duration_ms"`
+	Attempts    int               `json:"attempts,omitempty"`
+	Queued      bool              `json:"queued,omitempty"`       // Failed delivery was queued for retry
+	CircuitOpen bool              `json:"circuit_open,omitempty"` // Not attempted because the destination's circuit is open
+	Throttled   bool              `json:"throttled,omitempty"`    // Not attempted because it couldn't get a turn within max_wait
+	Paused      bool              `json:"paused,omitempty"`       // Not attempted because the destination is paused
+	RetryAfter  *time.Time        `json:"retry_after,omitempty"`  // The destination asked not to be called again before this time
 }
 
 type ResolvedDestination struct {
-	Name    string
-	URL     string
-	Method  string
-	Headers map[string]string
-	Body    []byte
+	ID      string            `json:"id"` // Delivery ID, stable across retries
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+	Retry   *RetryPolicy      `json:"retry,omitempty"`
+	Signing *SigningConfig    `json:"-"` // Secret is already rendered, so it is only kept in memory
+	HTTP    *HTTPClientConfig `json:"http,omitempty"`
+
+	// SigningRef is the path of the signing config in the config, for signing
+	// persisted deliveries with the secret rendered again from the live config
+	SigningRef string `json:"signing_ref,omitempty"`
+}
+
+// RequestMeta describes the inbound request a delivery originated from
+type RequestMeta struct {
+	ID         string            `json:"id"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	RemoteAddr string            `json:"remote_addr"`
+	Headers    http.Header       `json:"headers,omitempty"` // Copied to the destination request
+	Params     map[string]string `json:"params,omitempty"`  // Route parameters, for rendering signing secrets again
+	ReceivedAt time.Time         `json:"received_at"`
 }
 
 type RequestUrlData struct {
@@ -94,6 +154,7 @@ type RequestUrlData struct {
 }
 
 type RequestData struct {
+	ID          string              `json:"id" expr:"id"` // Request ID taken from the caller or generated
 	Method      string              `json:"method" expr:"method"`
 	Url         RequestUrlData      `json:"url" expr:"url"`
 	Headers     map[string][]string `json:"headers" expr:"headers"`
@@ -102,6 +163,23 @@ type RequestData struct {
 	ContentType string              `json:"contentType" expr:"contentType"`
 	UserAgent   string              `json:"userAgent" expr:"userAgent"`
 	RemoteAddr  string              `json:"remoteAddr" expr:"remoteAddr"`
+	ClientIP    string              `json:"clientIp" expr:"clientIp"` // Remote address, or the forwarding client behind a trusted proxy
+	TLS         bool                `json:"tls" expr:"tls"`
+	ClientCert  ClientCertData      `json:"clientCert" expr:"clientCert"` // Verified client certificate, empty without mTLS
+
+	parsed *ParsedBody // Read by expressions as request.json and request.form
+}
+
+// ClientCertData describes the verified client certificate of an mTLS request
+type ClientCertData struct {
+	Subject      string   `json:"subject" expr:"subject"` // Distinguished name such as "CN=ci,O=Example"
+	CommonName   string   `json:"commonName" expr:"commonName"`
+	Organization []string `json:"organization" expr:"organization"`
+	DNSNames     []string `json:"dnsNames" expr:"dnsNames"`
+	Emails       []string `json:"emails" expr:"emails"`
+	URIs         []string `json:"uris" expr:"uris"`
+	Issuer       string   `json:"issuer" expr:"issuer"`
+	SerialNumber string   `json:"serialNumber" expr:"serialNumber"`
 }
 
 type MatcherEnv struct {
@@ -111,65 +189,191 @@ type MatcherEnv struct {
 	Config  Config            `json:"config" expr:"config"`
 	Route   Route             `json:"route" expr:"route"`
 	Request RequestData       `json:"request" expr:"request"`
+	Auth    AuthInfo          `json:"auth" expr:"auth"` // Authenticated caller, empty when the route has no auth
 }
 
+// LoadConfig loads the configuration from a file, the YAML files of a
+// directory or the files matching a glob, along with the files they include
 func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
+	l := newLoader()
+	if err := l.loadPath(path); err != nil {
 		return nil, err
 	}
 
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, err
+	config, problems := l.merge()
+	problems = append(l.problems, problems...)
+	if len(problems) > 0 {
+		problem := problems[0]
+		if problem.Path != "" {
+			return nil, fmt.Errorf("%s: %s: %w", problem.File, problem.Path, problem.Err)
+		}
+		return nil, config.problemError(problem)
 	}
 
-	err = config.Validate()
-	if err != nil {
-		return nil, err
+	if problems := config.validate(); len(problems) > 0 {
+		return nil, config.problemError(problems[0])
 	}
 
-	err = config.CompileConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to compile config: %w", err)
+	if problems := config.compile(); len(problems) > 0 {
+		return nil, fmt.Errorf("failed to compile config: %w", config.problemError(problems[0]))
 	}
 
-	return &config, nil
+	return config, nil
+}
+
+// Problem is a configuration error together with where it is in the config
+type Problem struct {
+	File string // File the problem is in, when known
+	Path string // Location in the config such as routes[0].matchers[1]
+	Err  error
 }
 
 func (c *Config) Validate() error {
+	if problems := c.validate(); len(problems) > 0 {
+		return problems[0].Err
+	}
+	return nil
+}
+
+// validate returns every problem in the config, Validate reports the first
+func (c *Config) validate() []Problem {
+	var problems []Problem
+	report := func(path string, err error) {
+		problems = append(problems, Problem{Path: path, Err: err})
+	}
+
 	if len(c.Routes) == 0 {
-		return fmt.Errorf("no routes configured")
+		report("routes", fmt.Errorf("no routes configured"))
 	}
 
 	for i, route := range c.Routes {
+		path := fmt.Sprintf("routes[%d]", i)
+
 		if len(route.Paths) == 0 && route.Path == "" {
-			return fmt.Errorf("route %d has no paths configured", i)
+			report(path, fmt.Errorf("route %d has no paths configured", i))
+		}
+
+		if route.Mode != "" && route.Mode != RouteModeSync && route.Mode != RouteModeAsync {
+			report(path+".mode", fmt.Errorf("route %d has invalid mode '%s'", i, route.Mode))
+		}
+
+		if err := route.Verify.Validate(); err != nil {
+			report(path+".verify", fmt.Errorf("route %d: %w", i, err))
+		}
+
+		if err := route.Auth.Validate(); err != nil {
+			report(path+".auth", fmt.Errorf("route %d: %w", i, err))
+		}
+
+		if err := route.RateLimit.Validate(); err != nil {
+			report(path+".rate_limit", fmt.Errorf("route %d: %w", i, err))
+		}
+
+		if err := route.Dedupe.Validate(); err != nil {
+			report(path+".dedupe", fmt.Errorf("route %d: %w", i, err))
 		}
 
 		for j, matcher := range route.Matchers {
 			if len(matcher.To) == 0 {
-				return fmt.Errorf("route %d matcher %d has no destinations", i, j)
+				report(fmt.Sprintf("%s.matchers[%d]", path, j), fmt.Errorf("route %d matcher %d has no destinations", i, j))
 			}
 		}
 	}
 
 	// Validate destination URLs
-	for name, dest := range c.Destinations {
+	for _, name := range slices.Sorted(maps.Keys(c.Destinations)) {
+		dest := c.Destinations[name]
+		path := "destinations." + name
+
 		if dest.URL == "" {
-			return fmt.Errorf("destination %s has empty URL", name)
+			report(path, fmt.Errorf("destination %s has empty URL", name))
+		}
+		if err := dest.Retry.Validate(); err != nil {
+			report(path+".retry", fmt.Errorf("destination %s: %w", name, err))
+		}
+		if err := dest.Signing.Validate(); err != nil {
+			report(path+".signing", fmt.Errorf("destination %s: %w", name, err))
+		}
+		if err := dest.CircuitBreaker.Validate(); err != nil {
+			report(path+".circuit_breaker", fmt.Errorf("destination %s: %w", name, err))
+		}
+		if err := dest.HTTP.Validate(); err != nil {
+			report(path+".http", fmt.Errorf("destination %s: %w", name, err))
+		}
+		if err := dest.Throttle.Validate(); err != nil {
+			report(path+".throttle", fmt.Errorf("destination %s: %w", name, err))
+		}
+		if err := dest.Readiness.Validate(dest.URL); err != nil {
+			report(path+".readiness", fmt.Errorf("destination %s: %w", name, err))
 		}
 	}
 
-	return nil
+	for i, route := range c.Routes {
+		for j, matcher := range route.Matchers {
+			for k, ref := range matcher.To {
+				path := fmt.Sprintf("routes[%d].matchers[%d].to[%d]", i, j, k)
+
+				if err := ref.Retry.Validate(); err != nil {
+					report(path+".retry", fmt.Errorf("route %d matcher %d destination %d: %w", i, j, k, err))
+				}
+				if err := ref.Signing.Validate(); err != nil {
+					report(path+".signing", fmt.Errorf("route %d matcher %d destination %d: %w", i, j, k, err))
+				}
+				if err := ref.HTTP.Validate(); err != nil {
+					report(path+".http", fmt.Errorf("route %d matcher %d destination %d: %w", i, j, k, err))
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// IsAsync reports whether the route answers before forwarding completes
+func (r *Route) IsAsync() bool {
+	return r.Mode == RouteModeAsync
 }
 
 func (c *Config) CompileConfig() error {
+	if problems := c.compile(); len(problems) > 0 {
+		return problems[0].Err
+	}
+	return nil
+}
+
+// compile compiles every expression and template, returning all problems
+// found. CompileConfig reports the first.
+func (c *Config) compile() []Problem {
+	var problems []Problem
+	report := func(path string, err error) {
+		problems = append(problems, Problem{Path: path, Err: err})
+	}
+
 	for routeIndex, route := range c.Routes {
+		path := fmt.Sprintf("routes[%d]", routeIndex)
+
+		if route.RateLimit != nil {
+			for limitIndex, limit := range route.RateLimit.Limits {
+				if err := limit.CompileKey(); err != nil {
+					report(fmt.Sprintf("%s.rate_limit.limits[%d].key", path, limitIndex), fmt.Errorf("route %d rate_limit %d: %w", routeIndex, limitIndex, err))
+				} else if limit.ReadsAuth() && route.Auth == nil {
+					report(fmt.Sprintf("%s.rate_limit.limits[%d].key", path, limitIndex), fmt.Errorf("route %d rate_limit %d: key reads auth but the route has no auth", routeIndex, limitIndex))
+				}
+			}
+		}
+
+		if route.Dedupe != nil {
+			if err := route.Dedupe.CompileKey(); err != nil {
+				report(path+".dedupe.key", fmt.Errorf("route %d dedupe: %w", routeIndex, err))
+			}
+		}
+
 		for matcherIndex, matcher := range route.Matchers {
+			matcherPath := fmt.Sprintf("%s.matchers[%d]", path, matcherIndex)
+
 			if len(matcher.To) == 0 {
-				return fmt.Errorf("route %d matcher %d has no destinations", routeIndex, matcherIndex)
+				report(matcherPath, fmt.Errorf("route %d matcher %d has no destinations", routeIndex, matcherIndex))
+				continue
 			}
 
 			expressions := matcher.Exprs
@@ -180,13 +384,39 @@ func (c *Config) CompileConfig() error {
 			matcher.Exprs = expressions
 
 			err := matcher.CompileExpressions()
-			if err != nil {
-				return fmt.Errorf("failed to compile expressions for route %d matcher %d: %w", routeIndex, matcherIndex, err)
+			if err == nil {
+				continue
+			}
+
+			// Compile the expressions one by one to point at every broken one
+			reported := false
+			for exprIndex, expression := range matcher.Exprs {
+				if _, exprErr := compileExpression(expression); exprErr != nil {
+					report(matcherPath+matcher.expressionPath(exprIndex), fmt.Errorf("failed to compile expressions for route %d matcher %d: failed to compile expression '%s': %w", routeIndex, matcherIndex, expression, exprErr))
+					reported = true
+				}
+			}
+			if !reported {
+				report(matcherPath, fmt.Errorf("failed to compile expressions for route %d matcher %d: %w", routeIndex, matcherIndex, err))
 			}
 		}
 	}
 
-	return nil
+	for _, problem := range c.compileTemplates() {
+		report(problem.Path, fmt.Errorf("failed to compile templates for %w", problem.Err))
+	}
+
+	// Expressions see the redacted config on every request, so redact it once
+	c.redacted = nil
+	redacted := c.Redacted()
+	c.redacted = &redacted
+
+	return problems
+}
+
+// Templates returns the templates parsed when the config was compiled
+func (c *Config) Templates() *TemplateCache {
+	return c.templates
 }
 
 func (fd *FlexibleDestination) UnmarshalYAML(node *yaml.Node) error {
@@ -249,7 +479,7 @@ func (m *Matcher) CompileExpressions() error {
 	m.programs = make([]*vm.Program, 0, len(m.Exprs)+1)
 
 	for _, expression := range m.Exprs {
-		program, err := expr.Compile(expression, expr.Env(MatcherEnv{}))
+		program, err := compileExpression(expression)
 		if err != nil {
 			return fmt.Errorf("failed to compile expression '%s': %w", expression, err)
 		}
@@ -259,6 +489,42 @@ func (m *Matcher) CompileExpressions() error {
 	return nil
 }
 
+// ExpressionResult is the outcome of one matcher expression
+type ExpressionResult struct {
+	Expr   string `json:"expr"`
+	Result any    `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EvaluateEach runs every expression of the matcher and returns each result.
+// Unlike Evaluate it doesn't stop at the first match, it is meant for
+// explaining how a request was matched.
+func (m *Matcher) EvaluateEach(env *MatcherEnv) []ExpressionResult {
+	results := make([]ExpressionResult, len(m.programs))
+
+	for i, program := range m.programs {
+		results[i].Expr = m.Exprs[i]
+
+		result, err := expr.Run(program, env)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Result = result
+	}
+
+	return results
+}
+
+// expressionPath returns where the compiled expression at index is in the
+// config, expr is compiled after the exprs list
+func (m *Matcher) expressionPath(index int) string {
+	if m.Expr != "" && index == len(m.Exprs)-1 {
+		return ".expr"
+	}
+	return fmt.Sprintf(".exprs[%d]", index)
+}
+
 func (m *Matcher) Evaluate(env *MatcherEnv) (bool, error) {
 	if len(m.programs) == 0 {
 		return false, fmt.Errorf("no compiled expressions available for matcher")
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadConfig loads content as a config file from a temporary directory
+func loadConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, content)
+
+	return LoadConfig(path)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wantLoadError fails the test unless loading content fails mentioning want
+func wantLoadError(t *testing.T, content, want string) {
+	t.Helper()
+
+	_, err := loadConfig(t, content)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("load error = %v, want it to mention %q", err, want)
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"time"
+)
+
+const (
+	DedupeStoreMemory = "memory"
+	DedupeStoreDisk   = "disk"
+)
+
+// DedupeConfig suppresses repeated deliveries of the same event. Requests with
+// a key seen within TTL are answered with the original response instead of
+// being forwarded again.
+type DedupeConfig struct {
+	Key        string        `yaml:"key" expr:"key"`                           // Expression computing the idempotency key, an empty result skips deduplication
+	TTL        time.Duration `yaml:"ttl,omitempty" expr:"ttl"`                 // Default 24h
+	MaxEntries int           `yaml:"max_entries,omitempty" expr:"max_entries"` // Keys kept in memory, default 10000
+	Store      string        `yaml:"store,omitempty" expr:"store"`             // memory (default) or disk to survive restarts
+
+	program *vm.Program `yaml:"-"` // Compiled key expression
+}
+
+func (d *DedupeConfig) Validate() error {
+	if d == nil {
+		return nil
+	}
+	if d.Key == "" {
+		return fmt.Errorf("dedupe needs a key expression")
+	}
+	if d.TTL < 0 || d.MaxEntries < 0 {
+		return fmt.Errorf("dedupe ttl and max_entries must not be negative")
+	}
+	if d.Store != "" && d.Store != DedupeStoreMemory && d.Store != DedupeStoreDisk {
+		return fmt.Errorf("dedupe has unknown store '%s'", d.Store)
+	}
+	return nil
+}
+
+// GetTTL returns how long a key is remembered
+func (d *DedupeConfig) GetTTL() time.Duration {
+	if d.TTL == 0 {
+		return 24 * time.Hour
+	}
+	return d.TTL
+}
+
+// GetMaxEntries returns how many keys are kept in memory
+func (d *DedupeConfig) GetMaxEntries() int {
+	if d.MaxEntries == 0 {
+		return 10000
+	}
+	return d.MaxEntries
+}
+
+// Persistent reports whether keys are also stored on disk
+func (d *DedupeConfig) Persistent() bool {
+	return d.Store == DedupeStoreDisk
+}
+
+// CompileKey compiles the key expression
+func (d *DedupeConfig) CompileKey() error {
+	program, err := compileExpression(d.Key)
+	if err != nil {
+		return fmt.Errorf("failed to compile key expression '%s': %w", d.Key, err)
+	}
+	d.program = program
+
+	return nil
+}
+
+// EvaluateKey computes the idempotency key of a request
+func (d *DedupeConfig) EvaluateKey(env *MatcherEnv) (string, error) {
+	if d.program == nil {
+		return "", fmt.Errorf("no compiled key expression available for dedupe")
+	}
+
+	result, err := expr.Run(d.program, env)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate key expression: %w", err)
+	}
+	if result == nil {
+		return "", nil
+	}
+
+	return fmt.Sprint(result), nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func dedupeRoute(dedupe string) string {
+	return `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook/{tenant}"
+    dedupe:
+` + dedupe + `
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestDedupeValidation(t *testing.T) {
+	wantLoadError(t, dedupeRoute(`
+      ttl: 1h`), "dedupe needs a key expression")
+	wantLoadError(t, dedupeRoute(`
+      key: params.tenant
+      ttl: -1h`), "must not be negative")
+	wantLoadError(t, dedupeRoute(`
+      key: params.tenant
+      store: redis`), "unknown store 'redis'")
+	wantLoadError(t, dedupeRoute(`
+      key: 'params.tenant +'`), "failed to compile key expression")
+}
+
+func TestDedupeDefaultsAndKey(t *testing.T) {
+	cfg, err := loadConfig(t, dedupeRoute(`
+      key: 'params.tenant + ":" + (request.headers["X-Delivery"]?.[0] ?? "")'`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	dedupe := cfg.Routes[0].Dedupe
+	if dedupe.GetTTL() != 24*time.Hour || dedupe.GetMaxEntries() != 10000 || dedupe.Persistent() {
+		t.Errorf("defaults = %s, %d, %v", dedupe.GetTTL(), dedupe.GetMaxEntries(), dedupe.Persistent())
+	}
+
+	key, err := dedupe.EvaluateKey(&MatcherEnv{
+		Params:  map[string]string{"tenant": "acme"},
+		Request: RequestData{Headers: map[string][]string{"X-Delivery": {"evt-1"}}},
+	})
+	if err != nil || key != "acme:evt-1" {
+		t.Errorf("EvaluateKey = %q, %v", key, err)
+	}
+
+	if _, err := (&DedupeConfig{Key: "x"}).EvaluateKey(&MatcherEnv{}); err == nil {
+		t.Error("uncompiled key evaluated")
+	}
+}
@@ -0,0 +1,439 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found in a config file
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Path     string `json:"path,omitempty"` // Location in the config such as routes[0].matchers[1].expr
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	location := d.File
+	if d.Line > 0 {
+		location += fmt.Sprintf(":%d", d.Line)
+	}
+	if d.Column > 0 {
+		location += fmt.Sprintf(":%d", d.Column)
+	}
+	if d.Path != "" {
+		return fmt.Sprintf("%s: %s [%s]: %s", location, d.Severity, d.Path, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// Diagnose checks a configuration the way LoadConfig does but reports every
+// problem instead of the first, located by file, line and column. On top of
+// what LoadConfig rejects it reports unknown keys, references to undefined
+// destinations and routes that can never match. The error is only set when
+// the configuration can't be read.
+func Diagnose(path string) ([]Diagnostic, error) {
+	l := newLoader()
+	if err := l.loadPath(path); err != nil {
+		return nil, err
+	}
+
+	d := &diagnoser{sources: l.sources}
+
+	for _, src := range l.sources {
+		d.checkKeys(src.path, &src.root, reflect.TypeOf(Config{}), "")
+	}
+
+	for _, problem := range l.problems {
+		if problem.Path == "" {
+			d.reportYAMLError(problem.File, problem.Err)
+			continue
+		}
+		d.reportIn(problem.File, SeverityError, problem.Path, problem.Err.Error())
+	}
+	if l.broken {
+		return d.sorted(), nil
+	}
+
+	config, problems := l.merge()
+	d.config = config
+	for _, problem := range problems {
+		d.reportIn(problem.File, SeverityError, problem.Path, problem.Err.Error())
+	}
+
+	for _, problem := range config.validate() {
+		d.report(SeverityError, problem.Path, problem.Err.Error())
+	}
+	for _, problem := range config.compile() {
+		d.report(SeverityError, problem.Path, problem.Err.Error())
+	}
+
+	d.checkReferences(config)
+	d.checkShadowedRoutes(config)
+
+	return d.sorted(), nil
+}
+
+type diagnoser struct {
+	sources     []*source
+	config      *Config // Merged config, locates paths in their files
+	diagnostics []Diagnostic
+}
+
+// report adds a diagnostic for a path of the merged config
+func (d *diagnoser) report(severity, path, message string) {
+	file, local := d.config.locate(path)
+	d.reportIn(file, severity, local, message)
+}
+
+// reportIn adds a diagnostic for a path within a file
+func (d *diagnoser) reportIn(file, severity, path, message string) {
+	diagnostic := Diagnostic{Severity: severity, File: file, Path: path, Message: message}
+	if node := d.locate(file, path); node != nil {
+		diagnostic.Line = node.Line
+		diagnostic.Column = node.Column
+	}
+	d.diagnostics = append(d.diagnostics, diagnostic)
+}
+
+// reportYAMLError turns a YAML syntax or type error into diagnostics, one
+// per line the error mentions
+func (d *diagnoser) reportYAMLError(file string, err error) {
+	messages := []string{err.Error()}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	for _, message := range messages {
+		diagnostic := Diagnostic{Severity: SeverityError, File: file, Message: message}
+		if match := yamlLinePattern.FindStringSubmatch(message); match != nil {
+			diagnostic.Line, _ = strconv.Atoi(match[1])
+		}
+		d.diagnostics = append(d.diagnostics, diagnostic)
+	}
+}
+
+// locate returns the node for a path in a file, or for the closest enclosing
+// path when the exact one isn't in the file, e.g. a destination given as a
+// plain string
+func (d *diagnoser) locate(file, path string) *yaml.Node {
+	var nodes map[string]*yaml.Node
+	for _, src := range d.sources {
+		if src.path == file {
+			nodes = src.nodes
+		}
+	}
+
+	for path != "" {
+		if node, ok := nodes[path]; ok {
+			return node
+		}
+
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			return nil
+		}
+		path = path[:cut]
+	}
+
+	return nil
+}
+
+// indexNodes records the node of every path in the document. Mapping entries
+// are located at their key.
+func indexNodes(nodes map[string]*yaml.Node, node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			indexNodes(nodes, child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := joinPath(path, node.Content[i].Value)
+			nodes[childPath] = node.Content[i]
+			indexNodes(nodes, node.Content[i+1], childPath)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			nodes[childPath] = child
+			indexNodes(nodes, child, childPath)
+		}
+	}
+}
+
+var (
+	flexibleDestinationType = reflect.TypeOf(FlexibleDestination{})
+	flexibleToType          = reflect.TypeOf(FlexibleTo{})
+	destinationType         = reflect.TypeOf(Destination{})
+	destinationRefType      = reflect.TypeOf(DestinationRef{})
+)
+
+// checkKeys reports mapping keys that don't correspond to a field of the type
+// the node is decoded into. Values of the wrong kind are left to the decoder.
+func (d *diagnoser) checkKeys(file string, node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			d.checkKeys(file, child, t, path)
+		}
+		return
+	case yaml.AliasNode:
+		d.checkKeys(file, node.Alias, t, path)
+		return
+	}
+
+	// Types with their own unmarshalling also accept a plain string
+	switch t {
+	case flexibleDestinationType:
+		t = destinationType
+	case flexibleToType:
+		if node.Kind == yaml.SequenceNode {
+			for i, item := range node.Content {
+				d.checkKeys(file, item, destinationRefType, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if key == "<<" {
+				continue
+			}
+
+			childPath := joinPath(path, key)
+			field, ok := fields[key]
+			if !ok {
+				d.reportIn(file, SeverityError, childPath, fmt.Sprintf("unknown key '%s'", key))
+				continue
+			}
+			d.checkKeys(file, node.Content[i+1], field.Type, childPath)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			d.checkKeys(file, node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+
+		for i, item := range node.Content {
+			d.checkKeys(file, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// yamlFields maps the YAML keys of a struct to its fields
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if strings.Contains(options, "inline") {
+			for key, inner := range yamlFields(field.Type) {
+				fields[key] = inner
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+
+	return fields
+}
+
+// checkReferences reports destinations in to that resolve to nothing
+func (d *diagnoser) checkReferences(config *Config) {
+	for i, route := range config.Routes {
+		for j, matcher := range route.Matchers {
+			for k, ref := range matcher.To {
+				path := fmt.Sprintf("routes[%d].matchers[%d].to[%d]", i, j, k)
+
+				switch {
+				case ref.Name != "":
+					if _, ok := config.Destinations[ref.Name]; !ok {
+						d.report(SeverityError, path, fmt.Sprintf("route %d matcher %d destination %d references unknown destination '%s'", i, j, k, ref.Name))
+					}
+				case ref.URL == "":
+					d.report(SeverityError, path, fmt.Sprintf("route %d matcher %d destination %d has neither a name nor a url", i, j, k))
+				}
+			}
+		}
+	}
+}
+
+// checkShadowedRoutes reports route paths that can never be reached because
+// an earlier route matches every request they would. Requests go to the first
+// route whose method and path match.
+func (d *diagnoser) checkShadowedRoutes(config *Config) {
+	for j, route := range config.Routes {
+		for pathIndex, path := range routePaths(route) {
+			shadowedBy := -1
+			for i := 0; i < j && shadowedBy < 0; i++ {
+				if routeCovers(config.Routes[i], routeMethods(route), path) {
+					shadowedBy = i
+				}
+			}
+			if shadowedBy < 0 {
+				continue
+			}
+
+			// path comes after the paths list
+			location := fmt.Sprintf("routes[%d].paths[%d]", j, pathIndex)
+			if pathIndex == len(route.Paths) {
+				location = fmt.Sprintf("routes[%d].path", j)
+			}
+			d.report(SeverityWarning, location, fmt.Sprintf("route %d path '%s' is unreachable, route %d matches it first", j, path, shadowedBy))
+		}
+	}
+}
+
+// routeCovers reports whether the route matches every request for path with
+// any of the methods
+func routeCovers(route Route, methods []string, path string) bool {
+	for _, method := range methods {
+		covered := false
+		for _, candidate := range routeMethods(route) {
+			if candidate == method {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+
+	for _, pattern := range routePaths(route) {
+		if patternCovers(pattern, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// patternCovers reports whether every path matching the pattern other also
+// matches pattern. Parameters match any single segment.
+func patternCovers(pattern, other string) bool {
+	patternParts := strings.Split(pattern, "/")
+	otherParts := strings.Split(other, "/")
+	if len(patternParts) != len(otherParts) {
+		return false
+	}
+
+	for i, part := range patternParts {
+		if isPathParam(part) {
+			continue
+		}
+		if isPathParam(otherParts[i]) || part != otherParts[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isPathParam(part string) bool {
+	return strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+}
+
+// routeMethods returns the upper-cased methods of a route, POST by default
+func routeMethods(route Route) []string {
+	methods := route.Methods
+	if route.Method != "" {
+		methods = append(methods, route.Method)
+	}
+	if len(methods) == 0 {
+		return []string{"POST"}
+	}
+
+	upper := make([]string, len(methods))
+	for i, method := range methods {
+		upper[i] = strings.ToUpper(method)
+	}
+
+	return upper
+}
+
+func routePaths(route Route) []string {
+	paths := route.Paths
+	if route.Path != "" {
+		paths = append(paths, route.Path)
+	}
+	return paths
+}
+
+// sorted returns the diagnostics in the order of the files and their lines
+func (d *diagnoser) sorted() []Diagnostic {
+	order := make(map[string]int)
+	for i, src := range d.sources {
+		order[src.path] = i
+	}
+
+	sort.SliceStable(d.diagnostics, func(i, j int) bool {
+		a, b := d.diagnostics[i], d.diagnostics[j]
+		if a.File != b.File {
+			return order[a.File] < order[b.File]
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return d.diagnostics
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// diagnose writes content as a config file and diagnoses it
+func diagnose(t *testing.T, content string) []Diagnostic {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, content)
+
+	diagnostics, err := Diagnose(path)
+	if err != nil {
+		t.Fatalf("Diagnose: %v", err)
+	}
+	return diagnostics
+}
+
+// findDiagnostic returns the diagnostic at path, failing the test if there is none
+func findDiagnostic(t *testing.T, diagnostics []Diagnostic, path string) Diagnostic {
+	t.Helper()
+
+	for _, d := range diagnostics {
+		if d.Path == path {
+			return d
+		}
+	}
+	t.Fatalf("no diagnostic for %s in %v", path, diagnostics)
+	return Diagnostic{}
+}
+
+func TestDiagnoseReportsEveryProblem(t *testing.T) {
+	diagnostics := diagnose(t, `destinations:
+  echo:
+    url: "http://localhost"
+    retyr: {}
+  broken:
+    url: "http://localhost/{{.params.x"
+routes:
+  - path: "/hook/{tenant}"
+    matchers:
+      - expr: 'params.tenant =='
+        to: echo
+      - expr: "true"
+        to: ["echo", "missing"]
+  - path: "/hook/acme"
+    matchers:
+      - expr: "true"
+        to: echo
+`)
+
+	for _, want := range []struct {
+		path     string
+		severity string
+		line     int
+		column   int
+		message  string
+	}{
+		{"destinations.echo.retyr", SeverityError, 4, 5, "unknown key 'retyr'"},
+		{"destinations.broken.url", SeverityError, 6, 5, "destination broken url"},
+		{"routes[0].matchers[0].expr", SeverityError, 10, 9, "failed to compile expression 'params.tenant =='"},
+		{"routes[0].matchers[1].to[1]", SeverityError, 13, 22, "references unknown destination 'missing'"},
+		{"routes[1].path", SeverityWarning, 14, 5, "route 1 path '/hook/acme' is unreachable, route 0 matches it first"},
+	} {
+		d := findDiagnostic(t, diagnostics, want.path)
+		if d.Severity != want.severity || d.Line != want.line || d.Column != want.column || !strings.Contains(d.Message, want.message) {
+			t.Errorf("%s = %+v, want %s at %d:%d mentioning %q", want.path, d, want.severity, want.line, want.column, want.message)
+		}
+	}
+
+	for i := 1; i < len(diagnostics); i++ {
+		if diagnostics[i].Line < diagnostics[i-1].Line {
+			t.Errorf("diagnostics aren't sorted by line: %v", diagnostics)
+		}
+	}
+}
+
+func TestDiagnoseValidConfig(t *testing.T) {
+	diagnostics := diagnose(t, `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook/acme"
+    matchers:
+      - expr: "true"
+        to: echo
+  - methods: ["GET"]
+    path: "/hook/{tenant}"
+    matchers:
+      - expr: "true"
+        to: echo
+`)
+	if len(diagnostics) != 0 {
+		t.Errorf("diagnostics = %v", diagnostics)
+	}
+}
+
+func TestDiagnoseYAMLErrors(t *testing.T) {
+	diagnostics := diagnose(t, `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook"
+    matchers: "not a list"
+`)
+	if len(diagnostics) == 0 || diagnostics[0].Severity != SeverityError || diagnostics[0].Line != 6 {
+		t.Errorf("diagnostics = %v", diagnostics)
+	}
+}
+
+func TestDiagnoseUnreadableConfig(t *testing.T) {
+	if _, err := Diagnose(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Diagnose succeeded for a missing file")
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{Severity: SeverityError, File: "config.yaml", Line: 4, Column: 5, Path: "routes[0]", Message: "broken"}
+	if got := d.String(); got != "config.yaml:4:5: error [routes[0]]: broken" {
+		t.Errorf("String = %q", got)
+	}
+
+	d = Diagnostic{Severity: SeverityWarning, File: "config.yaml", Message: "odd"}
+	if got := d.String(); got != "config.yaml: warning: odd" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestPatternCovers(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, other string
+		want           bool
+	}{
+		{"/hook/{tenant}", "/hook/acme", true},
+		{"/hook/{tenant}", "/hook/{id}", true},
+		{"/hook/acme", "/hook/{tenant}", false},
+		{"/hook/acme", "/hook/acme", true},
+		{"/hook/{tenant}", "/hook/acme/push", false},
+		{"/{service}/{event}", "/hook/acme", true},
+	} {
+		if got := patternCovers(tc.pattern, tc.other); got != tc.want {
+			t.Errorf("patternCovers(%q, %q) = %v", tc.pattern, tc.other, got)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConfigFiles returns the files a config path names: the file itself, the
+// .yaml and .yml files of a directory, or the files matching a glob, sorted
+// by name
+func ConfigFiles(path string) ([]string, error) {
+	files, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no configuration files match '%s'", path)
+	}
+	return files, nil
+}
+
+func expandPath(path string) ([]string, error) {
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		return slices.DeleteFunc(matches, isDir), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Hidden entries include the ..data links of Kubernetes ConfigMap mounts
+		if strings.HasPrefix(name, ".") || (filepath.Ext(name) != ".yaml" && filepath.Ext(name) != ".yml") {
+			continue
+		}
+		if file := filepath.Join(path, name); !isDir(file) {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// source is one file of a configuration
+type source struct {
+	path   string
+	root   yaml.Node
+	nodes  map[string]*yaml.Node // Config path to the node it was read from
+	config Config
+}
+
+// origins records where the parts of a merged configuration came from
+type origins struct {
+	files        []string // Loaded files in order
+	patterns     []string // Config path and includes, expanded again to notice added and removed files
+	routes       []routeOrigin
+	destinations map[string]string // Destination name to file
+	variables    map[string]string // Variable name to file
+}
+
+type routeOrigin struct {
+	file  string
+	index int // Index of the route within its file
+}
+
+// loader reads the files of a configuration, following includes
+type loader struct {
+	sources      []*source
+	seen         map[string]bool
+	patterns     []string
+	secrets      map[string]string
+	interpolated []string // Secret values interpolated from the environment
+	files        []string // Secret files
+	problems     []Problem
+	broken       bool // A file couldn't be read or decoded, so the merged config is incomplete
+}
+
+func newLoader() *loader {
+	return &loader{seen: make(map[string]bool), secrets: make(map[string]string)}
+}
+
+// loadPath loads the files a config path names
+func (l *loader) loadPath(path string) error {
+	files, err := ConfigFiles(path)
+	if err != nil {
+		return err
+	}
+
+	l.patterns = append(l.patterns, path)
+	for _, file := range files {
+		l.load(file)
+	}
+
+	return nil
+}
+
+// load reads a file and then the files it includes. A file is only loaded
+// once however often it's included.
+func (l *loader) load(path string) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	if l.seen[key] {
+		return
+	}
+	l.seen[key] = true
+
+	report := func(p string, err error) {
+		l.problems = append(l.problems, Problem{File: path, Path: p, Err: err})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		report("", err)
+		l.broken = true
+		return
+	}
+
+	src := &source{path: path, nodes: make(map[string]*yaml.Node)}
+	if err := yaml.Unmarshal(data, &src.root); err != nil {
+		report("", err)
+		l.broken = true
+		return
+	}
+	indexNodes(src.nodes, &src.root, "")
+	l.sources = append(l.sources, src)
+
+	dir := filepath.Dir(path)
+	resolved := resolveNode(&src.root, dir)
+	for _, problem := range resolved.problems {
+		report(problem.Path, problem.Err)
+	}
+	maps.Copy(l.secrets, resolved.secrets)
+	l.interpolated = append(l.interpolated, resolved.interpolated...)
+	l.files = append(l.files, resolved.files...)
+
+	includes := includeList(&src.root)
+	if src.root.Kind != 0 {
+		if err := src.root.Decode(&src.config); err != nil {
+			report("", err)
+			l.broken = true
+		}
+	}
+
+	for i, include := range includes {
+		pattern := include
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		l.patterns = append(l.patterns, pattern)
+
+		files, err := expandPath(pattern)
+		if err != nil {
+			report(fmt.Sprintf("include[%d]", i), fmt.Errorf("failed to include '%s': %w", include, err))
+			continue
+		}
+		for _, file := range files {
+			l.load(file)
+		}
+	}
+}
+
+// includeList returns the files a document includes. A single include may be
+// given as a string, which is turned into a list for decoding.
+func includeList(root *yaml.Node) []string {
+	node := mappingValue(root, "include")
+	if node == nil {
+		return nil
+	}
+
+	if node.Kind == yaml.ScalarNode {
+		*node = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: node.Line, Column: node.Column, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: node.Value, Line: node.Line, Column: node.Column},
+		}}
+	}
+
+	var includes []string
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode {
+			includes = append(includes, item.Value)
+		}
+	}
+
+	return includes
+}
+
+// merge combines the loaded files into one config. Routes keep the order the
+// files were loaded in, destination and variable names must be unique.
+func (l *loader) merge() (*Config, []Problem) {
+	var problems []Problem
+
+	config := &Config{
+		Destinations: make(map[string]FlexibleDestination),
+		Variables:    make(map[string]string),
+		secrets:      l.secrets,
+		interpolated: l.interpolated,
+		secretFiles:  l.files,
+		origins: &origins{
+			patterns:     l.patterns,
+			destinations: make(map[string]string),
+			variables:    make(map[string]string),
+		},
+	}
+
+	for _, src := range l.sources {
+		config.origins.files = append(config.origins.files, src.path)
+
+		for _, name := range slices.Sorted(maps.Keys(src.config.Destinations)) {
+			if file, ok := config.origins.destinations[name]; ok {
+				problems = append(problems, Problem{File: src.path, Path: joinPath("destinations", name), Err: fmt.Errorf("destination '%s' is already defined in %s", name, file)})
+				continue
+			}
+			config.Destinations[name] = src.config.Destinations[name]
+			config.origins.destinations[name] = src.path
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(src.config.Variables)) {
+			if file, ok := config.origins.variables[name]; ok {
+				problems = append(problems, Problem{File: src.path, Path: joinPath("variables", name), Err: fmt.Errorf("variable '%s' is already defined in %s", name, file)})
+				continue
+			}
+			config.Variables[name] = src.config.Variables[name]
+			config.origins.variables[name] = src.path
+		}
+
+		for i, route := range src.config.Routes {
+			config.Routes = append(config.Routes, route)
+			config.origins.routes = append(config.origins.routes, routeOrigin{file: src.path, index: i})
+		}
+	}
+
+	return config, problems
+}
+
+var routePathPattern = regexp.MustCompile(`^routes\[(\d+)\]`)
+
+// locate returns the file a path of the merged config comes from and the
+// path within that file
+func (c *Config) locate(path string) (string, string) {
+	if c.origins == nil || len(c.origins.files) == 0 {
+		return "", path
+	}
+
+	if match := routePathPattern.FindStringSubmatch(path); match != nil {
+		index, _ := strconv.Atoi(match[1])
+		if index < len(c.origins.routes) {
+			origin := c.origins.routes[index]
+			return origin.file, fmt.Sprintf("routes[%d]", origin.index) + path[len(match[0]):]
+		}
+	}
+
+	section, rest, _ := strings.Cut(path, ".")
+	name, _, _ := strings.Cut(rest, ".")
+	switch section {
+	case "destinations":
+		if file, ok := c.origins.destinations[name]; ok {
+			return file, path
+		}
+	case "variables":
+		if file, ok := c.origins.variables[name]; ok {
+			return file, path
+		}
+	}
+
+	return c.origins.files[0], path
+}
+
+// problemError names the file a problem is in
+func (c *Config) problemError(problem Problem) error {
+	file := problem.File
+	if file == "" {
+		file, _ = c.locate(problem.Path)
+	}
+	if file == "" {
+		return problem.Err
+	}
+	return fmt.Errorf("%s: %w", file, problem.Err)
+}
+
+// Files returns the files the configuration was loaded from
+func (c *Config) Files() []string {
+	if c.origins == nil {
+		return nil
+	}
+	return c.origins.files
+}
+
+// WatchedFiles returns the files a reload would read: those the config path
+// and includes expand to now, which may differ from the loaded ones when
+// files were added or removed, along with the loaded files and secret files
+func (c *Config) WatchedFiles() []string {
+	files := slices.Clone(c.secretFiles)
+	if c.origins != nil {
+		files = append(files, c.origins.files...)
+		for _, pattern := range c.origins.patterns {
+			matches, _ := expandPath(pattern)
+			files = append(files, matches...)
+		}
+	}
+
+	slices.Sort(files)
+	return slices.Compact(files)
+}
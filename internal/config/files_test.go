@@ -0,0 +1,189 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func routeFile(path, dest string) string {
+	return `
+routes:
+  - path: "` + path + `"
+    matchers:
+      - expr: "true"
+        to: ` + dest + `
+`
+}
+
+// loadedPaths returns the paths of the routes in order
+func loadedPaths(cfg *Config) []string {
+	var paths []string
+	for _, route := range cfg.Routes {
+		paths = append(paths, route.Path)
+	}
+	return paths
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "10-routes.yaml"), routeFile("/b", "echo"))
+	writeFile(t, filepath.Join(dir, "00-destinations.yml"), "destinations:\n  echo: \"http://localhost\"\n"+routeFile("/a", "echo"))
+	writeFile(t, filepath.Join(dir, ".hidden.yaml"), "not: [valid")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not: [valid")
+	writeFile(t, filepath.Join(dir, "nested.yaml", "inner.yaml"), routeFile("/nested", "echo"))
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := loadedPaths(cfg); !slices.Equal(got, []string{"/a", "/b"}) {
+		t.Errorf("routes = %v", got)
+	}
+	if files := cfg.Files(); len(files) != 2 || filepath.Base(files[0]) != "00-destinations.yml" {
+		t.Errorf("Files = %v", files)
+	}
+}
+
+func TestLoadGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "base.yaml"), "destinations:\n  echo: \"http://localhost\"\n")
+	writeFile(t, filepath.Join(dir, "team-a.yaml"), routeFile("/a", "echo"))
+	writeFile(t, filepath.Join(dir, "team-b.yaml"), routeFile("/b", "echo"))
+
+	cfg, err := LoadConfig(filepath.Join(dir, "team-*.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Destinations) != 0 || len(cfg.Files()) != 2 {
+		t.Errorf("glob loaded %v", cfg.Files())
+	}
+
+	cfg, err = LoadConfig(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loadedPaths(cfg); !slices.Equal(got, []string{"/a", "/b"}) {
+		t.Errorf("routes = %v", got)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "*.json")); err == nil || !strings.Contains(err.Error(), "no configuration files match") {
+		t.Errorf("glob matching nothing: %v", err)
+	}
+}
+
+func TestLoadIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), `
+include: teams/*.yaml
+variables:
+  environment: production
+destinations:
+  echo: "http://localhost"
+`+routeFile("/main", "echo"))
+	writeFile(t, filepath.Join(dir, "teams", "a.yaml"), `
+include:
+  - ../shared/destinations.yaml
+  - ../config.yaml
+`+routeFile("/a", "audit"))
+	writeFile(t, filepath.Join(dir, "teams", "b.yaml"), routeFile("/b", "echo"))
+	writeFile(t, filepath.Join(dir, "shared", "destinations.yaml"), `
+destinations:
+  audit: "http://localhost/audit"
+`+routeFile("/shared", "audit"))
+
+	cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Each file comes before its includes, and config.yaml isn't loaded twice
+	if got := loadedPaths(cfg); !slices.Equal(got, []string{"/main", "/a", "/shared", "/b"}) {
+		t.Errorf("routes = %v", got)
+	}
+	if _, ok := cfg.Destinations["audit"]; !ok || cfg.Variables["environment"] != "production" {
+		t.Errorf("destinations = %v, variables = %v", cfg.Destinations, cfg.Variables)
+	}
+}
+
+func TestLoadReportsDuplicatesWithTheirFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "variables:\n  env: prod\ndestinations:\n  alerts: \"http://localhost\"\n"+routeFile("/a", "alerts"))
+	writeFile(t, filepath.Join(dir, "b.yaml"), "destinations:\n  alerts: \"http://localhost/other\"\n")
+	writeFile(t, filepath.Join(dir, "c.yaml"), "variables:\n  env: dev\n")
+
+	_, err := LoadConfig(dir)
+	if err == nil {
+		t.Fatal("loaded duplicate names")
+	}
+	want := filepath.Join(dir, "b.yaml") + ": destinations.alerts: destination 'alerts' is already defined in " + filepath.Join(dir, "a.yaml")
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want it to mention %q", err, want)
+	}
+
+	diagnostics, err := Diagnose(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := findDiagnostic(t, diagnostics, "variables.env")
+	if d.File != filepath.Join(dir, "c.yaml") || d.Line != 2 || !strings.Contains(d.Message, "already defined in "+filepath.Join(dir, "a.yaml")) {
+		t.Errorf("variable diagnostic = %+v", d)
+	}
+}
+
+func TestLoadNamesTheFileOfAProblem(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: team.yaml\ndestinations:\n  echo: \"http://localhost\"\n"+routeFile("/a", "echo"))
+	writeFile(t, filepath.Join(dir, "team.yaml"), `
+routes:
+  - path: "/b"
+    matchers:
+      - expr: 'params.x =='
+        to: echo
+`)
+
+	_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "team.yaml")+": ") {
+		t.Errorf("error = %v, want it to name team.yaml", err)
+	}
+
+	diagnostics, err := Diagnose(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := findDiagnostic(t, diagnostics, "routes[0].matchers[0].expr")
+	if d.File != filepath.Join(dir, "team.yaml") || d.Line != 5 {
+		t.Errorf("diagnostic = %+v", d)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: missing/*.yaml\n"+routeFile("/a", "echo"))
+	if _, err := LoadConfig(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Errorf("include matching nothing failed: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: missing.yaml\n"+routeFile("/a", "echo"))
+	if _, err := LoadConfig(filepath.Join(dir, "config.yaml")); err == nil || !strings.Contains(err.Error(), "failed to include 'missing.yaml'") {
+		t.Errorf("missing include: %v", err)
+	}
+}
+
+func TestWatchedFilesNoticeAddedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "destinations:\n  echo: \"http://localhost\"\n"+routeFile("/a", "echo"))
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	added := filepath.Join(dir, "b.yaml")
+	writeFile(t, added, routeFile("/b", "echo"))
+
+	if files := cfg.WatchedFiles(); !slices.Contains(files, added) {
+		t.Errorf("WatchedFiles = %v, want it to include %s", files, added)
+	}
+	if files := cfg.Files(); slices.Contains(files, added) {
+		t.Errorf("Files = %v", files)
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// HTTPClientConfig overrides the HTTP client used to reach a destination.
+// Destinations with identical settings share a client.
+type HTTPClientConfig struct {
+	ConnectTimeout     time.Duration `yaml:"connect_timeout,omitempty" expr:"connect_timeout" json:"connect_timeout,omitempty"`                // Time to establish the TCP connection
+	ResponseTimeout    time.Duration `yaml:"response_timeout,omitempty" expr:"response_timeout" json:"response_timeout,omitempty"`             // Time to wait for response headers after sending the request
+	Timeout            time.Duration `yaml:"timeout,omitempty" expr:"timeout" json:"timeout,omitempty"`                                        // Total time for the request, default --timeout
+	CAFile             string        `yaml:"ca_file,omitempty" expr:"ca_file" json:"ca_file,omitempty"`                                        // PEM bundle of CAs to trust instead of the system roots
+	CertFile           string        `yaml:"cert_file,omitempty" expr:"cert_file" json:"cert_file,omitempty"`                                  // PEM client certificate for mTLS
+	KeyFile            string        `yaml:"key_file,omitempty" expr:"key_file" json:"key_file,omitempty"`                                     // PEM client key for mTLS
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify,omitempty" expr:"insecure_skip_verify" json:"insecure_skip_verify,omitempty"` // Don't verify the server certificate, for lab targets only
+	Proxy              string        `yaml:"proxy,omitempty" expr:"proxy" json:"proxy,omitempty"`                                              // http://, https://, socks5:// or socks5h:// proxy URL
+	HTTP2              *bool         `yaml:"http2,omitempty" expr:"http2" json:"http2,omitempty"`                                              // Default true
+	MaxConnsPerHost    int           `yaml:"max_conns_per_host,omitempty" expr:"max_conns_per_host" json:"max_conns_per_host,omitempty"`       // Default unlimited
+}
+
+func (h *HTTPClientConfig) Validate() error {
+	if h == nil {
+		return nil
+	}
+	if h.ConnectTimeout < 0 || h.ResponseTimeout < 0 || h.Timeout < 0 {
+		return fmt.Errorf("http timeouts must not be negative")
+	}
+	if (h.CertFile == "") != (h.KeyFile == "") {
+		return fmt.Errorf("http cert_file and key_file must be set together")
+	}
+	if h.MaxConnsPerHost < 0 {
+		return fmt.Errorf("http max_conns_per_host must not be negative")
+	}
+	if h.Proxy != "" {
+		u, err := url.Parse(h.Proxy)
+		if err != nil {
+			return fmt.Errorf("http proxy is not a valid URL: %w", err)
+		}
+		if !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) {
+			return fmt.Errorf("http proxy has unsupported scheme '%s'", u.Scheme)
+		}
+	}
+	return nil
+}
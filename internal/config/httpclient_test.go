@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func httpDestination(http string) string {
+	return `
+destinations:
+  echo:
+    url: "http://localhost"
+    http:
+` + http + `
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestHTTPClientValidation(t *testing.T) {
+	wantLoadError(t, httpDestination(`
+      timeout: -1s`), "must not be negative")
+	wantLoadError(t, httpDestination(`
+      cert_file: client.pem`), "must be set together")
+	wantLoadError(t, httpDestination(`
+      proxy: "ftp://proxy:21"`), "unsupported scheme 'ftp'")
+
+	if _, err := loadConfig(t, httpDestination(`
+      proxy: "socks5h://proxy:1080"
+      http2: false`)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/redact"
+	"gopkg.in/yaml.v3"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// resolution is what resolving a config document found
+type resolution struct {
+	secrets      map[string]string // Secret variable name to value
+	interpolated []string          // Environment values interpolated into secrets, headers, variables and URLs
+	files        []string          // Files secret variables were read from
+	problems     []Problem
+}
+
+// valueKind tells how sensitive environment values interpolated into a value are
+type valueKind int
+
+const (
+	plainValue  valueKind = iota
+	secretValue           // Field tagged secret, a header or a variable, kept out of logs
+	urlValue              // URL, kept out of logs unless the value is the host
+)
+
+// resolveNode expands ${NAME} and ${NAME:-default} in the string values of
+// the document with environment variables, then replaces secret variables,
+// given as {secret_file: path} or {secret_env: NAME}, with their values.
+// Relative secret files are looked up in dir.
+func resolveNode(root *yaml.Node, dir string) *resolution {
+	r := &resolution{secrets: make(map[string]string)}
+	r.interpolate(root, "", reflect.TypeOf(Config{}), plainValue)
+
+	if variables := mappingValue(root, "variables"); variables != nil && variables.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(variables.Content); i += 2 {
+			name, value := variables.Content[i].Value, variables.Content[i+1]
+			if value.Kind != yaml.MappingNode {
+				continue
+			}
+
+			secret, ok := r.resolveSecret(name, value, dir)
+			if ok {
+				r.secrets[name] = secret
+			}
+			// Secrets that failed to resolve become empty so the rest of the config can still be checked
+			*value = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: secret, Line: value.Line, Column: value.Column}
+		}
+	}
+
+	return r
+}
+
+// interpolate expands environment variables in the values under node, which
+// is decoded into t, nil where the config has no such key
+func (r *resolution) interpolate(node *yaml.Node, path string, t reflect.Type, kind valueKind) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == flexibleDestinationType {
+		t = destinationType
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			r.interpolate(child, path, t, kind)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			childType, childKind := mappingChild(t, key, kind)
+			r.interpolate(node.Content[i+1], joinPath(path, key), childType, childKind)
+		}
+	case yaml.SequenceNode:
+		var elem reflect.Type
+		if t != nil && t.Kind() == reflect.Slice {
+			elem = t.Elem()
+		}
+		for i, child := range node.Content {
+			r.interpolate(child, fmt.Sprintf("%s[%d]", path, i), elem, kind)
+		}
+	case yaml.ScalarNode:
+		value, substitutions, err := interpolateEnv(node.Value)
+		if err != nil {
+			r.problems = append(r.problems, Problem{Path: path, Err: err})
+			return
+		}
+		if value != node.Value {
+			node.Value = value
+			// Let unquoted values such as ${PORT} decode into numbers and booleans
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+
+		for _, sub := range substitutions {
+			if kind == secretValue || kind == urlValue && !inHost(value, sub.start, sub.end) {
+				r.interpolated = append(r.interpolated, value[sub.start:sub.end])
+			}
+		}
+	}
+}
+
+// mappingChild returns the type and kind of the value of key in a mapping
+// decoded into t. Everything under a secret field, a header or a variable,
+// which usually holds tokens, is secret.
+func mappingChild(t reflect.Type, key string, kind valueKind) (reflect.Type, valueKind) {
+	if t == nil {
+		return nil, kind
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem(), kind
+	case reflect.Struct:
+		field, ok := yamlFields(t)[key]
+		switch {
+		case !ok:
+			return nil, kind
+		case kind == secretValue || field.Tag.Get("secret") == "true" || key == "headers" || key == "variables":
+			return field.Type, secretValue
+		case key == "url":
+			return field.Type, urlValue
+		default:
+			return field.Type, plainValue
+		}
+	default:
+		return nil, kind
+	}
+}
+
+// inHost reports whether start..end of a URL lies within its host. Unlike
+// credentials, paths and queries, which often carry tokens, the host is safe
+// to log.
+func inHost(rawURL string, start, end int) bool {
+	authority := strings.Index(rawURL, "://")
+	if authority < 0 {
+		return false
+	}
+	authority += len("://")
+
+	hostEnd := len(rawURL)
+	if i := strings.IndexAny(rawURL[authority:], "/?#"); i >= 0 {
+		hostEnd = authority + i
+	}
+	hostStart := authority
+	if i := strings.LastIndex(rawURL[authority:hostEnd], "@"); i >= 0 {
+		hostStart = authority + i + 1
+	}
+
+	return start >= hostStart && end <= hostEnd
+}
+
+// substitution locates an environment value in an interpolated string
+type substitution struct {
+	start, end int
+}
+
+// interpolateEnv expands environment variable references in value and
+// returns where values from the environment ended up. A reference to an unset
+// variable without a default is an error, $${ is a literal ${.
+func interpolateEnv(value string) (string, []substitution, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil, nil
+	}
+
+	var b strings.Builder
+	var substitutions []substitution
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			b.WriteString(value)
+			return b.String(), substitutions, nil
+		}
+
+		if start > 0 && value[start-1] == '$' {
+			b.WriteString(value[:start-1])
+			b.WriteString("${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated environment variable reference in '%s'", value)
+		}
+		b.WriteString(value[:start])
+
+		reference := value[start+2 : start+end]
+		name, fallback, hasDefault := strings.Cut(reference, ":-")
+		if !envNamePattern.MatchString(name) {
+			return "", nil, fmt.Errorf("invalid environment variable reference '${%s}'", reference)
+		}
+
+		env, set := os.LookupEnv(name)
+		switch {
+		case env != "":
+			substitutions = append(substitutions, substitution{start: b.Len(), end: b.Len() + len(env)})
+			b.WriteString(env)
+		case hasDefault:
+			b.WriteString(fallback)
+		case !set:
+			return "", nil, fmt.Errorf("environment variable '%s' is not set", name)
+		}
+
+		value = value[start+end+1:]
+	}
+}
+
+// resolveSecret reads the value of a secret variable
+func (r *resolution) resolveSecret(name string, node *yaml.Node, dir string) (string, bool) {
+	path := joinPath("variables", name)
+
+	var file, env string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1].Value
+		switch key {
+		case "secret_file":
+			file = value
+		case "secret_env":
+			env = value
+		default:
+			r.problems = append(r.problems, Problem{Path: joinPath(path, key), Err: fmt.Errorf("unknown key '%s', variables take secret_file or secret_env", key)})
+			return "", false
+		}
+	}
+
+	var secret string
+	switch {
+	case file != "" && env != "":
+		r.problems = append(r.problems, Problem{Path: path, Err: fmt.Errorf("variable '%s' sets both secret_file and secret_env", name)})
+		return "", false
+	case file != "":
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			r.problems = append(r.problems, Problem{Path: joinPath(path, "secret_file"), Err: fmt.Errorf("failed to read secret for variable '%s': %w", name, err)})
+			return "", false
+		}
+		r.files = append(r.files, file)
+		// Files written by editors and echo end with a newline that isn't part of the secret
+		secret = strings.TrimRight(string(data), "\r\n")
+	case env != "":
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			r.problems = append(r.problems, Problem{Path: joinPath(path, "secret_env"), Err: fmt.Errorf("environment variable '%s' for variable '%s' is not set", env, name)})
+			return "", false
+		}
+		secret = value
+	default:
+		r.problems = append(r.problems, Problem{Path: path, Err: fmt.Errorf("variable '%s' must be a string or set secret_file or secret_env", name)})
+		return "", false
+	}
+
+	return secret, true
+}
+
+// mappingValue returns the value of key in the top-level mapping of the document
+func mappingValue(root *yaml.Node, key string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// Secrets returns the values of the secret variables and the environment
+// values interpolated into secrets, headers, variables and URLs
+func (c *Config) Secrets() []string {
+	return append(slices.Collect(maps.Values(c.secrets)), c.interpolated...)
+}
+
+// SecretFiles returns the files secret variables are read from
+func (c *Config) SecretFiles() []string {
+	return c.secretFiles
+}
+
+// Redacted returns a copy of the config with the values of secret variables
+// and of secrets interpolated from the environment replaced, for exposing the
+// config to expressions
+func (c *Config) Redacted() Config {
+	if c.redacted != nil {
+		return *c.redacted
+	}
+
+	redacted := *c
+	if len(c.secrets) > 0 {
+		redacted.Variables = maps.Clone(c.Variables)
+		for name := range c.secrets {
+			redacted.Variables[name] = redact.Placeholder
+		}
+	}
+
+	if len(c.interpolated) > 0 {
+		var secrets redact.Secrets
+		secrets.Set(c.interpolated)
+		redacted.Variables = redactValue(reflect.ValueOf(redacted.Variables), &secrets).Interface().(map[string]string)
+		redacted.Destinations = redactValue(reflect.ValueOf(c.Destinations), &secrets).Interface().(map[string]FlexibleDestination)
+		redacted.Routes = redactValue(reflect.ValueOf(c.Routes), &secrets).Interface().([]Route)
+	}
+
+	return redacted
+}
+
+// redactValue returns a deep copy of v with the secrets replaced in every string
+func redactValue(v reflect.Value, secrets *redact.Secrets) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(secrets.Redact(v.String())).Convert(v.Type())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(redactValue(v.Elem(), secrets))
+		return copied
+	case reflect.Struct:
+		// Copy unexported fields such as compiled expressions as they are
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				copied.Field(i).Set(redactValue(v.Field(i), secrets))
+			}
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), redactValue(iter.Value(), secrets))
+		}
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactValue(v.Index(i), secrets))
+		}
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"slices"
+	"time"
+)
+
+const (
+	RateLimitByRoute = "route"
+	RateLimitByIP    = "ip"
+	RateLimitByKey   = "key"
+)
+
+// RateLimitConfig limits how often a route accepts requests. A request must
+// pass every limit, otherwise it is answered with Status and Retry-After.
+type RateLimitConfig struct {
+	Limits []*RateLimit `yaml:"limits" expr:"limits"`
+	Status int          `yaml:"status,omitempty" expr:"status"` // Default 429 Too Many Requests
+	Body   string       `yaml:"body,omitempty" expr:"body"`     // Response body template, default a JSON error
+}
+
+// RateLimit is a token bucket refilled with Requests tokens every Per and
+// holding up to Burst tokens
+type RateLimit struct {
+	Requests int           `yaml:"requests" expr:"requests"`
+	Per      time.Duration `yaml:"per,omitempty" expr:"per"`     // Default 1s
+	Burst    int           `yaml:"burst,omitempty" expr:"burst"` // Default Requests
+	By       string        `yaml:"by,omitempty" expr:"by"`       // route (default), ip or key
+	Key      string        `yaml:"key,omitempty" expr:"key"`     // Expression computing the bucket key when by is key
+
+	program *vm.Program `yaml:"-"` // Compiled key expression
+	reads   keyReads    `yaml:"-"` // What the key expression reads
+}
+
+func (c *RateLimitConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Limits) == 0 {
+		return fmt.Errorf("rate_limit has no limits")
+	}
+	if c.Status != 0 && (c.Status < 400 || c.Status > 599) {
+		return fmt.Errorf("rate_limit status must be a 4xx or 5xx code")
+	}
+	for i, limit := range c.Limits {
+		if limit.Requests <= 0 {
+			return fmt.Errorf("rate_limit %d requests must be positive", i)
+		}
+		if limit.Per < 0 || limit.Burst < 0 {
+			return fmt.Errorf("rate_limit %d per and burst must not be negative", i)
+		}
+		switch limit.By {
+		case "", RateLimitByRoute, RateLimitByIP:
+		case RateLimitByKey:
+			if limit.Key == "" {
+				return fmt.Errorf("rate_limit %d by key needs a key expression", i)
+			}
+		default:
+			return fmt.Errorf("rate_limit %d has unknown by '%s'", i, limit.By)
+		}
+	}
+	return nil
+}
+
+// GetStatus returns the status code for rejected requests
+func (c *RateLimitConfig) GetStatus() int {
+	if c.Status == 0 {
+		return 429
+	}
+	return c.Status
+}
+
+// GetBy returns what the limit is counted by
+func (l *RateLimit) GetBy() string {
+	if l.By == "" {
+		return RateLimitByRoute
+	}
+	return l.By
+}
+
+// RatePerSecond returns the refill rate of the bucket
+func (l *RateLimit) RatePerSecond() float64 {
+	per := l.Per
+	if per == 0 {
+		per = time.Second
+	}
+	return float64(l.Requests) / per.Seconds()
+}
+
+// GetBurst returns the bucket capacity
+func (l *RateLimit) GetBurst() int {
+	if l.Burst == 0 {
+		return l.Requests
+	}
+	return l.Burst
+}
+
+// CompileKey compiles the key expression of a by key limit
+func (l *RateLimit) CompileKey() error {
+	if l.GetBy() != RateLimitByKey {
+		return nil
+	}
+
+	program, err := compileExpression(l.Key)
+	if err != nil {
+		return fmt.Errorf("failed to compile key expression '%s': %w", l.Key, err)
+	}
+	l.program = program
+
+	l.reads = keyReads{}
+	node := program.Node()
+	ast.Walk(&node, &l.reads)
+
+	return nil
+}
+
+// ReadsAuth reports whether the key expression reads the authenticated caller
+func (l *RateLimit) ReadsAuth() bool {
+	return l.reads.auth
+}
+
+// AfterAuth reports whether the limit can only be checked once the body was
+// read and the caller authenticated, because its key reads either of them
+func (l *RateLimit) AfterAuth() bool {
+	return l.reads.auth || l.reads.body
+}
+
+// keyReads records whether an expression reads auth or the request body
+type keyReads struct {
+	auth bool
+	body bool
+}
+
+func (r *keyReads) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		if n.Value == "auth" {
+			r.auth = true
+		}
+	case *ast.MemberNode:
+		object, ok := n.Node.(*ast.IdentifierNode)
+		property, isString := n.Property.(*ast.StringNode)
+		if ok && isString && object.Value == "request" && slices.Contains([]string{"body", "json", "form", "JSON", "Form"}, property.Value) {
+			r.body = true
+		}
+	}
+}
+
+// EvaluateKey computes the bucket key for a by key limit
+func (l *RateLimit) EvaluateKey(env *MatcherEnv) (string, error) {
+	if l.program == nil {
+		return "", fmt.Errorf("no compiled key expression available for rate limit")
+	}
+
+	result, err := expr.Run(l.program, env)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate key expression: %w", err)
+	}
+	if result == nil {
+		return "", nil
+	}
+
+	return fmt.Sprint(result), nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func rateLimitRoute(auth, limits string) string {
+	return `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook/{tenant}"
+` + auth + `
+    rate_limit:
+      limits:
+` + limits + `
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+const bearerAuth = `
+    auth:
+      bearer:
+        tokens:
+          ci: "t0ken"`
+
+func TestRateLimitValidation(t *testing.T) {
+	wantLoadError(t, rateLimitRoute("", `
+        - requests: 0`), "requests must be positive")
+	wantLoadError(t, rateLimitRoute("", `
+        - requests: 1
+          by: key`), "needs a key expression")
+	wantLoadError(t, rateLimitRoute("", `
+        - requests: 1
+          by: user`), "unknown by 'user'")
+	wantLoadError(t, rateLimitRoute("", `
+        - requests: 1
+          by: key
+          key: 'params.tenant +'`), "failed to compile key expression")
+	wantLoadError(t, rateLimitRoute("", `
+        - requests: 1
+          by: key
+          key: auth.subject`), "key reads auth but the route has no auth")
+}
+
+func TestRateLimitKeyPlacement(t *testing.T) {
+	cfg, err := loadConfig(t, rateLimitRoute(bearerAuth, `
+        - requests: 10
+          per: 1m
+        - requests: 1
+          by: key
+          key: params.tenant
+        - requests: 1
+          by: key
+          key: auth.subject
+        - requests: 1
+          by: key
+          key: 'fromJSON(request.body).org'
+        - requests: 1
+          by: key
+          key: 'request.json?.org ?? ""'`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limits := cfg.Routes[0].RateLimit.Limits
+	for i, want := range []struct{ readsAuth, afterAuth bool }{
+		{false, false},
+		{false, false},
+		{true, true},
+		{false, true},
+		{false, true},
+	} {
+		if limits[i].ReadsAuth() != want.readsAuth || limits[i].AfterAuth() != want.afterAuth {
+			t.Errorf("limit %d (%s): ReadsAuth %v, AfterAuth %v", i, limits[i].Key, limits[i].ReadsAuth(), limits[i].AfterAuth())
+		}
+	}
+
+	if rate := limits[0].RatePerSecond(); rate != 10/time.Minute.Seconds() || limits[0].GetBurst() != 10 {
+		t.Errorf("rate %v, burst %d", rate, limits[0].GetBurst())
+	}
+	if cfg.Routes[0].RateLimit.GetStatus() != 429 {
+		t.Errorf("default status %d", cfg.Routes[0].RateLimit.GetStatus())
+	}
+}
+
+func TestRateLimitEvaluateKey(t *testing.T) {
+	cfg, err := loadConfig(t, rateLimitRoute("", `
+        - requests: 1
+          by: key
+          key: params.tenant`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := cfg.Routes[0].RateLimit.Limits[0].EvaluateKey(&MatcherEnv{Params: map[string]string{"tenant": "acme"}})
+	if err != nil || key != "acme" {
+		t.Errorf("EvaluateKey = %q, %v", key, err)
+	}
+}
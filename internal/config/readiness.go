@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReadinessConfig makes the server's readiness depend on a named destination,
+// through an active probe, the ratio of recent successful deliveries or both
+type ReadinessConfig struct {
+	Probe           *ProbeConfig  `yaml:"probe,omitempty" expr:"probe"`
+	MinSuccessRatio float64       `yaml:"min_success_ratio,omitempty" expr:"min_success_ratio"` // Not ready below this ratio of successful deliveries in the window, 0 only reports it
+	Window          time.Duration `yaml:"window,omitempty" expr:"window"`                       // Default 5m
+	MinRequests     int           `yaml:"min_requests,omitempty" expr:"min_requests"`           // Deliveries needed in the window before the ratio is considered, default 10
+}
+
+// ProbeConfig checks a destination with a request of its own every interval
+type ProbeConfig struct {
+	URL            string        `yaml:"url,omitempty" expr:"url"`                         // Default the destination URL
+	Method         string        `yaml:"method,omitempty" expr:"method"`                   // HEAD (default) or GET
+	ExpectedStatus []int         `yaml:"expected_status,omitempty" expr:"expected_status"` // Default any 2xx
+	Interval       time.Duration `yaml:"interval,omitempty" expr:"interval"`               // Default 30s
+	Timeout        time.Duration `yaml:"timeout,omitempty" expr:"timeout"`                 // Default 5s
+}
+
+// DefaultReadinessWindow is how far back success ratios of destinations
+// without a readiness config are counted
+const DefaultReadinessWindow = 5 * time.Minute
+
+func (r *ReadinessConfig) Validate(destinationURL string) error {
+	if r == nil {
+		return nil
+	}
+	if r.MinSuccessRatio < 0 || r.MinSuccessRatio > 1 {
+		return fmt.Errorf("readiness min_success_ratio must be between 0 and 1")
+	}
+	if r.Window < 0 || r.MinRequests < 0 {
+		return fmt.Errorf("readiness window and min_requests must not be negative")
+	}
+
+	p := r.Probe
+	if p == nil {
+		return nil
+	}
+	if p.URL == "" && strings.Contains(destinationURL, "{{") {
+		return fmt.Errorf("readiness probe needs a url when the destination url is a template")
+	}
+	if p.Method != "" && !slices.Contains([]string{"HEAD", "GET"}, strings.ToUpper(p.Method)) {
+		return fmt.Errorf("readiness probe method must be HEAD or GET")
+	}
+	for _, status := range p.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("readiness probe has invalid expected_status %d", status)
+		}
+	}
+	if p.Interval < 0 || p.Timeout < 0 {
+		return fmt.Errorf("readiness probe durations must not be negative")
+	}
+	return nil
+}
+
+// GetWindow returns how far back deliveries count towards the success ratio
+func (r *ReadinessConfig) GetWindow() time.Duration {
+	if r == nil || r.Window == 0 {
+		return DefaultReadinessWindow
+	}
+	return r.Window
+}
+
+// GetMinRequests returns the deliveries needed before the ratio is considered
+func (r *ReadinessConfig) GetMinRequests() int {
+	if r == nil || r.MinRequests == 0 {
+		return 10
+	}
+	return r.MinRequests
+}
+
+// GetMethod returns the probe's request method
+func (p *ProbeConfig) GetMethod() string {
+	if p.Method == "" {
+		return "HEAD"
+	}
+	return strings.ToUpper(p.Method)
+}
+
+// GetInterval returns the time between probes
+func (p *ProbeConfig) GetInterval() time.Duration {
+	if p.Interval == 0 {
+		return 30 * time.Second
+	}
+	return p.Interval
+}
+
+// GetTimeout returns how long a probe may take
+func (p *ProbeConfig) GetTimeout() time.Duration {
+	if p.Timeout == 0 {
+		return 5 * time.Second
+	}
+	return p.Timeout
+}
+
+// Expects reports whether a probe response status counts as healthy
+func (p *ProbeConfig) Expects(status int) bool {
+	if len(p.ExpectedStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(p.ExpectedStatus, status)
+}
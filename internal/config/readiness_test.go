@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func readinessConfig(readiness string) string {
+	return `
+destinations:
+  echo:
+    url: "http://localhost/{{.params.name}}"
+    readiness:
+` + readiness + `
+routes:
+  - path: "/hook/{name}"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestReadinessDefaults(t *testing.T) {
+	cfg, err := loadConfig(t, readinessConfig(`
+      min_success_ratio: 0.9
+      probe:
+        url: "http://localhost/health"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readiness := cfg.Destinations["echo"].Readiness
+	if readiness.GetWindow() != DefaultReadinessWindow || readiness.GetMinRequests() != 10 {
+		t.Errorf("window = %s, min requests = %d", readiness.GetWindow(), readiness.GetMinRequests())
+	}
+
+	probe := readiness.Probe
+	if probe.GetMethod() != "HEAD" || probe.GetInterval() != 30*time.Second || probe.GetTimeout() != 5*time.Second {
+		t.Errorf("probe = %s %s %s", probe.GetMethod(), probe.GetInterval(), probe.GetTimeout())
+	}
+	if !probe.Expects(204) || probe.Expects(301) {
+		t.Error("default probe doesn't expect any 2xx")
+	}
+
+	var none *ReadinessConfig
+	if none.GetWindow() != DefaultReadinessWindow {
+		t.Errorf("nil window = %s", none.GetWindow())
+	}
+}
+
+func TestProbeExpectedStatus(t *testing.T) {
+	probe := &ProbeConfig{Method: "get", ExpectedStatus: []int{200, 401}}
+
+	if !probe.Expects(401) || probe.Expects(204) {
+		t.Error("expected_status isn't used")
+	}
+	if probe.GetMethod() != "GET" {
+		t.Errorf("method = %s", probe.GetMethod())
+	}
+}
+
+func TestReadinessValidation(t *testing.T) {
+	for _, tc := range []struct {
+		readiness string
+		want      string
+	}{
+		{"      min_success_ratio: 1.5", "min_success_ratio must be between 0 and 1"},
+		{"      window: -1s", "must not be negative"},
+		{"      probe:\n        interval: 10s", "needs a url when the destination url is a template"},
+		{"      probe:\n        url: http://localhost\n        method: POST", "method must be HEAD or GET"},
+		{"      probe:\n        url: http://localhost\n        expected_status: [99]", "invalid expected_status 99"},
+		{"      probe:\n        url: http://localhost\n        timeout: -1s", "durations must not be negative"},
+	} {
+		wantLoadError(t, readinessConfig(tc.readiness), tc.want)
+	}
+}
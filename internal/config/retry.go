@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// RetryPolicy controls how failed forwards to a destination are retried
+type RetryPolicy struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty" expr:"max_attempts" json:"max_attempts,omitempty"`          // Default 5, including the first attempt
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty" expr:"initial_backoff" json:"initial_backoff,omitempty"` // Default 1s
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty" expr:"max_backoff" json:"max_backoff,omitempty"`             // Default 5m
+	Jitter         *float64      `yaml:"jitter,omitempty" expr:"jitter" json:"jitter,omitempty"`                            // Fraction of the backoff to randomize, default 0.2, 0 disables
+	RetryOn        []int         `yaml:"retry_on,omitempty" expr:"retry_on" json:"retry_on,omitempty"`                      // Status codes to retry, network errors are always retried
+}
+
+func (p *RetryPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts must not be negative")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	if p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1) {
+		return fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// GetMaxAttempts returns the total number of attempts including the first one
+func (p *RetryPolicy) GetMaxAttempts() int {
+	if p == nil {
+		return 1
+	}
+	if p.MaxAttempts == 0 {
+		return 5
+	}
+	return p.MaxAttempts
+}
+
+// ShouldRetry reports whether a failed attempt with the given status code
+// should be retried. A status code of 0 means the request never got a response.
+func (p *RetryPolicy) ShouldRetry(statusCode int) bool {
+	if p == nil {
+		return false
+	}
+	if statusCode == 0 {
+		return true
+	}
+	if len(p.RetryOn) == 0 {
+		return slices.Contains([]int{408, 425, 429, 500, 502, 503, 504}, statusCode)
+	}
+	return slices.Contains(p.RetryOn, statusCode)
+}
+
+// Backoff returns the delay before the given retry attempt (1 for the first retry)
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	initial := 1 * time.Second
+	maxBackoff := 5 * time.Minute
+	jitter := 0.2
+	if p != nil {
+		if p.InitialBackoff > 0 {
+			initial = p.InitialBackoff
+		}
+		if p.MaxBackoff > 0 {
+			maxBackoff = p.MaxBackoff
+		}
+		if p.Jitter != nil {
+			jitter = *p.Jitter
+		}
+	}
+
+	backoff := initial
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	// Spread retries by +/- jitter of the backoff
+	delta := (rand.Float64()*2 - 1) * jitter * float64(backoff)
+
+	return backoff + time.Duration(delta)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	jitter := 0.0
+	p := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Jitter: &jitter}
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	var p *RetryPolicy
+	for range 100 {
+		if got := p.Backoff(1); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("default jitter: Backoff(1) = %s", got)
+		}
+	}
+
+	jitter := 0.5
+	p = &RetryPolicy{InitialBackoff: time.Second, Jitter: &jitter}
+	spread := false
+	for range 100 {
+		got := p.Backoff(1)
+		if got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("Backoff(1) = %s", got)
+		}
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			spread = true
+		}
+	}
+	if !spread {
+		t.Error("jitter 0.5 never spread beyond 20%")
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	var none *RetryPolicy
+	if none.ShouldRetry(0) || none.ShouldRetry(503) {
+		t.Error("nil policy retries")
+	}
+
+	defaults := &RetryPolicy{}
+	for status, want := range map[int]bool{0: true, 429: true, 503: true, 400: false, 404: false} {
+		if got := defaults.ShouldRetry(status); got != want {
+			t.Errorf("default ShouldRetry(%d) = %v", status, got)
+		}
+	}
+
+	custom := &RetryPolicy{RetryOn: []int{409}}
+	if !custom.ShouldRetry(409) || custom.ShouldRetry(503) || !custom.ShouldRetry(0) {
+		t.Error("retry_on not honored")
+	}
+}
+
+func TestRetryPolicyDefaultsAndValidation(t *testing.T) {
+	var none *RetryPolicy
+	if none.GetMaxAttempts() != 1 {
+		t.Errorf("nil policy max attempts = %d", none.GetMaxAttempts())
+	}
+	if (&RetryPolicy{}).GetMaxAttempts() != 5 {
+		t.Errorf("default max attempts = %d", (&RetryPolicy{}).GetMaxAttempts())
+	}
+
+	tooMuch := 1.5
+	for _, p := range []*RetryPolicy{{MaxAttempts: -1}, {InitialBackoff: -time.Second}, {Jitter: &tooMuch}} {
+		if p.Validate() == nil {
+			t.Errorf("Validate accepted %+v", p)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/redact"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Sanitized returns the config as maps and lists keyed like the YAML, for
+// showing it. The values of secret variables and of fields tagged secret,
+// such as signing secrets and API keys, are redacted and empty fields left out.
+func (c *Config) Sanitized() map[string]any {
+	doc, _ := sanitize(reflect.ValueOf(c.Redacted()), false).(map[string]any)
+	return doc
+}
+
+func sanitize(v reflect.Value, secret bool) any {
+	if v.Type() == durationType {
+		return v.Interface().(time.Duration).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return sanitize(v.Elem(), secret)
+	case reflect.Struct:
+		fields := make(map[string]any)
+		sanitizeFields(v, fields)
+		return fields
+	case reflect.Map:
+		entries := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			entries[fmt.Sprint(iter.Key().Interface())] = sanitize(iter.Value(), secret)
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = sanitize(v.Index(i), secret)
+		}
+		return items
+	case reflect.String:
+		if secret && v.String() != "" {
+			return redact.Placeholder
+		}
+		return v.String()
+	default:
+		return v.Interface()
+	}
+}
+
+func sanitizeFields(v reflect.Value, fields map[string]any) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		if !field.IsExported() || value.IsZero() {
+			continue
+		}
+
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if strings.Contains(options, "inline") {
+			for value.Kind() == reflect.Pointer {
+				value = value.Elem()
+			}
+			sanitizeFields(value, fields)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fields[name] = sanitize(value, field.Tag.Get("secret") == "true")
+	}
+}
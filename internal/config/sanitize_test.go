@@ -0,0 +1,79 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizedRedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "key"), "file-secret")
+	t.Setenv("WEBHOOK_TOKEN", "env-secret")
+
+	cfg, err := loadConfig(t, `
+variables:
+  region: eu
+  key:
+    secret_file: `+filepath.Join(dir, "key")+`
+destinations:
+  echo:
+    url: "http://localhost/{{.var.region}}"
+    retry:
+      initial_backoff: 1500ms
+    signing:
+      secret: "{{.var.key}}"
+routes:
+  - path: "/hook"
+    auth:
+      bearer:
+        tokens:
+          ci: "${WEBHOOK_TOKEN}"
+    matchers:
+      - expr: "true"
+        to: echo
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := cfg.Sanitized()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"file-secret", "env-secret", "{{.var.key}}"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("sanitized config shows %q: %s", secret, data)
+		}
+	}
+
+	variables := doc["variables"].(map[string]any)
+	if variables["region"] != "eu" || variables["key"] != "[REDACTED]" {
+		t.Errorf("variables = %v", variables)
+	}
+
+	echo := doc["destinations"].(map[string]any)["echo"].(map[string]any)
+	if echo["url"] != "http://localhost/{{.var.region}}" {
+		t.Errorf("url = %v", echo["url"])
+	}
+	if signing := echo["signing"].(map[string]any); signing["secret"] != "[REDACTED]" {
+		t.Errorf("signing = %v", signing)
+	}
+	if retry := echo["retry"].(map[string]any); retry["initial_backoff"] != "1.5s" {
+		t.Errorf("retry = %v", retry)
+	}
+	if _, ok := echo["headers"]; ok {
+		t.Error("empty headers aren't left out")
+	}
+
+	route := doc["routes"].([]any)[0].(map[string]any)
+	tokens := route["auth"].(map[string]any)["bearer"].(map[string]any)["tokens"].(map[string]any)
+	if tokens["ci"] != "[REDACTED]" {
+		t.Errorf("tokens = %v", tokens)
+	}
+	if route["path"] != "/hook" {
+		t.Errorf("route = %v", route)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	SigningSchemeCustom           = "custom"
+	SigningSchemeStandardWebhooks = "standard-webhooks"
+	SigningSchemeGitHub           = "github"
+
+	SigningAlgorithmHMACSHA256 = "hmac-sha256"
+	SigningAlgorithmHMACSHA512 = "hmac-sha512"
+	SigningAlgorithmEd25519    = "ed25519"
+)
+
+// SigningConfig describes how outbound requests to a destination are signed
+type SigningConfig struct {
+	Scheme          string `yaml:"scheme,omitempty" expr:"scheme" json:"scheme,omitempty"`                               // custom (default), standard-webhooks or github
+	Algorithm       string `yaml:"algorithm,omitempty" expr:"algorithm" json:"algorithm,omitempty"`                      // hmac-sha256 (default), hmac-sha512 or ed25519
+	Secret          string `yaml:"secret" expr:"-" json:"-" secret:"true"`                                               // HMAC secret or base64 Ed25519 private key, may use templates such as {{.var.signing_key}}
+	Header          string `yaml:"header,omitempty" expr:"header" json:"header,omitempty"`                               // Signature header (custom), default X-Signature
+	Prefix          string `yaml:"prefix,omitempty" expr:"prefix" json:"prefix,omitempty"`                               // Prefix in front of the signature such as "sha256=" (custom)
+	Encoding        string `yaml:"encoding,omitempty" expr:"encoding" json:"encoding,omitempty"`                         // hex (default) or base64 (custom)
+	TimestampHeader string `yaml:"timestamp_header,omitempty" expr:"timestamp_header" json:"timestamp_header,omitempty"` // Sign and send the unix timestamp in this header (custom)
+	IDHeader        string `yaml:"id_header,omitempty" expr:"id_header" json:"id_header,omitempty"`                      // Sign and send the delivery ID in this header (custom)
+}
+
+func (s *SigningConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Secret == "" {
+		return fmt.Errorf("signing has no secret")
+	}
+	if !slices.Contains([]string{"", SigningSchemeCustom, SigningSchemeStandardWebhooks, SigningSchemeGitHub}, s.Scheme) {
+		return fmt.Errorf("signing has unknown scheme '%s'", s.Scheme)
+	}
+	if !slices.Contains([]string{"", SigningAlgorithmHMACSHA256, SigningAlgorithmHMACSHA512, SigningAlgorithmEd25519}, s.Algorithm) {
+		return fmt.Errorf("signing has unknown algorithm '%s'", s.Algorithm)
+	}
+	if s.Scheme == SigningSchemeGitHub && s.Algorithm != "" && s.Algorithm != SigningAlgorithmHMACSHA256 {
+		return fmt.Errorf("signing scheme github only supports %s", SigningAlgorithmHMACSHA256)
+	}
+	if s.Scheme == SigningSchemeStandardWebhooks && s.Algorithm == SigningAlgorithmHMACSHA512 {
+		return fmt.Errorf("signing scheme standard-webhooks does not support %s", SigningAlgorithmHMACSHA512)
+	}
+	if s.Encoding != "" && s.Encoding != "hex" && s.Encoding != "base64" {
+		return fmt.Errorf("signing has unknown encoding '%s'", s.Encoding)
+	}
+	return nil
+}
+
+// GetAlgorithm returns the signing algorithm, defaulting to HMAC-SHA256
+func (s *SigningConfig) GetAlgorithm() string {
+	if s.Algorithm == "" {
+		return SigningAlgorithmHMACSHA256
+	}
+	return s.Algorithm
+}
+
+// SigningAt returns the signing config at ref, a path such as
+// destinations.NAME.signing, or nil if there is none
+func (c *Config) SigningAt(ref string) *SigningConfig {
+	return c.signings[ref]
+}
+
+// SigningRef returns the path of the signing config of an inline destination
+func (ref *DestinationRef) SigningRef() string {
+	return ref.signingRef
+}
@@ -0,0 +1,63 @@
+package config
+
+import "testing"
+
+func signingDestination(signing string) string {
+	return `
+destinations:
+  echo:
+    url: "http://localhost"
+    signing:
+` + signing + `
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestSigningValidation(t *testing.T) {
+	wantLoadError(t, signingDestination(`
+      scheme: github`), "signing has no secret")
+	wantLoadError(t, signingDestination(`
+      scheme: github
+      algorithm: ed25519
+      secret: x`), "only supports hmac-sha256")
+	wantLoadError(t, signingDestination(`
+      scheme: standard-webhooks
+      algorithm: hmac-sha512
+      secret: x`), "does not support hmac-sha512")
+	wantLoadError(t, signingDestination(`
+      secret: "{{.var.missing}}"`), "missing")
+}
+
+func TestSigningAtResolvesRefs(t *testing.T) {
+	cfg, err := loadConfig(t, `
+destinations:
+  echo:
+    url: "http://localhost"
+    signing:
+      secret: named
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to:
+          - echo
+          - url: "http://localhost/inline"
+            signing:
+              secret: inline
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := cfg.SigningAt("destinations.echo.signing"); s == nil || s.Secret != "named" {
+		t.Errorf("named signing = %+v", s)
+	}
+	ref := cfg.Routes[0].Matchers[0].To[1].SigningRef()
+	if s := cfg.SigningAt(ref); s == nil || s.Secret != "inline" {
+		t.Errorf("inline signing at %q = %+v", ref, s)
+	}
+}
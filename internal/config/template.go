@@ -0,0 +1,312 @@
+package config
+
+import (
+	"fmt"
+	wmSprout "github.com/framjet/go-webhook-middleman/internal/sprout"
+	"maps"
+	"slices"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateCache holds the parsed templates of a config keyed by their source,
+// so each template is parsed once when the config is compiled. It is not
+// modified afterwards and safe for concurrent use.
+type TemplateCache struct {
+	templates map[string]*template.Template
+	secrets   map[string]*template.Template // Parsed with missingkey=error
+}
+
+// ParseTemplate parses a template with the template functions
+func ParseTemplate(src string) (*template.Template, error) {
+	t, err := template.New("webhook").Funcs(wmSprout.FuncMap()).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("template parse error: %w", err)
+	}
+	return t, nil
+}
+
+// ParseSecretTemplate parses a template for a secret, where referencing a
+// missing key is an error rather than rendering "<no value>"
+func ParseSecretTemplate(src string) (*template.Template, error) {
+	t, err := ParseTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	return t.Option("missingkey=error"), nil
+}
+
+// Get returns the parsed template for src. Sources that weren't part of the
+// config when it was compiled are parsed on every call.
+func (c *TemplateCache) Get(src string) (*template.Template, error) {
+	if c != nil {
+		if t, ok := c.templates[src]; ok {
+			return t, nil
+		}
+	}
+	return ParseTemplate(src)
+}
+
+// GetSecret returns the parsed secret template for src, see ParseSecretTemplate
+func (c *TemplateCache) GetSecret(src string) (*template.Template, error) {
+	if c != nil {
+		if t, ok := c.secrets[src]; ok {
+			return t, nil
+		}
+	}
+	return ParseSecretTemplate(src)
+}
+
+func (c *TemplateCache) add(src string) error {
+	if _, ok := c.templates[src]; ok {
+		return nil
+	}
+
+	t, err := ParseTemplate(src)
+	if err != nil {
+		return err
+	}
+	c.templates[src] = t
+
+	return nil
+}
+
+// templateCompiler fills a cache and collects the templates that fail to parse
+type templateCompiler struct {
+	cache     *TemplateCache
+	variables map[string]string
+	problems  []Problem
+}
+
+// add parses src, path locates it in the config and what describes it in errors
+func (tc *templateCompiler) add(path, what, src string) {
+	if err := tc.cache.add(src); err != nil {
+		tc.problems = append(tc.problems, Problem{Path: path, Err: fmt.Errorf("%s: %w", what, err)})
+	}
+}
+
+// addSecret parses the template of a secret like add and makes sure the
+// variables it references exist, as a secret rendered from a missing
+// variable would be empty or guessable
+func (tc *templateCompiler) addSecret(path, what, src string) {
+	if _, ok := tc.cache.secrets[src]; !ok {
+		t, err := ParseSecretTemplate(src)
+		if err != nil {
+			tc.problems = append(tc.problems, Problem{Path: path, Err: fmt.Errorf("%s: %w", what, err)})
+			return
+		}
+		tc.cache.secrets[src] = t
+	}
+
+	t := tc.cache.secrets[src]
+	for _, name := range referencedVariables(t.Tree.Root) {
+		if _, ok := tc.variables[name]; !ok {
+			tc.problems = append(tc.problems, Problem{Path: path, Err: fmt.Errorf("%s: undefined variable '%s'", what, name)})
+		}
+	}
+}
+
+// compileTemplates parses every template in the config and returns the ones
+// that failed
+func (c *Config) compileTemplates() []Problem {
+	tc := &templateCompiler{
+		cache: &TemplateCache{
+			templates: make(map[string]*template.Template),
+			secrets:   make(map[string]*template.Template),
+		},
+		variables: c.Variables,
+	}
+	c.signings = make(map[string]*SigningConfig)
+
+	for _, name := range slices.Sorted(maps.Keys(c.Destinations)) {
+		dest := c.Destinations[name]
+		path := "destinations." + name
+		what := "destination " + name
+
+		tc.add(path+".url", what+" url", dest.URL)
+		tc.add(path+".body", what+" body", dest.Body)
+		if dest.Signing != nil {
+			tc.addSecret(path+".signing.secret", what+" signing secret", dest.Signing.Secret)
+			c.signings[path+".signing"] = dest.Signing
+		}
+	}
+
+	for routeIndex, route := range c.Routes {
+		route.compileTemplates(tc, fmt.Sprintf("routes[%d]", routeIndex), fmt.Sprintf("route %d", routeIndex))
+
+		for matcherIndex, matcher := range route.Matchers {
+			for refIndex := range matcher.To {
+				ref := &matcher.To[refIndex]
+				path := fmt.Sprintf("routes[%d].matchers[%d].to[%d]", routeIndex, matcherIndex, refIndex)
+				ref.compileTemplates(tc, path, fmt.Sprintf("route %d matcher %d destination %d", routeIndex, matcherIndex, refIndex))
+				if ref.Signing != nil {
+					ref.signingRef = path + ".signing"
+					c.signings[ref.signingRef] = ref.Signing
+				}
+			}
+		}
+	}
+
+	c.templates = tc.cache
+
+	return tc.problems
+}
+
+func (r *Route) compileTemplates(tc *templateCompiler, path, what string) {
+	if r.Response != nil {
+		if r.Response.Headers != nil {
+			for _, header := range slices.Sorted(maps.Keys(*r.Response.Headers)) {
+				tc.add(path+".response.headers."+header, what+" response header "+header, (*r.Response.Headers)[header])
+			}
+		}
+		if r.Response.Body != nil {
+			tc.add(path+".response.body", what+" response body", *r.Response.Body)
+		}
+	}
+
+	if r.Verify != nil {
+		tc.addSecret(path+".verify.secret", what+" verify secret", r.Verify.Secret)
+		tc.add(path+".verify.url", what+" verify url", r.Verify.URL)
+	}
+
+	if r.Auth != nil {
+		if r.Auth.APIKey != nil {
+			for _, name := range slices.Sorted(maps.Keys(r.Auth.APIKey.Keys)) {
+				tc.addSecret(path+".auth.api_key.keys."+name, what+" auth api key "+name, r.Auth.APIKey.Keys[name])
+			}
+		}
+		if r.Auth.Bearer != nil {
+			for _, name := range slices.Sorted(maps.Keys(r.Auth.Bearer.Tokens)) {
+				tc.addSecret(path+".auth.bearer.tokens."+name, what+" auth bearer token "+name, r.Auth.Bearer.Tokens[name])
+			}
+		}
+		if r.Auth.JWT != nil {
+			tc.addSecret(path+".auth.jwt.secret", what+" auth jwt secret", r.Auth.JWT.Secret)
+		}
+	}
+
+	if r.RateLimit != nil {
+		tc.add(path+".rate_limit.body", what+" rate_limit body", r.RateLimit.Body)
+	}
+}
+
+func (ref *DestinationRef) compileTemplates(tc *templateCompiler, path, what string) {
+	tc.add(path+".url", what+" url", ref.URL)
+	tc.add(path+".body", what+" body", ref.Body)
+	for _, header := range slices.Sorted(maps.Keys(ref.Headers)) {
+		tc.add(path+".headers."+header, what+" header "+header, ref.Headers[header])
+	}
+	if ref.Signing != nil {
+		tc.addSecret(path+".signing.secret", what+" signing secret", ref.Signing.Secret)
+	}
+}
+
+// referencedVariables returns the names of the variables a template reads
+// with .var.name or $.var.name
+func referencedVariables(node parse.Node) []string {
+	var names []string
+
+	walkTemplate(node, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.FieldNode:
+			if len(n.Ident) >= 2 && n.Ident[0] == "var" {
+				names = append(names, n.Ident[1])
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) >= 3 && n.Ident[0] == "$" && n.Ident[1] == "var" {
+				names = append(names, n.Ident[2])
+			}
+		}
+	})
+
+	return names
+}
+
+// BodyData returns the .json and .form template data for t. Each is only
+// parsed from body when the template may read it, and nil otherwise.
+func BodyData(t *template.Template, body *ParsedBody) (json any, form map[string][]string) {
+	readsJSON, readsForm := readsBody(t.Tree.Root)
+	if readsJSON {
+		json = body.JSON()
+	}
+	if readsForm {
+		form = body.Form()
+	}
+	return json, form
+}
+
+// readsBody reports whether a template may read .json or .form. Passing the
+// dot or $ on counts as reading both, as the callee could read either.
+func readsBody(node parse.Node) (json, form bool) {
+	reads := func(name string) {
+		switch name {
+		case "json":
+			json = true
+		case "form":
+			form = true
+		}
+	}
+
+	walkTemplate(node, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.FieldNode:
+			reads(n.Ident[0])
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" {
+				if len(n.Ident) == 1 {
+					json, form = true, true
+				} else {
+					reads(n.Ident[1])
+				}
+			}
+		case *parse.StringNode:
+			reads(n.Text) // index . "json"
+		case *parse.DotNode:
+			json, form = true, true
+		}
+	})
+
+	return json, form
+}
+
+// walkTemplate calls visit for every node of a template parse tree
+func walkTemplate(node parse.Node, visit func(parse.Node)) {
+	visit(node)
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplate(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTemplate(n.Pipe, visit)
+	case *parse.IfNode:
+		walkTemplate(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkTemplate(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkTemplate(&n.BranchNode, visit)
+	case *parse.BranchNode:
+		walkTemplate(n.Pipe, visit)
+		walkTemplate(n.List, visit)
+		walkTemplate(n.ElseList, visit)
+	case *parse.TemplateNode:
+		walkTemplate(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkTemplate(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplate(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkTemplate(n.Node, visit)
+	}
+}
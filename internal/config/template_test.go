@@ -0,0 +1,150 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const templatesConfig = `
+variables:
+  token: "t0ken"
+destinations:
+  echo:
+    url: "http://localhost/{{.params.tenant}}"
+    body: '{"tenant": "{{.params.tenant}}"}'
+routes:
+  - path: "/hook/{tenant}"
+    response:
+      headers:
+        X-Tenant: "{{.params.tenant}}"
+      body: '{"ok": {{.successful}}}'
+    matchers:
+      - expr: "true"
+        to:
+          - echo
+          - url: "http://localhost/inline"
+            headers:
+              Authorization: "Bearer {{.var.token}}"
+`
+
+func TestTemplatesAreParsedOnce(t *testing.T) {
+	cfg, err := loadConfig(t, templatesConfig)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	cache := cfg.Templates()
+	for _, src := range []string{
+		"http://localhost/{{.params.tenant}}",
+		`{"tenant": "{{.params.tenant}}"}`,
+		"{{.params.tenant}}",
+		`{"ok": {{.successful}}}`,
+		"Bearer {{.var.token}}",
+	} {
+		first, err := cache.Get(src)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", src, err)
+		}
+		if second, _ := cache.Get(src); first != second {
+			t.Errorf("template %q is parsed on every call", src)
+		}
+	}
+
+	// Sources outside the config are still parsed, just not cached
+	first, err := cache.Get("{{.body}}")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if second, _ := cache.Get("{{.body}}"); first == second {
+		t.Error("template outside the config was cached")
+	}
+
+	if _, err := (*TemplateCache)(nil).Get("{{.body}}"); err != nil {
+		t.Errorf("nil cache: %v", err)
+	}
+}
+
+func TestBrokenTemplatesFailLoading(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		replace string
+		with    string
+		want    string
+	}{
+		{"destination body", `{"tenant": "{{.params.tenant}}"}`, `{{.params.tenant`, "destination echo body"},
+		{"destination url", `http://localhost/{{.params.tenant}}`, `http://localhost/{{end}}`, "destination echo url"},
+		{"response header", `X-Tenant: "{{.params.tenant}}"`, `X-Tenant: "{{if}}"`, "route 0 response header X-Tenant"},
+		{"response body", `{"ok": {{.successful}}}`, `{{nosuchfunc}}`, "route 0 response body"},
+		{"inline header", `Bearer {{.var.token}}`, `Bearer {{.var.token`, "route 0 matcher 0 destination 1 header Authorization"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			content := strings.Replace(templatesConfig, tc.replace, tc.with, 1)
+			wantLoadError(t, content, "failed to compile templates for "+tc.want)
+		})
+	}
+}
+
+func TestSecretTemplatesNeedDefinedVariables(t *testing.T) {
+	withSecret := func(secret string) string {
+		return strings.Replace(templatesConfig, `          - echo
+`, `          - echo
+          - url: "http://localhost/signed"
+            signing:
+              secret: '`+secret+`'
+`, 1)
+	}
+
+	wantLoadError(t, withSecret(`{{.var.missing}}`), "signing secret: undefined variable 'missing'")
+	wantLoadError(t, withSecret(`{{if true}}{{$.var.other}}{{end}}`), "signing secret: undefined variable 'other'")
+
+	if _, err := loadConfig(t, withSecret(`{{.var.token}}-{{.params.tenant}}`)); err != nil {
+		t.Errorf("Load: %v", err)
+	}
+}
+
+func TestReferencedVariables(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{.var.a}}{{if .var.b}}{{range .params}}{{$.var.c}}{{end}}{{else}}{{with .var.d}}{{.}}{{end}}{{end}}{{.params.e}}{{printf "%s" .var.f}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := referencedVariables(tmpl.Tree.Root)
+	if want := []string{"a", "b", "c", "d", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("referencedVariables = %v, want %v", got, want)
+	}
+}
+
+func TestBodyDataOnlyParsesWhatTemplatesRead(t *testing.T) {
+	tests := []struct {
+		src        string
+		json, form bool
+	}{
+		{`{{.params.repo}} {{.body}}`, false, false},
+		{`{{.json.action}}`, true, false},
+		{`{{with .json}}{{.action}}{{end}}`, true, false},
+		{`{{range $.form.text}}{{.}}{{end}}`, true, true}, // The dot inside range counts too
+		{`{{index .form "text" 0}}`, false, true},
+		{`{{index . "json"}}`, true, true},
+		{`{{toJson $}}`, true, true},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if json, form := readsBody(tmpl.Tree.Root); json != tt.json || form != tt.form {
+			t.Errorf("readsBody(%q) = %v, %v, want %v, %v", tt.src, json, form, tt.json, tt.form)
+		}
+	}
+
+	body := NewParsedBody(`{"action": "opened"}`, "application/json")
+	tmpl, err := ParseTemplate(`{{.body}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json, form := BodyData(tmpl, body); json != nil || form != nil || body.json != nil {
+		t.Error("body parsed for a template that doesn't read it")
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ThrottleConfig paces deliveries to a named destination. Deliveries over the
+// limits wait for their turn instead of being sent right away.
+type ThrottleConfig struct {
+	Requests    int           `yaml:"requests,omitempty" expr:"requests"`           // Requests allowed per Per, 0 for no rate limit
+	Per         time.Duration `yaml:"per,omitempty" expr:"per"`                     // Default 1s
+	Burst       int           `yaml:"burst,omitempty" expr:"burst"`                 // Default Requests
+	MaxInFlight int           `yaml:"max_in_flight,omitempty" expr:"max_in_flight"` // Concurrent requests, 0 for unlimited
+	MaxWait     time.Duration `yaml:"max_wait,omitempty" expr:"max_wait"`           // Longest a delivery waits for its turn before it is retried later, default 10s
+}
+
+func (t *ThrottleConfig) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.Requests < 0 || t.Burst < 0 || t.MaxInFlight < 0 {
+		return fmt.Errorf("throttle limits must not be negative")
+	}
+	if t.Per < 0 || t.MaxWait < 0 {
+		return fmt.Errorf("throttle durations must not be negative")
+	}
+	if t.Requests == 0 && t.MaxInFlight == 0 {
+		return fmt.Errorf("throttle needs requests or max_in_flight")
+	}
+	return nil
+}
+
+// RatePerSecond returns the sustained request rate, 0 when unlimited
+func (t *ThrottleConfig) RatePerSecond() float64 {
+	if t.Requests == 0 {
+		return 0
+	}
+	per := t.Per
+	if per == 0 {
+		per = time.Second
+	}
+	return float64(t.Requests) / per.Seconds()
+}
+
+// GetBurst returns how many requests may be sent back to back
+func (t *ThrottleConfig) GetBurst() int {
+	if t.Burst == 0 {
+		return t.Requests
+	}
+	return t.Burst
+}
+
+// GetMaxWait returns how long a delivery may wait for its turn
+func (t *ThrottleConfig) GetMaxWait() time.Duration {
+	if t.MaxWait == 0 {
+		return 10 * time.Second
+	}
+	return t.MaxWait
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleValidation(t *testing.T) {
+	for throttle, want := range map[string]string{
+		"per: 1s":                               "needs requests or max_in_flight",
+		"requests: -1":                          "must not be negative",
+		"max_in_flight: 1\n      max_wait: -1s": "durations must not be negative",
+	} {
+		wantLoadError(t, `
+destinations:
+  echo:
+    url: "http://localhost"
+    throttle:
+      `+throttle+`
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`, want)
+	}
+}
+
+func TestThrottleDefaults(t *testing.T) {
+	throttle := &ThrottleConfig{Requests: 30, Per: time.Minute}
+	if throttle.RatePerSecond() != 0.5 || throttle.GetBurst() != 30 || throttle.GetMaxWait() != 10*time.Second {
+		t.Errorf("rate %v, burst %d, max wait %s", throttle.RatePerSecond(), throttle.GetBurst(), throttle.GetMaxWait())
+	}
+	if (&ThrottleConfig{MaxInFlight: 2}).RatePerSecond() != 0 {
+		t.Error("throttle without requests has a rate")
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	VerifyProviderGitHub  = "github"
+	VerifyProviderStripe  = "stripe"
+	VerifyProviderSlack   = "slack"
+	VerifyProviderGitLab  = "gitlab"
+	VerifyProviderShopify = "shopify"
+	VerifyProviderTwilio  = "twilio"
+	VerifyProviderHMAC    = "hmac"
+)
+
+var verifyProviders = []string{
+	VerifyProviderGitHub,
+	VerifyProviderStripe,
+	VerifyProviderSlack,
+	VerifyProviderGitLab,
+	VerifyProviderShopify,
+	VerifyProviderTwilio,
+	VerifyProviderHMAC,
+}
+
+// VerifyConfig describes how the signature of inbound requests on a route is verified
+type VerifyConfig struct {
+	Provider  string        `yaml:"provider" expr:"provider"`             // github, stripe, slack, gitlab, shopify, twilio or hmac
+	Secret    string        `yaml:"secret" expr:"-" secret:"true"`        // Signing secret, may use templates such as {{.var.github_secret}}
+	Tolerance time.Duration `yaml:"tolerance,omitempty" expr:"tolerance"` // Maximum age of timestamped signatures (stripe, slack), default 5m
+	URL       string        `yaml:"url,omitempty" expr:"url"`             // Public URL of the route as seen by the sender (twilio), default derived from the request
+	Header    string        `yaml:"header,omitempty" expr:"header"`       // Signature header (hmac)
+	Algorithm string        `yaml:"algorithm,omitempty" expr:"algorithm"` // sha1, sha256 or sha512 (hmac), default sha256
+	Encoding  string        `yaml:"encoding,omitempty" expr:"encoding"`   // hex or base64 (hmac), default hex
+	Prefix    string        `yaml:"prefix,omitempty" expr:"prefix"`       // Prefix in front of the signature such as "sha256=" (hmac)
+}
+
+func (v *VerifyConfig) Validate() error {
+	if v == nil {
+		return nil
+	}
+	if !slices.Contains(verifyProviders, v.Provider) {
+		return fmt.Errorf("verify has unknown provider '%s'", v.Provider)
+	}
+	if v.Secret == "" {
+		return fmt.Errorf("verify has no secret")
+	}
+	if v.Tolerance < 0 {
+		return fmt.Errorf("verify tolerance must not be negative")
+	}
+	if v.Provider == VerifyProviderHMAC {
+		if v.Header == "" {
+			return fmt.Errorf("verify with provider hmac needs a header")
+		}
+		if v.Algorithm != "" && !slices.Contains([]string{"sha1", "sha256", "sha512"}, v.Algorithm) {
+			return fmt.Errorf("verify has unknown algorithm '%s'", v.Algorithm)
+		}
+		if v.Encoding != "" && v.Encoding != "hex" && v.Encoding != "base64" {
+			return fmt.Errorf("verify has unknown encoding '%s'", v.Encoding)
+		}
+	}
+	return nil
+}
+
+// GetTolerance returns the maximum accepted age of a timestamped signature
+func (v *VerifyConfig) GetTolerance() time.Duration {
+	if v.Tolerance == 0 {
+		return 5 * time.Minute
+	}
+	return v.Tolerance
+}
@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func verifyRoute(verify string) string {
+	return `
+destinations:
+  echo: "http://localhost"
+routes:
+  - path: "/hook"
+    verify:
+` + verify + `
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestVerifyValidation(t *testing.T) {
+	wantLoadError(t, verifyRoute(`
+      provider: bitbucket
+      secret: x`), "unknown provider 'bitbucket'")
+	wantLoadError(t, verifyRoute(`
+      provider: github`), "verify has no secret")
+	wantLoadError(t, verifyRoute(`
+      provider: hmac
+      secret: x`), "needs a header")
+	wantLoadError(t, verifyRoute(`
+      provider: hmac
+      secret: x
+      header: X-Sig
+      algorithm: md5`), "unknown algorithm 'md5'")
+}
+
+func TestVerifySecretWithUndefinedVariableFailsToLoad(t *testing.T) {
+	wantLoadError(t, verifyRoute(`
+      provider: github
+      secret: "{{.var.missing}}"`), "missing")
+
+	cfg, err := loadConfig(t, `
+variables:
+  github_secret: s3cret
+`+verifyRoute(`
+      provider: github
+      secret: "{{.var.github_secret}}"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Routes[0].Verify.GetTolerance().String(); got != "5m0s" {
+		t.Errorf("default tolerance = %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package deadletter
+
+import (
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/filestore"
+	"github.com/framjet/go-webhook-middleman/internal/redact"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrNotFound is returned when no dead letter exists for an ID
+var ErrNotFound = filestore.ErrNotFound
+
+// Entry is a delivery that could not be forwarded, kept for inspection and replay
+type Entry struct {
+	ID          string                     `json:"id"`
+	Destination config.ResolvedDestination `json:"destination"`
+	Request     config.RequestMeta         `json:"request"`
+	Attempts    int                        `json:"attempts"`
+	LastError   string                     `json:"last_error,omitempty"`
+	LastStatus  int                        `json:"last_status,omitempty"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	FailedAt    time.Time                  `json:"failed_at"`
+	Replays     int                        `json:"replays,omitempty"`
+}
+
+// Redacted returns a copy of the entry for showing it, with secret values and
+// credential headers redacted from the delivery and the request it came from
+func (e *Entry) Redacted(secrets *redact.Secrets) *Entry {
+	redacted := *e
+
+	redacted.Destination.URL = secrets.Redact(e.Destination.URL)
+	if e.Destination.Headers != nil {
+		redacted.Destination.Headers = make(map[string]string, len(e.Destination.Headers))
+		for name, value := range e.Destination.Headers {
+			redacted.Destination.Headers[name] = secrets.Header(name, value)
+		}
+	}
+	if e.Destination.Body != nil {
+		redacted.Destination.Body = []byte(secrets.Redact(string(e.Destination.Body)))
+	}
+	redacted.LastError = secrets.Redact(e.LastError)
+
+	redacted.Request.URL = secrets.Redact(e.Request.URL)
+	if e.Request.Headers != nil {
+		redacted.Request.Headers = make(http.Header, len(e.Request.Headers))
+		for name, values := range e.Request.Headers {
+			for _, value := range values {
+				redacted.Request.Headers.Add(name, secrets.Header(name, value))
+			}
+		}
+	}
+	if e.Request.Params != nil {
+		redacted.Request.Params = make(map[string]string, len(e.Request.Params))
+		for name, value := range e.Request.Params {
+			redacted.Request.Params[name] = secrets.Redact(value)
+		}
+	}
+
+	return &redacted
+}
+
+// Store keeps dead-lettered deliveries on disk
+type Store struct {
+	store *filestore.Store[Entry]
+}
+
+// Open creates the dead-letter directory if needed and returns a store backed by it
+func Open(dir string) (*Store, error) {
+	store, err := filestore.Open[Entry](dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{store: store}, nil
+}
+
+// Put stores or replaces an entry
+func (s *Store) Put(e *Entry) error {
+	return s.store.Put(e.ID, e)
+}
+
+// Get loads a single entry by ID
+func (s *Store) Get(id string) (*Entry, error) {
+	return s.store.Get(id)
+}
+
+// Remove deletes an entry, it is not an error if it doesn't exist
+func (s *Store) Remove(id string) error {
+	return s.store.Remove(id)
+}
+
+// List returns all entries, most recently failed first
+func (s *Store) List() ([]*Entry, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].FailedAt.After(entries[j].FailedAt)
+	})
+
+	return entries, nil
+}
+
+// Purge removes all entries and returns how many were removed
+func (s *Store) Purge() (int, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		if err := s.store.Remove(e.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(entries), nil
+}
@@ -0,0 +1,80 @@
+package deadletter
+
+import (
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/redact"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestListNewestFirstAndPurge(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for id, failedAt := range map[string]time.Time{"old": now.Add(-time.Hour), "new": now, "middle": now.Add(-time.Minute)} {
+		if err := s.Put(&Entry{ID: id, FailedAt: failedAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].ID != "new" || entries[1].ID != "middle" || entries[2].ID != "old" {
+		t.Fatalf("List = %+v", entries)
+	}
+
+	count, err := s.Purge()
+	if err != nil || count != 3 {
+		t.Fatalf("Purge = %d, %v", count, err)
+	}
+	if _, err := s.Get("new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Purge: %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	var secrets redact.Secrets
+	secrets.Set([]string{"s3cret"})
+
+	entry := &Entry{
+		ID: "a",
+		Destination: config.ResolvedDestination{
+			URL:     "http://localhost/hook?token=s3cret",
+			Headers: map[string]string{"Authorization": "Bearer literal", "X-Tenant": "acme"},
+			Body:    []byte(`{"token": "s3cret"}`),
+		},
+		Request: config.RequestMeta{
+			URL:     "/hook/s3cret",
+			Headers: http.Header{"Cookie": {"session=1"}, "X-Sig": {"s3cret"}},
+			Params:  map[string]string{"key": "s3cret"},
+		},
+		LastError: "failed for s3cret",
+	}
+
+	got := entry.Redacted(&secrets)
+	if got.Destination.URL != "http://localhost/hook?token=[REDACTED]" || string(got.Destination.Body) != `{"token": "[REDACTED]"}` {
+		t.Errorf("destination = %+v", got.Destination)
+	}
+	if got.Destination.Headers["Authorization"] != "[REDACTED]" || got.Destination.Headers["X-Tenant"] != "acme" {
+		t.Errorf("headers = %v", got.Destination.Headers)
+	}
+	if got.Request.URL != "/hook/[REDACTED]" || got.Request.Headers.Get("Cookie") != "[REDACTED]" ||
+		got.Request.Headers.Get("X-Sig") != "[REDACTED]" || got.Request.Params["key"] != "[REDACTED]" {
+		t.Errorf("request = %+v", got.Request)
+	}
+	if got.LastError != "failed for [REDACTED]" {
+		t.Errorf("last error = %q", got.LastError)
+	}
+
+	// The stored entry keeps its values for replays
+	if entry.Destination.Headers["Authorization"] != "Bearer literal" || string(entry.Destination.Body) != `{"token": "s3cret"}` {
+		t.Errorf("original changed: %+v", entry)
+	}
+}
@@ -0,0 +1,215 @@
+package dedupe
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/filestore"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Settings control how long and where keys of one namespace are remembered
+type Settings struct {
+	TTL        time.Duration
+	MaxEntries int  // Entries kept in memory, least recently used are evicted first
+	Persist    bool // Also keep entries on disk so they survive a restart
+}
+
+// Response is the answer given to the first delivery of an event
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+// Entry remembers a processed key and the response it was answered with
+type Entry struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace"`
+	Key       string    `json:"key"`
+	RequestID string    `json:"request_id"` // Request that was forwarded
+	Response  Response  `json:"response"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type lru struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // Most recently used at the front
+}
+
+// Store remembers processed keys in an in-memory LRU per namespace, backed by
+// an on-disk store for namespaces that persist. Keys being processed are held
+// so concurrent duplicates aren't forwarded twice.
+type Store struct {
+	mu        sync.Mutex
+	caches    map[string]*lru
+	inflight  map[string]struct{}
+	disk      *filestore.Store[Entry]
+	lastSweep time.Time
+}
+
+// Open returns a store persisting to dir
+func Open(dir string) (*Store, error) {
+	disk, err := filestore.Open[Entry](dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		caches:    make(map[string]*lru),
+		inflight:  make(map[string]struct{}),
+		disk:      disk,
+		lastSweep: time.Now(),
+	}, nil
+}
+
+// Claim looks up a key. It returns the entry if the key was already
+// processed. Otherwise claimed reports whether the caller now owns the key and
+// must call Complete or Release, or if another request is still processing it.
+func (s *Store) Claim(namespace, key string, settings Settings, now time.Time) (entry *Entry, claimed bool) {
+	id := entryID(namespace, key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		go s.sweepDisk(now)
+	}
+
+	cache := s.cache(namespace, settings)
+	if entry := cache.get(id, now); entry != nil {
+		return entry, false
+	}
+
+	if _, ok := s.inflight[id]; ok {
+		return nil, false
+	}
+
+	if settings.Persist {
+		entry, err := s.disk.Get(id)
+		if err != nil && !errors.Is(err, filestore.ErrNotFound) {
+			slog.Warn("Failed to read dedupe entry", "key", key, "error", err)
+		}
+		if entry != nil && entry.ExpiresAt.After(now) {
+			cache.put(entry)
+			return entry, false
+		}
+	}
+
+	s.inflight[id] = struct{}{}
+
+	return nil, true
+}
+
+// Complete records the response for a claimed key
+func (s *Store) Complete(namespace, key, requestID string, response Response, settings Settings, now time.Time) error {
+	entry := &Entry{
+		ID:        entryID(namespace, key),
+		Namespace: namespace,
+		Key:       key,
+		RequestID: requestID,
+		Response:  response,
+		CreatedAt: now,
+		ExpiresAt: now.Add(settings.TTL),
+	}
+
+	s.mu.Lock()
+	s.cache(namespace, settings).put(entry)
+	delete(s.inflight, entry.ID)
+	s.mu.Unlock()
+
+	if !settings.Persist {
+		return nil
+	}
+
+	return s.disk.Put(entry.ID, entry)
+}
+
+// Release gives up a claimed key without recording it, so the next delivery
+// of the event is processed again
+func (s *Store) Release(namespace, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inflight, entryID(namespace, key))
+}
+
+// cache returns the LRU of a namespace, applying the current capacity
+func (s *Store) cache(namespace string, settings Settings) *lru {
+	cache, ok := s.caches[namespace]
+	if !ok {
+		cache = &lru{entries: make(map[string]*list.Element), order: list.New()}
+		s.caches[namespace] = cache
+	}
+	cache.capacity = settings.MaxEntries
+	cache.evict()
+
+	return cache
+}
+
+func (s *Store) sweepDisk(now time.Time) {
+	entries, err := s.disk.List()
+	if err != nil {
+		slog.Warn("Failed to list dedupe entries", "error", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.ExpiresAt.After(now) {
+			continue
+		}
+		if err := s.disk.Remove(entry.ID); err != nil {
+			slog.Warn("Failed to remove expired dedupe entry", "key", entry.Key, "error", err)
+		}
+	}
+}
+
+func (c *lru) get(id string, now time.Time) *Entry {
+	element, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*Entry)
+	if !entry.ExpiresAt.After(now) {
+		c.order.Remove(element)
+		delete(c.entries, id)
+		return nil
+	}
+
+	c.order.MoveToFront(element)
+
+	return entry
+}
+
+func (c *lru) put(entry *Entry) {
+	if element, ok := c.entries[entry.ID]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[entry.ID] = c.order.PushFront(entry)
+	c.evict()
+}
+
+func (c *lru) evict() {
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*Entry).ID)
+	}
+}
+
+// entryID hashes the key so any value can be used as a file name
+func entryID(namespace, key string) string {
+	sum := sha256.Sum256([]byte(namespace + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
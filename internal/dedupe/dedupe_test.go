@@ -0,0 +1,160 @@
+package dedupe
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+var memory = Settings{TTL: time.Hour, MaxEntries: 10}
+
+func open(t *testing.T, dir string) *Store {
+	t.Helper()
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return store
+}
+
+func TestClaimCompleteReplay(t *testing.T) {
+	store := open(t, t.TempDir())
+	now := time.Now()
+
+	entry, claimed := store.Claim("route", "evt-1", memory, now)
+	if entry != nil || !claimed {
+		t.Fatalf("first claim = %v, %v", entry, claimed)
+	}
+
+	if entry, claimed := store.Claim("route", "evt-1", memory, now); entry != nil || claimed {
+		t.Fatalf("claim while in flight = %v, %v", entry, claimed)
+	}
+
+	response := Response{Status: 202, Headers: http.Header{"X-Test": {"yes"}}, Body: []byte("ok")}
+	if err := store.Complete("route", "evt-1", "req-1", response, memory, now); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	entry, claimed = store.Claim("route", "evt-1", memory, now.Add(time.Minute))
+	if entry == nil || claimed {
+		t.Fatalf("claim after complete = %v, %v", entry, claimed)
+	}
+	if entry.RequestID != "req-1" || entry.Response.Status != 202 || string(entry.Response.Body) != "ok" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !entry.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("ExpiresAt = %s", entry.ExpiresAt)
+	}
+}
+
+func TestNamespacesAreSeparate(t *testing.T) {
+	store := open(t, t.TempDir())
+	now := time.Now()
+
+	store.Claim("a", "evt", memory, now)
+	if err := store.Complete("a", "evt", "req", Response{Status: 200}, memory, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, claimed := store.Claim("b", "evt", memory, now); entry != nil || !claimed {
+		t.Errorf("other namespace claim = %v, %v", entry, claimed)
+	}
+}
+
+func TestReleaseAllowsNextClaim(t *testing.T) {
+	store := open(t, t.TempDir())
+	now := time.Now()
+
+	store.Claim("route", "evt", memory, now)
+	store.Release("route", "evt")
+
+	if entry, claimed := store.Claim("route", "evt", memory, now); entry != nil || !claimed {
+		t.Errorf("claim after release = %v, %v", entry, claimed)
+	}
+}
+
+func TestExpiredEntriesAreForgotten(t *testing.T) {
+	store := open(t, t.TempDir())
+	now := time.Now()
+
+	store.Claim("route", "evt", memory, now)
+	if err := store.Complete("route", "evt", "req", Response{Status: 200}, memory, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, claimed := store.Claim("route", "evt", memory, now.Add(time.Hour)); entry != nil || !claimed {
+		t.Errorf("claim after ttl = %v, %v", entry, claimed)
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	store := open(t, t.TempDir())
+	settings := Settings{TTL: time.Hour, MaxEntries: 2}
+	now := time.Now()
+
+	for _, key := range []string{"a", "b"} {
+		store.Claim("route", key, settings, now)
+		if err := store.Complete("route", key, "req-"+key, Response{Status: 200}, settings, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Touch a so b is the least recently used
+	store.Claim("route", "a", settings, now)
+
+	store.Claim("route", "c", settings, now)
+	if err := store.Complete("route", "c", "req-c", Response{Status: 200}, settings, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, _ := store.Claim("route", "a", settings, now); entry == nil {
+		t.Error("a was evicted")
+	}
+	if entry, claimed := store.Claim("route", "b", settings, now); entry != nil || !claimed {
+		t.Errorf("b wasn't evicted: %v, %v", entry, claimed)
+	}
+}
+
+func TestPersistedEntriesSurviveReopen(t *testing.T) {
+	dir := t.TempDir()
+	disk := Settings{TTL: time.Hour, MaxEntries: 10, Persist: true}
+	now := time.Now()
+
+	store := open(t, dir)
+	store.Claim("route", "kept", disk, now)
+	if err := store.Complete("route", "kept", "req-1", Response{Status: 200, Body: []byte("done")}, disk, now); err != nil {
+		t.Fatal(err)
+	}
+	store.Claim("route", "memory-only", memory, now)
+	if err := store.Complete("route", "memory-only", "req-2", Response{Status: 200}, memory, now); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := open(t, dir)
+	entry, claimed := reopened.Claim("route", "kept", disk, now)
+	if entry == nil || claimed {
+		t.Fatalf("persisted claim = %v, %v", entry, claimed)
+	}
+	if entry.RequestID != "req-1" || string(entry.Response.Body) != "done" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	if entry, claimed := reopened.Claim("route", "memory-only", disk, now); entry != nil || !claimed {
+		t.Errorf("memory entry survived reopen: %v, %v", entry, claimed)
+	}
+
+	if entry, claimed := open(t, dir).Claim("route", "kept", disk, now.Add(2*time.Hour)); entry != nil || !claimed {
+		t.Errorf("expired disk entry = %v, %v", entry, claimed)
+	}
+}
+
+func TestEntryIDIsFileSafe(t *testing.T) {
+	id := entryID("0 POST /hook", "../../etc/passwd")
+	if len(id) != 64 {
+		t.Errorf("id = %q", id)
+	}
+	if entryID("ab", "c") == entryID("a", "bc") {
+		t.Error("namespace and key aren't separated")
+	}
+}
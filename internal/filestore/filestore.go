@@ -0,0 +1,139 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when no entry exists for an ID
+var ErrNotFound = errors.New("entry not found")
+
+// Store keeps each entry as a JSON file in a directory. It is meant for small
+// amounts of state that must survive a restart without an external service.
+type Store[T any] struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open creates the directory if needed and returns a store backed by it
+func Open[T any](dir string) (*Store[T], error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	return &Store[T]{dir: dir}, nil
+}
+
+// Put stores or replaces the entry with the given ID
+func (s *Store[T]) Put(id string, v *T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writeFileAtomic(s.path(id), data)
+}
+
+// Get loads the entry with the given ID
+func (s *Store[T]) Get(id string) (*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.read(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return v, err
+}
+
+// Remove deletes the entry with the given ID, it is not an error if it doesn't exist
+func (s *Store[T]) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// List returns all readable entries in no particular order
+func (s *Store[T]) List() ([]*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*T, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		v, err := s.read(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			slog.Warn("Skipping unreadable entry", "dir", s.dir, "file", entry.Name(), "error", err)
+			continue
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+func (s *Store[T]) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+func (s *Store[T]) read(path string) (*T, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode entry: %w", err)
+	}
+
+	return &v, nil
+}
+
+// writeFileAtomic writes to a temporary file and renames it into place so a
+// crash never leaves a partially written entry behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
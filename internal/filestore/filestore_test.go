@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type entry struct {
+	Name string `json:"name"`
+}
+
+func TestPutGetRemove(t *testing.T) {
+	s, err := Open[entry](filepath.Join(t.TempDir(), "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("a", &entry{Name: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("a", &entry{Name: "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Get("a")
+	if err != nil || got.Name != "second" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	if err := s.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("a"); err != nil {
+		t.Errorf("Remove of a missing entry: %v", err)
+	}
+	if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Remove: %v", err)
+	}
+}
+
+func TestListSkipsUnreadableEntries(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open[entry](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("a", &entry{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"broken.json": "{", "notes.txt": "x"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "a" {
+		t.Fatalf("List = %+v", entries)
+	}
+}
+
+func TestIDsStayInsideTheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open[entry](filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("../escape", &entry{Name: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("entry written outside the store: %v", err)
+	}
+	if _, err := s.Get("escape"); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+}
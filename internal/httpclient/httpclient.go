@@ -0,0 +1,179 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pool caches one HTTP client per distinct settings profile so connections
+// are reused between destinations that share settings.
+type Pool struct {
+	defaultTimeout time.Duration
+	defaultClient  *http.Client
+	transport      http.RoundTripper // Replaces the transport of every client when set
+
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}
+
+// NewPool creates a pool whose default client is used for destinations
+// without their own settings
+func NewPool(defaultTimeout time.Duration) *Pool {
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &Pool{
+		defaultTimeout: defaultTimeout,
+		defaultClient: &http.Client{
+			Timeout:   defaultTimeout,
+			Transport: transport,
+		},
+		clients: make(map[string]*http.Client),
+	}
+}
+
+// Get returns the client for the settings, building it on first use
+func (p *Pool) Get(cfg *config.HTTPClientConfig) (*http.Client, error) {
+	if cfg == nil {
+		return p.defaultClient, nil
+	}
+
+	key, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[string(key)]; ok {
+		return client, nil
+	}
+
+	client, err := p.build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[string(key)] = client
+
+	return client, nil
+}
+
+// UseTransport sends every request through rt instead of the network, for
+// example a Stub for dry runs
+func (p *Pool) UseTransport(rt http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.transport = rt
+	p.defaultClient.Transport = rt
+	p.clients = make(map[string]*http.Client)
+}
+
+// Reset drops the cached clients so certificate files are read again on next use
+func (p *Pool) Reset() {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = make(map[string]*http.Client)
+	p.mu.Unlock()
+
+	for _, client := range clients {
+		client.CloseIdleConnections()
+	}
+}
+
+func (p *Pool) build(cfg *config.HTTPClientConfig) (*http.Client, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if cfg.ConnectTimeout > 0 {
+		dialer.Timeout = cfg.ConnectTimeout
+	}
+
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	http2 := cfg.HTTP2 == nil || *cfg.HTTP2
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.ResponseTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     http2,
+	}
+	if !http2 {
+		// A non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeout := p.defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+
+	if p.transport != nil {
+		return &http.Client{Timeout: timeout, Transport: p.transport}, nil
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+func buildTLSConfig(cfg *config.HTTPClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
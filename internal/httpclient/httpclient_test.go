@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSharesClientsBySettings(t *testing.T) {
+	p := NewPool(time.Second)
+
+	defaultClient, err := p.Get(nil)
+	if err != nil || defaultClient.Timeout != time.Second {
+		t.Fatalf("default client = %+v, %v", defaultClient, err)
+	}
+
+	a, _ := p.Get(&config.HTTPClientConfig{Timeout: 5 * time.Second})
+	b, _ := p.Get(&config.HTTPClientConfig{Timeout: 5 * time.Second})
+	c, _ := p.Get(&config.HTTPClientConfig{Timeout: 6 * time.Second})
+	if a != b || a == c || a.Timeout != 5*time.Second {
+		t.Errorf("clients not shared by settings: %p %p %p", a, b, c)
+	}
+
+	p.Reset()
+	if d, _ := p.Get(&config.HTTPClientConfig{Timeout: 5 * time.Second}); d == a {
+		t.Error("Reset kept the cached client")
+	}
+}
+
+func TestCAFileTrustsServer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemData, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPool(5 * time.Second)
+	for name, tt := range map[string]struct {
+		cfg     *config.HTTPClientConfig
+		succeed bool
+	}{
+		"system roots": {&config.HTTPClientConfig{}, false},
+		"ca_file":      {&config.HTTPClientConfig{CAFile: caFile}, true},
+		"insecure":     {&config.HTTPClientConfig{InsecureSkipVerify: true}, true},
+	} {
+		client, err := p.Get(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.succeed {
+			t.Errorf("%s: request error = %v", name, err)
+		}
+	}
+}
+
+func TestBadCertificateFilesFail(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPool(time.Second)
+	for _, cfg := range []*config.HTTPClientConfig{
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{CAFile: empty},
+		{CertFile: empty, KeyFile: empty},
+	} {
+		if _, err := p.Get(cfg); err == nil {
+			t.Errorf("Get(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := NewPool(5 * time.Second).Get(&config.HTTPClientConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://destination.invalid/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://destination.invalid/hook" {
+		t.Errorf("proxy saw %q", proxied)
+	}
+}
+
+func TestUseTransportRoutesEveryClientThroughStub(t *testing.T) {
+	stub := &Stub{Respond: func(r *http.Request) StubResponse {
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			return StubResponse{Status: http.StatusBadGateway, Body: "nope"}
+		}
+		return StubResponse{}
+	}}
+
+	p := NewPool(time.Second)
+	p.UseTransport(stub)
+
+	client, err := p.Get(&config.HTTPClientConfig{Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{"/ok": http.StatusOK, "/fail": http.StatusBadGateway} {
+		resp, err := client.Post("http://destination.invalid"+path, "text/plain", strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status = %d", path, resp.StatusCode)
+		}
+	}
+
+	requests := stub.Requests()
+	if len(requests) != 2 || requests[0].Body != "body" || requests[0].Method != "POST" {
+		t.Errorf("recorded %+v", requests)
+	}
+	stub.Reset()
+	if len(stub.Requests()) != 0 {
+		t.Error("Reset kept recorded requests")
+	}
+}
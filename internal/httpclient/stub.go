@@ -0,0 +1,94 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RecordedRequest is an outbound request captured by a Stub
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// StubResponse is the answer a Stub gives to an outbound request
+type StubResponse struct {
+	Status  int
+	Headers http.Header
+	Body    string
+}
+
+// Stub is a transport that records outbound requests instead of sending them.
+// Every request is answered with 200 OK unless Respond says otherwise.
+type Stub struct {
+	Respond func(r *http.Request) StubResponse
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+func (s *Stub) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, RecordedRequest{
+		Method:  r.Method,
+		URL:     r.URL.String(),
+		Headers: r.Header.Clone(),
+		Body:    string(body),
+	})
+	s.mu.Unlock()
+
+	response := StubResponse{Status: http.StatusOK}
+	if s.Respond != nil {
+		response = s.Respond(r)
+	}
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	if response.Headers == nil {
+		response.Headers = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(response.Status),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Headers,
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       r,
+	}, nil
+}
+
+// Requests returns the requests recorded since the last Reset
+func (s *Stub) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// Reset forgets the recorded requests
+func (s *Stub) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
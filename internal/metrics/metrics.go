@@ -8,9 +8,21 @@ type Metrics struct {
 	ForwardingDuration *prometheus.HistogramVec
 	ForwardingTotal    *prometheus.CounterVec
 	RoutesMatched      *prometheus.CounterVec
+	ConfigReloads      *prometheus.CounterVec
+	ConfigReloadOK     prometheus.Gauge
+	RetriesTotal       *prometheus.CounterVec
+	RetryQueueDepth    prometheus.Gauge
+	DeadLettersTotal   *prometheus.CounterVec
+	CircuitState       *prometheus.GaugeVec
+	RateLimitedTotal   *prometheus.CounterVec
+	ThrottledTotal     *prometheus.CounterVec
+	ThrottleWait       *prometheus.HistogramVec
+	DuplicatesTotal    *prometheus.CounterVec
+	ProbeUp            *prometheus.GaugeVec
 }
 
-func NewMetrics() *Metrics {
+// NewMetrics creates the metrics and registers them with registerer
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
 	m := &Metrics{
 		WebhooksReceived: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "webhook_middleman_webhooks_received_total",
@@ -33,16 +45,74 @@ func NewMetrics() *Metrics {
 			Name: "webhook_middleman_routes_matched_total",
 			Help: "Total number of routes matched per webhook",
 		}, []string{"method", "path"}),
+		ConfigReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_middleman_config_reloads_total",
+			Help: "Total number of configuration reload attempts",
+		}, []string{"trigger", "status"}),
+		ConfigReloadOK: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "webhook_middleman_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt succeeded (1) or failed (0)",
+		}),
+		RetriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_middleman_retries_total",
+			Help: "Total number of retry queue events per destination",
+		}, []string{"destination", "status"}),
+		RetryQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "webhook_middleman_retry_queue_depth",
+			Help: "Number of deliveries waiting in the retry queue",
+		}),
+		DeadLettersTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_middleman_dead_letters_total",
+			Help: "Total number of deliveries moved to the dead-letter store",
+		}, []string{"destination"}),
+		CircuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "webhook_middleman_circuit_breaker_state",
+			Help: "Circuit breaker state per destination (0 closed, 1 half-open, 2 open)",
+		}, []string{"destination"}),
+		RateLimitedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_middleman_rate_limited_total",
+			Help: "Total number of webhook requests rejected by a route rate limit",
+		}, []string{"route"}),
+		ThrottledTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_middleman_throttled_total",
+			Help: "Total number of deliveries held back by a destination throttle or by the destination itself",
+		}, []string{"destination", "reason"}),
+		ThrottleWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_middleman_throttle_wait_seconds",
+			Help:    "Time deliveries waited for their turn before being sent",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"destination"}),
+		DuplicatesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_middleman_duplicates_total",
+			Help: "Total number of duplicate webhooks answered without forwarding",
+		}, []string{"route"}),
+		ProbeUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "webhook_middleman_destination_probe_up",
+			Help: "Whether the last readiness probe of a destination succeeded (1) or failed (0)",
+		}, []string{"destination"}),
 	}
 
 	// Register metrics
-	prometheus.MustRegister(
+	registerer.MustRegister(
 		m.WebhooksReceived,
 		m.WebhooksProcessed,
 		m.ForwardingDuration,
 		m.ForwardingTotal,
 		m.RoutesMatched,
+		m.ConfigReloads,
+		m.ConfigReloadOK,
+		m.RetriesTotal,
+		m.RetryQueueDepth,
+		m.DeadLettersTotal,
+		m.CircuitState,
+		m.RateLimitedTotal,
+		m.ThrottledTotal,
+		m.ThrottleWait,
+		m.DuplicatesTotal,
+		m.ProbeUp,
 	)
 
+	m.ConfigReloadOK.Set(1)
+
 	return m
 }
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sample is the current value of one labelled series of a metric
+type Sample struct {
+	Labels map[string]string
+	Value  float64 // Observation count for histograms
+}
+
+// Samples reads the current value of every series of a metric
+func Samples(c prometheus.Collector) []Sample {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var samples []Sample
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+
+		sample := Sample{Labels: make(map[string]string, len(m.GetLabel()))}
+		for _, label := range m.GetLabel() {
+			sample.Labels[label.GetName()] = label.GetValue()
+		}
+
+		switch {
+		case m.Counter != nil:
+			sample.Value = m.GetCounter().GetValue()
+		case m.Gauge != nil:
+			sample.Value = m.GetGauge().GetValue()
+		case m.Histogram != nil:
+			sample.Value = float64(m.GetHistogram().GetSampleCount())
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
+
+// Sum adds up the series of a metric whose labels include the given ones
+func Sum(c prometheus.Collector, labels map[string]string) float64 {
+	total := 0.0
+	for _, sample := range Samples(c) {
+		if matches(sample.Labels, labels) {
+			total += sample.Value
+		}
+	}
+	return total
+}
+
+// SumBy adds up the series of a metric whose labels include the given ones
+// separately for each value of another label
+func SumBy(c prometheus.Collector, labels map[string]string, by string) map[string]float64 {
+	totals := make(map[string]float64)
+	for _, sample := range Samples(c) {
+		if matches(sample.Labels, labels) {
+			totals[sample.Labels[by]] += sample.Value
+		}
+	}
+	return totals
+}
+
+func matches(labels, want map[string]string) bool {
+	for name, value := range want {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+func TestSamples(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total"}, []string{"destination", "status"})
+	counter.WithLabelValues("a", "success").Add(2)
+	counter.WithLabelValues("a", "failure").Inc()
+	counter.WithLabelValues("b", "success").Add(4)
+
+	if samples := Samples(counter); len(samples) != 3 {
+		t.Errorf("samples = %+v", samples)
+	}
+	if got := Sum(counter, map[string]string{"destination": "a"}); got != 3 {
+		t.Errorf("Sum(a) = %v", got)
+	}
+	if got := Sum(counter, nil); got != 7 {
+		t.Errorf("Sum() = %v", got)
+	}
+	if got := Sum(counter, map[string]string{"destination": "c"}); got != 0 {
+		t.Errorf("Sum(c) = %v", got)
+	}
+
+	byStatus := SumBy(counter, map[string]string{"destination": "a"}, "status")
+	if len(byStatus) != 2 || byStatus["success"] != 2 || byStatus["failure"] != 1 {
+		t.Errorf("SumBy = %v", byStatus)
+	}
+}
+
+func TestSamplesCountHistogramObservations(t *testing.T) {
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_seconds"}, []string{"route"})
+	histogram.WithLabelValues("/hook").Observe(0.1)
+	histogram.WithLabelValues("/hook").Observe(2)
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_queued"})
+	gauge.Set(5)
+
+	if got := Sum(histogram, map[string]string{"route": "/hook"}); got != 2 {
+		t.Errorf("histogram = %v", got)
+	}
+	if got := Sum(gauge, nil); got != 5 {
+		t.Errorf("gauge = %v", got)
+	}
+}
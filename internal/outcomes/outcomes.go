@@ -0,0 +1,90 @@
+package outcomes
+
+import (
+	"sync"
+	"time"
+)
+
+// capacity is how many outcomes are kept per destination, older ones are
+// dropped even when they are still within a window
+const capacity = 1024
+
+type outcome struct {
+	at      time.Time
+	success bool
+}
+
+// history is a ring of the latest outcomes of one destination
+type history struct {
+	outcomes []outcome
+	next     int
+}
+
+// Ratio is the share of successful deliveries within a window
+type Ratio struct {
+	Requests  int
+	Successes int
+}
+
+// Value returns the success ratio, 1 when there were no requests
+func (r Ratio) Value() float64 {
+	if r.Requests == 0 {
+		return 1
+	}
+	return float64(r.Successes) / float64(r.Requests)
+}
+
+// Tracker records recent delivery outcomes by destination, safe for
+// concurrent use
+type Tracker struct {
+	mu        sync.Mutex
+	histories map[string]*history
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{histories: make(map[string]*history)}
+}
+
+// Record adds the outcome of a delivery
+func (t *Tracker) Record(name string, success bool, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.histories[name]
+	if !ok {
+		h = &history{}
+		t.histories[name] = h
+	}
+
+	if len(h.outcomes) < capacity {
+		h.outcomes = append(h.outcomes, outcome{at: at, success: success})
+		return
+	}
+	h.outcomes[h.next] = outcome{at: at, success: success}
+	h.next = (h.next + 1) % capacity
+}
+
+// Ratio counts the outcomes of a destination recorded since window before now
+func (t *Tracker) Ratio(name string, window time.Duration, now time.Time) Ratio {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ratio Ratio
+	h, ok := t.histories[name]
+	if !ok {
+		return ratio
+	}
+
+	since := now.Add(-window)
+	for _, o := range h.outcomes {
+		if o.at.Before(since) {
+			continue
+		}
+		ratio.Requests++
+		if o.success {
+			ratio.Successes++
+		}
+	}
+
+	return ratio
+}
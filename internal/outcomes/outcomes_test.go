@@ -0,0 +1,51 @@
+package outcomes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRatioCountsWithinWindow(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+
+	tracker.Record("echo", false, now.Add(-10*time.Minute))
+	tracker.Record("echo", true, now.Add(-time.Minute))
+	tracker.Record("echo", false, now.Add(-30*time.Second))
+	tracker.Record("echo", true, now)
+	tracker.Record("other", false, now)
+
+	ratio := tracker.Ratio("echo", 5*time.Minute, now)
+	if ratio.Requests != 3 || ratio.Successes != 2 {
+		t.Errorf("ratio = %+v", ratio)
+	}
+	if got := ratio.Value(); got < 0.66 || got > 0.67 {
+		t.Errorf("Value() = %v", got)
+	}
+}
+
+func TestRatioWithoutRequestsIsOne(t *testing.T) {
+	tracker := NewTracker()
+
+	ratio := tracker.Ratio("unknown", time.Minute, time.Now())
+	if ratio.Requests != 0 || ratio.Value() != 1 {
+		t.Errorf("ratio = %+v, value %v", ratio, ratio.Value())
+	}
+}
+
+func TestRecordKeepsLatestOutcomes(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+
+	for range capacity {
+		tracker.Record("echo", false, now)
+	}
+	for range capacity / 2 {
+		tracker.Record("echo", true, now)
+	}
+
+	ratio := tracker.Ratio("echo", time.Minute, now)
+	if ratio.Requests != capacity || ratio.Successes != capacity/2 {
+		t.Errorf("ratio = %+v", ratio)
+	}
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/filestore"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Delivery is a pending forward waiting for its next retry attempt
+type Delivery struct {
+	ID          string                     `json:"id"`
+	Destination config.ResolvedDestination `json:"destination"`
+	Request     config.RequestMeta         `json:"request"`
+	Attempts    int                        `json:"attempts"`
+	NextAttempt time.Time                  `json:"next_attempt"`
+	LastError   string                     `json:"last_error,omitempty"`
+	LastStatus  int                        `json:"last_status,omitempty"`
+	CreatedAt   time.Time                  `json:"created_at"`
+}
+
+// Queue is an embedded on-disk queue storing each delivery as a JSON file so
+// pending retries survive a restart. The next attempt times are indexed in
+// memory, so only due deliveries are read back from disk.
+type Queue struct {
+	store *filestore.Store[Delivery]
+
+	mu    sync.Mutex
+	index map[string]time.Time // Delivery ID to next attempt
+}
+
+// Open creates the queue directory if needed and returns a queue backed by
+// it, indexing the deliveries already queued
+func Open(dir string) (*Queue, error) {
+	store, err := filestore.Open[Delivery](dir)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{store: store, index: make(map[string]time.Time, len(deliveries))}
+	for _, d := range deliveries {
+		q.index[d.ID] = d.NextAttempt
+	}
+
+	return q, nil
+}
+
+// Put stores or replaces a delivery
+func (q *Queue) Put(d *Delivery) error {
+	if err := q.store.Put(d.ID, d); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.index[d.ID] = d.NextAttempt
+	q.mu.Unlock()
+
+	return nil
+}
+
+// Get loads a single delivery by ID
+func (q *Queue) Get(id string) (*Delivery, error) {
+	return q.store.Get(id)
+}
+
+// Remove deletes a delivery, it is not an error if it doesn't exist
+func (q *Queue) Remove(id string) error {
+	if err := q.store.Remove(id); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	delete(q.index, id)
+	q.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the number of queued deliveries
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.index)
+}
+
+// List returns all deliveries ordered by their next attempt time
+func (q *Queue) List() ([]*Delivery, error) {
+	deliveries, err := q.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+	})
+
+	return deliveries, nil
+}
+
+// Due returns the deliveries whose next attempt is at or before now, ordered
+// by their next attempt time
+func (q *Queue) Due(now time.Time) ([]*Delivery, error) {
+	q.mu.Lock()
+	var ids []string
+	for id, next := range q.index {
+		if !next.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	q.mu.Unlock()
+
+	deliveries := make([]*Delivery, 0, len(ids))
+	for _, id := range ids {
+		d, err := q.store.Get(id)
+		if err != nil {
+			// Forget deliveries removed or corrupted behind the queue's back
+			if !errors.Is(err, filestore.ErrNotFound) {
+				slog.Warn("Skipping unreadable delivery", "id", id, "error", err)
+			}
+			q.mu.Lock()
+			delete(q.index, id)
+			q.mu.Unlock()
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+	})
+
+	return deliveries, nil
+}
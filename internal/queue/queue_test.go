@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDueReturnsDeliveriesInAttemptOrder(t *testing.T) {
+	q, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for id, next := range map[string]time.Time{
+		"late":   now.Add(-time.Second),
+		"early":  now.Add(-time.Minute),
+		"future": now.Add(time.Minute),
+	} {
+		if err := q.Put(&Delivery{ID: id, NextAttempt: next}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due, err := q.Due(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].ID != "early" || due[1].ID != "late" {
+		t.Fatalf("Due = %+v", due)
+	}
+	if q.Len() != 3 {
+		t.Errorf("Len = %d", q.Len())
+	}
+
+	if err := q.Remove("early"); err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Len after Remove = %d", q.Len())
+	}
+}
+
+func TestPutReschedulesDelivery(t *testing.T) {
+	q, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	d := &Delivery{ID: "a", NextAttempt: now.Add(-time.Second)}
+	if err := q.Put(d); err != nil {
+		t.Fatal(err)
+	}
+	d.NextAttempt = now.Add(time.Minute)
+	if err := q.Put(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if due, _ := q.Due(now); len(due) != 0 {
+		t.Fatalf("rescheduled delivery is due: %+v", due)
+	}
+}
+
+func TestOpenIndexesExistingDeliveries(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(&Delivery{ID: "a", Attempts: 2, NextAttempt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := q.Due(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].ID != "a" || due[0].Attempts != 2 {
+		t.Fatalf("Due after reopening = %+v", due)
+	}
+}
+
+func TestDueForgetsDeliveriesRemovedFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"gone", "broken"} {
+		if err := q.Put(&Delivery{ID: id, NextAttempt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, "gone.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := q.Due(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 0 || q.Len() != 0 {
+		t.Fatalf("Due = %+v, Len = %d", due, q.Len())
+	}
+}
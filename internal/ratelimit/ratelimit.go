@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Bucket identifies a token bucket and how it refills
+type Bucket struct {
+	Key   string
+	Rate  float64 // Tokens added per second
+	Burst int     // Capacity
+}
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  float64
+}
+
+// Store holds token buckets by key. Buckets that have refilled completely are
+// dropped periodically, so keys such as client IPs don't accumulate.
+type Store struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucketState
+	lastSweep time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		buckets:   make(map[string]*bucketState),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes one token from every bucket if all of them have one. Otherwise
+// nothing is taken and it returns how long until the request would be allowed.
+func (s *Store) Allow(buckets []Bucket, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.sweep(now)
+	}
+
+	states := make([]*bucketState, len(buckets))
+	var wait time.Duration
+	for i, b := range buckets {
+		state := s.bucket(b, now)
+		states[i] = state
+
+		if state.tokens < 1 {
+			needed := time.Duration((1 - state.tokens) / state.rate * float64(time.Second))
+			wait = max(wait, needed)
+		}
+	}
+
+	if wait > 0 {
+		return false, wait
+	}
+
+	for _, state := range states {
+		state.tokens--
+	}
+
+	return true, 0
+}
+
+// bucket returns the refilled state of a bucket, creating it full
+func (s *Store) bucket(b Bucket, now time.Time) *bucketState {
+	state, ok := s.buckets[b.Key]
+	if !ok || state.rate != b.Rate || state.burst != float64(b.Burst) {
+		state = &bucketState{tokens: float64(b.Burst), last: now, rate: b.Rate, burst: float64(b.Burst)}
+		s.buckets[b.Key] = state
+		return state
+	}
+
+	elapsed := now.Sub(state.last).Seconds()
+	if elapsed > 0 {
+		state.tokens = math.Min(state.burst, state.tokens+elapsed*state.rate)
+		state.last = now
+	}
+
+	return state
+}
+
+func (s *Store) sweep(now time.Time) {
+	for key, state := range s.buckets {
+		if state.tokens+now.Sub(state.last).Seconds()*state.rate >= state.burst {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketBurstAndRefill(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	bucket := []Bucket{{Key: "a", Rate: 2, Burst: 3}}
+
+	for i := range 3 {
+		if ok, _ := s.Allow(bucket, now); !ok {
+			t.Fatalf("request %d within burst rejected", i+1)
+		}
+	}
+
+	ok, wait := s.Allow(bucket, now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("over burst: ok %v, wait %s", ok, wait)
+	}
+
+	if ok, _ := s.Allow(bucket, now.Add(500*time.Millisecond)); !ok {
+		t.Error("refilled token rejected")
+	}
+	if ok, _ := s.Allow(bucket, now.Add(500*time.Millisecond)); ok {
+		t.Error("refill added more than one token")
+	}
+}
+
+func TestAllowTakesFromAllBucketsOrNone(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	route := Bucket{Key: "route", Rate: 1, Burst: 10}
+	ip := Bucket{Key: "ip", Rate: 1, Burst: 1}
+
+	if ok, _ := s.Allow([]Bucket{route, ip}, now); !ok {
+		t.Fatal("first request rejected")
+	}
+	for range 5 {
+		if ok, _ := s.Allow([]Bucket{route, ip}, now); ok {
+			t.Fatal("request over the ip limit allowed")
+		}
+	}
+
+	// The rejected requests didn't use up the route bucket
+	for i := range 9 {
+		if ok, _ := s.Allow([]Bucket{route}, now); !ok {
+			t.Fatalf("route request %d rejected", i+1)
+		}
+	}
+}
+
+func TestKeysAreIndependent(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	s.Allow([]Bucket{{Key: "a", Rate: 1, Burst: 1}}, now)
+	if ok, _ := s.Allow([]Bucket{{Key: "b", Rate: 1, Burst: 1}}, now); !ok {
+		t.Error("bucket b limited by a")
+	}
+}
+
+func TestChangedSettingsResetBucket(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	s.Allow([]Bucket{{Key: "a", Rate: 1, Burst: 1}}, now)
+	if ok, _ := s.Allow([]Bucket{{Key: "a", Rate: 1, Burst: 5}}, now); !ok {
+		t.Error("bucket kept its state after the burst changed")
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	s.Allow([]Bucket{{Key: "idle", Rate: 1, Burst: 1}}, now)
+	s.Allow([]Bucket{{Key: "busy", Rate: 0.001, Burst: 1}}, now)
+	s.Allow([]Bucket{{Key: "other", Rate: 1, Burst: 1}}, now.Add(2*time.Minute))
+
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("refilled bucket kept")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("empty bucket dropped")
+	}
+}
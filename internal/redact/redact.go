@@ -0,0 +1,134 @@
+package redact
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Placeholder replaces secret values
+const Placeholder = "[REDACTED]"
+
+// credentialHeaders are redacted whole, whether or not their values are known secrets
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// Header returns the value of a header for showing it, with the values of
+// credential headers such as Authorization replaced entirely
+func Header(name, value string) string {
+	if value != "" && slices.Contains(credentialHeaders, http.CanonicalHeaderKey(name)) {
+		return Placeholder
+	}
+	return value
+}
+
+// Secrets is a set of values to keep out of logs, safe for concurrent use
+type Secrets struct {
+	replacer atomic.Pointer[strings.Replacer]
+}
+
+// Set replaces the secret values
+func (s *Secrets) Set(values []string) {
+	values = slices.DeleteFunc(slices.Clone(values), func(value string) bool { return value == "" })
+	if len(values) == 0 {
+		s.replacer.Store(nil)
+		return
+	}
+
+	// Longer values first so a secret containing another is replaced whole
+	slices.SortFunc(values, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, Placeholder)
+	}
+	s.replacer.Store(strings.NewReplacer(pairs...))
+}
+
+// Redact replaces the secret values in text
+func (s *Secrets) Redact(text string) string {
+	replacer := s.replacer.Load()
+	if replacer == nil {
+		return text
+	}
+	return replacer.Replace(text)
+}
+
+// Header redacts the secret values in the value of a header, see Header
+func (s *Secrets) Header(name, value string) string {
+	return s.Redact(Header(name, value))
+}
+
+func (s *Secrets) empty() bool {
+	return s.replacer.Load() == nil
+}
+
+// Handler is a slog handler that redacts secret values from the messages and
+// attributes of records before passing them on
+type Handler struct {
+	next    slog.Handler
+	secrets *Secrets
+}
+
+func NewHandler(next slog.Handler, secrets *Secrets) *Handler {
+	return &Handler{next: next, secrets: secrets}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if h.secrets.empty() {
+		return h.next.Handle(ctx, record)
+	}
+
+	redacted := slog.NewRecord(record.Time, record.Level, h.secrets.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.attr(attr))
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.attr(attr)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted), secrets: h.secrets}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), secrets: h.secrets}
+}
+
+// attr redacts an attribute. Values other than strings and groups, such as
+// headers or errors, are formatted as text when they contain a secret.
+func (h *Handler) attr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.secrets.Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, inner := range group {
+			redacted[i] = h.attr(inner)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		text := fmt.Sprint(value.Any())
+		if redacted := h.secrets.Redact(text); redacted != text {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	return slog.Attr{Key: attr.Key, Value: value}
+}
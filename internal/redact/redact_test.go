@@ -0,0 +1,96 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	for _, tc := range []struct{ name, value, want string }{
+		{"Authorization", "Bearer literal", Placeholder},
+		{"x-api-key", "literal", Placeholder},
+		{"Cookie", "session=1", Placeholder},
+		{"Authorization", "", ""},
+		{"Content-Type", "application/json", "application/json"},
+	} {
+		if got := Header(tc.name, tc.value); got != tc.want {
+			t.Errorf("Header(%q, %q) = %q, want %q", tc.name, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestSecretsHeader(t *testing.T) {
+	var secrets Secrets
+	secrets.Set([]string{"abc"})
+
+	for _, tc := range []struct{ name, value, want string }{
+		{"Authorization", "Bearer literal", Placeholder},
+		{"X-Token", "key=abc", "key=[REDACTED]"},
+		{"Content-Type", "application/json", "application/json"},
+	} {
+		if got := secrets.Header(tc.name, tc.value); got != tc.want {
+			t.Errorf("Header(%q, %q) = %q, want %q", tc.name, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var secrets Secrets
+	if got := secrets.Redact("nothing set"); got != "nothing set" {
+		t.Errorf("Redact = %q", got)
+	}
+
+	secrets.Set([]string{"", "abc", "abcdef"})
+	if got := secrets.Redact("key=abcdef other=abc"); got != "key=[REDACTED] other=[REDACTED]" {
+		t.Errorf("Redact = %q", got)
+	}
+
+	secrets.Set(nil)
+	if got := secrets.Redact("abc"); got != "abc" {
+		t.Errorf("Redact after clearing = %q", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var secrets Secrets
+	secrets.Set([]string{"s3cret"})
+
+	var out bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&out, nil), &secrets)).With("token", "s3cret")
+
+	logger.Info("sending s3cret",
+		"url", "https://example.com/s3cret",
+		"count", 3,
+		"headers", http.Header{"Authorization": {"Bearer s3cret"}},
+		"error", errors.New("rejected s3cret"),
+		slog.Group("request", "query", "key=s3cret"))
+
+	logged := out.String()
+	if strings.Contains(logged, "s3cret") {
+		t.Errorf("secret logged: %s", logged)
+	}
+	for _, want := range []string{"msg=\"sending [REDACTED]\"", "token=[REDACTED]", "count=3", `request.query="key=[REDACTED]"`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("log lacks %q: %s", want, logged)
+		}
+	}
+}
+
+func TestHandlerFollowsSecretChanges(t *testing.T) {
+	var secrets Secrets
+
+	var out bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&out, nil), &secrets))
+
+	logger.Info("old")
+	secrets.Set([]string{"old"})
+	logger.Info("old")
+
+	if got := out.String(); !strings.Contains(got, "msg=old") || !strings.Contains(got, "msg=[REDACTED]") {
+		t.Errorf("log = %s", got)
+	}
+}
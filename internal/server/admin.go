@@ -0,0 +1,247 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/deadletter"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// setupAdminRoutes mounts the admin endpoints under /admin. They are only
+// available when an admin token is configured.
+func (ws *WebhookServer) setupAdminRoutes(r *mux.Router) {
+	if ws.adminToken == "" {
+		return
+	}
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(ws.requireAdminToken)
+
+	admin.HandleFunc("/config", ws.showConfig).Methods("GET")
+	admin.HandleFunc("/reload", ws.reloadConfig).Methods("POST")
+	admin.HandleFunc("/routes", ws.listRoutes).Methods("GET")
+	admin.HandleFunc("/destinations", ws.listDestinations).Methods("GET")
+	admin.HandleFunc("/destinations/{name}", ws.showDestination).Methods("GET")
+	admin.HandleFunc("/destinations/{name}/pause", ws.pauseDestination).Methods("POST")
+	admin.HandleFunc("/destinations/{name}/resume", ws.resumeDestination).Methods("POST")
+	admin.HandleFunc("/destinations/{name}/test", ws.testDestination).Methods("POST")
+
+	admin.HandleFunc("/deadletters", ws.listDeadLetters).Methods("GET")
+	admin.HandleFunc("/deadletters", ws.purgeDeadLetters).Methods("DELETE")
+	admin.HandleFunc("/deadletters/{id}", ws.showDeadLetter).Methods("GET")
+	admin.HandleFunc("/deadletters/{id}", ws.deleteDeadLetter).Methods("DELETE")
+	admin.HandleFunc("/deadletters/{id}/replay", ws.replayDeadLetter).Methods("POST")
+}
+
+func (ws *WebhookServer) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(ws.adminToken)) != 1 {
+			ws.logger.Warn("Unauthorized admin request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			ws.writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "UNAUTHORIZED")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (ws *WebhookServer) showConfig(w http.ResponseWriter, _ *http.Request) {
+	config := ws.Config()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"files":  config.Files(),
+		"config": config.Sanitized(),
+	})
+}
+
+func (ws *WebhookServer) reloadConfig(w http.ResponseWriter, _ *http.Request) {
+	if err := ws.Reload("admin"); err != nil {
+		ws.writeErrorResponse(w, http.StatusUnprocessableEntity, ws.secrets.Redact(err.Error()), "RELOAD_FAILED")
+		return
+	}
+
+	config := ws.Config()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":       "reloaded",
+		"destinations": len(config.Destinations),
+		"routes":       len(config.Routes),
+	})
+}
+
+func (ws *WebhookServer) listRoutes(w http.ResponseWriter, _ *http.Request) {
+	routes := ws.RouteStatuses()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":  len(routes),
+		"routes": routes,
+	})
+}
+
+func (ws *WebhookServer) listDestinations(w http.ResponseWriter, _ *http.Request) {
+	destinations, err := ws.DestinationStatuses()
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusInternalServerError, err.Error(), "QUEUE_ERROR")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":        len(destinations),
+		"destinations": destinations,
+	})
+}
+
+func (ws *WebhookServer) showDestination(w http.ResponseWriter, r *http.Request) {
+	status, ok, err := ws.DestinationStatus(mux.Vars(r)["name"])
+	if !ok {
+		ws.writeDestinationNotFound(w)
+		return
+	}
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusInternalServerError, err.Error(), "QUEUE_ERROR")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (ws *WebhookServer) pauseDestination(w http.ResponseWriter, r *http.Request) {
+	ws.changeDestination(w, mux.Vars(r)["name"], ws.PauseDestination)
+}
+
+func (ws *WebhookServer) resumeDestination(w http.ResponseWriter, r *http.Request) {
+	ws.changeDestination(w, mux.Vars(r)["name"], ws.ResumeDestination)
+}
+
+// changeDestination applies a pause or resume and responds with the
+// resulting state of the destination
+func (ws *WebhookServer) changeDestination(w http.ResponseWriter, name string, change func(string) bool) {
+	if !change(name) {
+		ws.writeDestinationNotFound(w)
+		return
+	}
+
+	status, _, err := ws.DestinationStatus(name)
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusInternalServerError, err.Error(), "QUEUE_ERROR")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (ws *WebhookServer) testDestination(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusBadRequest, "Failed to read request body", "BODY_READ_ERROR")
+		return
+	}
+
+	delivery, err := ws.TestDestination(r.Context(), mux.Vars(r)["name"], r, body)
+	if errors.Is(err, errUnknownDestination) {
+		ws.writeDestinationNotFound(w)
+		return
+	}
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusUnprocessableEntity, ws.secrets.Redact(err.Error()), "TEMPLATE_ERROR")
+		return
+	}
+
+	status := http.StatusOK
+	if !delivery.Result.Success {
+		status = http.StatusBadGateway
+	}
+
+	writeJSON(w, status, delivery)
+}
+
+func (ws *WebhookServer) writeDestinationNotFound(w http.ResponseWriter) {
+	ws.writeErrorResponse(w, http.StatusNotFound, "Destination not found", "DESTINATION_NOT_FOUND")
+}
+
+func (ws *WebhookServer) listDeadLetters(w http.ResponseWriter, _ *http.Request) {
+	entries, err := ws.deadLetters.List()
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusInternalServerError, err.Error(), "DEAD_LETTER_ERROR")
+		return
+	}
+
+	for i, entry := range entries {
+		entries[i] = entry.Redacted(ws.secrets)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":        len(entries),
+		"dead_letters": entries,
+	})
+}
+
+func (ws *WebhookServer) showDeadLetter(w http.ResponseWriter, r *http.Request) {
+	entry, err := ws.deadLetters.Get(mux.Vars(r)["id"])
+	if err != nil {
+		ws.writeDeadLetterError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entry.Redacted(ws.secrets))
+}
+
+func (ws *WebhookServer) deleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := ws.deadLetters.Get(id); err != nil {
+		ws.writeDeadLetterError(w, err)
+		return
+	}
+
+	if err := ws.deadLetters.Remove(id); err != nil {
+		ws.writeDeadLetterError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ws *WebhookServer) purgeDeadLetters(w http.ResponseWriter, _ *http.Request) {
+	count, err := ws.deadLetters.Purge()
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusInternalServerError, err.Error(), "DEAD_LETTER_ERROR")
+		return
+	}
+
+	ws.logger.Info("Dead letters purged", "count", count)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"purged": count})
+}
+
+func (ws *WebhookServer) replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	result, err := ws.ReplayDeadLetter(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		ws.writeDeadLetterError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Success {
+		status = http.StatusBadGateway
+	}
+
+	writeJSON(w, status, result)
+}
+
+func (ws *WebhookServer) writeDeadLetterError(w http.ResponseWriter, err error) {
+	if errors.Is(err, deadletter.ErrNotFound) {
+		ws.writeErrorResponse(w, http.StatusNotFound, "Dead letter not found", "DEAD_LETTER_NOT_FOUND")
+		return
+	}
+
+	ws.writeErrorResponse(w, http.StatusInternalServerError, err.Error(), "DEAD_LETTER_ERROR")
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
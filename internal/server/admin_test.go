@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func adminConfig(dest, secretFile string) string {
+	return `
+variables:
+  token:
+    secret_file: ` + secretFile + `
+destinations:
+  admin_echo:
+    url: "` + dest + `/{{.params.channel}}"
+    body: '{"text": "{{.json.event}} {{.var.token}}"}'
+    retry:
+      initial_backoff: 1ms
+    signing:
+      secret: "{{.var.token}}"
+  admin_other: "` + dest + `/other"
+routes:
+  - path: "/admin-test/{channel}"
+    mode: async
+    matchers:
+      - expr: "true"
+        to: admin_echo
+`
+}
+
+func newAdminServer(t *testing.T, dest string, opts Options) *WebhookServer {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "token"), "s3cret-token")
+	opts.AdminToken = testAdminToken
+	if opts.AsyncQueueSize == 0 {
+		opts.AsyncWorkers, opts.AsyncQueueSize = 1, 10
+	}
+
+	return newTestServer(t, adminConfig(dest, filepath.Join(dir, "token")), opts)
+}
+
+func decodeJSON(t *testing.T, w interface{ Bytes() []byte }, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", w.Bytes(), err)
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	ws := newAdminServer(t, "http://localhost", Options{})
+
+	if w := send(ws, "GET", "/admin/config", ""); w.Code != http.StatusUnauthorized || errorCode(t, w) != "UNAUTHORIZED" {
+		t.Errorf("without token: status = %d", w.Code)
+	}
+	if w := send(ws, "GET", "/admin/config", "", "Authorization", "Bearer wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d", w.Code)
+	}
+	if w := sendAdmin(ws, "GET", "/admin/config"); w.Code != http.StatusOK {
+		t.Errorf("with token: status = %d", w.Code)
+	}
+
+	withoutToken := newTestServer(t, reloadConfig("http://localhost", "/hook"), Options{})
+	if w := sendAdmin(withoutToken, "GET", "/admin/config"); w.Code != http.StatusNotFound {
+		t.Errorf("admin without a configured token: status = %d", w.Code)
+	}
+}
+
+func TestAdminShowsRedactedConfig(t *testing.T) {
+	ws := newAdminServer(t, "http://localhost", Options{})
+
+	w := sendAdmin(ws, "GET", "/admin/config")
+	if strings.Contains(w.Body.String(), "s3cret-token") {
+		t.Errorf("config exposes the secret: %s", w.Body)
+	}
+
+	var shown struct {
+		Files  []string `json:"files"`
+		Config struct {
+			Variables    map[string]string `json:"variables"`
+			Destinations map[string]struct {
+				URL     string `json:"url"`
+				Signing struct {
+					Secret string `json:"secret"`
+				} `json:"signing"`
+			} `json:"destinations"`
+			Routes []map[string]any `json:"routes"`
+		} `json:"config"`
+	}
+	decodeJSON(t, w.Body, &shown)
+
+	if len(shown.Files) != 1 || shown.Config.Variables["token"] != "[REDACTED]" {
+		t.Errorf("files = %v, variables = %v", shown.Files, shown.Config.Variables)
+	}
+	if dest := shown.Config.Destinations["admin_echo"]; dest.Signing.Secret != "[REDACTED]" || !strings.HasSuffix(dest.URL, "/{{.params.channel}}") {
+		t.Errorf("destination = %+v", dest)
+	}
+	if len(shown.Config.Routes) != 1 || shown.Config.Routes[0]["mode"] != "async" {
+		t.Errorf("routes = %v", shown.Config.Routes)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	ws := newAdminServer(t, "http://localhost", Options{})
+
+	w := sendAdmin(ws, "POST", "/admin/reload")
+	var reloaded struct {
+		Status       string `json:"status"`
+		Destinations int    `json:"destinations"`
+		Routes       int    `json:"routes"`
+	}
+	decodeJSON(t, w.Body, &reloaded)
+	if w.Code != http.StatusOK || reloaded.Status != "reloaded" || reloaded.Destinations != 2 || reloaded.Routes != 1 {
+		t.Errorf("reload: status = %d, body %s", w.Code, w.Body)
+	}
+
+	writeFile(t, ws.configPath, "routes: [")
+	if w := sendAdmin(ws, "POST", "/admin/reload"); w.Code != http.StatusUnprocessableEntity || errorCode(t, w) != "RELOAD_FAILED" {
+		t.Errorf("broken reload: status = %d, body %s", w.Code, w.Body)
+	}
+	if len(ws.Config().Destinations) != 2 {
+		t.Error("broken reload replaced the config")
+	}
+}
+
+func TestAdminListsRoutesAndDestinations(t *testing.T) {
+	dest := newDestination(t)
+	ws := newAdminServer(t, dest.URL, Options{})
+
+	routeStats := func() RouteStatus {
+		var list struct {
+			Count  int           `json:"count"`
+			Routes []RouteStatus `json:"routes"`
+		}
+		decodeJSON(t, sendAdmin(ws, "GET", "/admin/routes").Body, &list)
+		if list.Count != 1 {
+			t.Fatalf("routes = %+v", list)
+		}
+		return list.Routes[0]
+	}
+	destinationStats := func() DestinationStatus {
+		var status DestinationStatus
+		decodeJSON(t, sendAdmin(ws, "GET", "/admin/destinations/admin_echo").Body, &status)
+		return status
+	}
+
+	// Metrics are shared by every server of the test binary, so compare against a baseline
+	routeBefore, destBefore := routeStats(), destinationStats()
+
+	send(ws, "POST", "/admin-test/general", `{"event": "deploy"}`)
+	if err := ws.WaitIdle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	route := routeStats()
+	if route.Mode != "async" || route.Matchers != 1 || len(route.Destinations) != 1 || route.Destinations[0] != "admin_echo" ||
+		route.Paths[0] != "/admin-test/{channel}" || route.Methods[0] != "POST" {
+		t.Errorf("route = %+v", route)
+	}
+	if route.Stats.Matched != routeBefore.Stats.Matched+1 {
+		t.Errorf("matched = %v, was %v", route.Stats.Matched, routeBefore.Stats.Matched)
+	}
+
+	status := destinationStats()
+	if status.Name != "admin_echo" || status.Method != "POST" || status.Paused || status.Circuit != "" {
+		t.Errorf("destination = %+v", status)
+	}
+	if status.Stats.Forwarded["success"] != destBefore.Stats.Forwarded["success"]+1 {
+		t.Errorf("forwarded = %v, was %v", status.Stats.Forwarded, destBefore.Stats.Forwarded)
+	}
+
+	var list struct {
+		Count        int                 `json:"count"`
+		Destinations []DestinationStatus `json:"destinations"`
+	}
+	decodeJSON(t, sendAdmin(ws, "GET", "/admin/destinations").Body, &list)
+	if list.Count != 2 || list.Destinations[0].Name != "admin_echo" || list.Destinations[1].Name != "admin_other" {
+		t.Errorf("destinations = %+v", list)
+	}
+
+	if w := sendAdmin(ws, "GET", "/admin/destinations/missing"); w.Code != http.StatusNotFound || errorCode(t, w) != "DESTINATION_NOT_FOUND" {
+		t.Errorf("unknown destination: status = %d", w.Code)
+	}
+}
+
+func TestAdminPauseAndResume(t *testing.T) {
+	dest := newDestination(t)
+	ws := newAdminServer(t, dest.URL, Options{})
+
+	w := sendAdmin(ws, "POST", "/admin/destinations/admin_echo/pause")
+	var status DestinationStatus
+	decodeJSON(t, w.Body, &status)
+	if w.Code != http.StatusOK || !status.Paused || status.PausedSince == nil {
+		t.Fatalf("pause: status = %d, body %s", w.Code, w.Body)
+	}
+
+	send(ws, "POST", "/admin-test/general", `{"event": "deploy"}`)
+	if err := ws.WaitIdle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ws.processRetries(context.Background())
+
+	if n := len(dest.received()); n != 0 {
+		t.Fatalf("paused destination received %d requests", n)
+	}
+	decodeJSON(t, sendAdmin(ws, "GET", "/admin/destinations/admin_echo").Body, &status)
+	if status.Queued != 1 {
+		t.Errorf("queued = %d", status.Queued)
+	}
+
+	decodeJSON(t, sendAdmin(ws, "POST", "/admin/destinations/admin_echo/resume").Body, &status)
+	if status.Paused {
+		t.Error("still paused after resume")
+	}
+
+	eventually(t, func() bool {
+		ws.processRetries(context.Background())
+		return ws.retries.Len() == 0
+	})
+	if got := dest.received(); len(got) != 1 || got[0].Path != "/general" {
+		t.Errorf("destination received %+v", got)
+	}
+	if entries, _ := ws.deadLetters.List(); len(entries) != 0 {
+		t.Errorf("dead letters = %+v", entries)
+	}
+
+	if w := sendAdmin(ws, "POST", "/admin/destinations/missing/pause"); w.Code != http.StatusNotFound {
+		t.Errorf("pause unknown destination: status = %d", w.Code)
+	}
+}
+
+func TestAdminTestFire(t *testing.T) {
+	dest := newDestination(t, http.StatusOK, http.StatusInternalServerError)
+	ws := newAdminServer(t, dest.URL, Options{})
+
+	// Pauses are bypassed by test fires
+	sendAdmin(ws, "POST", "/admin/destinations/admin_echo/pause")
+
+	w := send(ws, "POST", "/admin/destinations/admin_echo/test?channel=ops", `{"event": "deploy"}`,
+		"Authorization", "Bearer "+testAdminToken, "Content-Type", "application/json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "s3cret-token") {
+		t.Errorf("response exposes the secret: %s", w.Body)
+	}
+
+	var delivery TestDelivery
+	decodeJSON(t, w.Body, &delivery)
+	if delivery.Request.URL != dest.URL+"/ops" || delivery.Request.Body != `{"text": "deploy [REDACTED]"}` || !delivery.Result.Success {
+		t.Errorf("delivery = %+v", delivery)
+	}
+
+	got := dest.received()
+	if len(got) != 1 || got[0].Body != `{"text": "deploy s3cret-token"}` || got[0].Header.Get("X-Signature") == "" {
+		t.Errorf("destination received %+v", got)
+	}
+
+	if w := sendAdmin(ws, "POST", "/admin/destinations/admin_other/test"); w.Code != http.StatusBadGateway {
+		t.Errorf("failing destination: status = %d, body %s", w.Code, w.Body)
+	}
+	if entries, _ := ws.deadLetters.List(); len(entries) != 0 {
+		t.Errorf("failed test fire was dead-lettered: %+v", entries)
+	}
+	if w := sendAdmin(ws, "POST", "/admin/destinations/missing/test"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown destination: status = %d", w.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const catchAllConfig = `
+destinations:
+  echo: "%s"
+routes:
+  - path: "/{name}"
+    methods: [GET, POST]
+    matchers:
+      - expr: "true"
+        to: echo
+`
+
+func TestSeparateAdminKeepsPublicRouterToWebhooks(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, reloadConfig(dest.URL, "/hook"), Options{SeparateAdmin: true, AdminToken: testAdminToken})
+
+	for _, target := range []string{"/health", "/livez", "/readyz", "/metrics", "/debug/pprof/", "/admin/config"} {
+		if w := sendAdmin(ws, "GET", target); w.Code != http.StatusNotFound {
+			t.Errorf("public %s: status = %d", target, w.Code)
+		}
+	}
+	if w := send(ws, "POST", "/hook", `{}`); w.Code != http.StatusOK {
+		t.Errorf("webhook: status = %d, body %s", w.Code, w.Body)
+	}
+
+	admin := ws.AdminHandler()
+	for _, target := range []string{"/health", "/livez", "/readyz", "/metrics", "/debug/pprof/", "/admin/config"} {
+		if w := sendAdmin(admin, "GET", target); w.Code != http.StatusOK {
+			t.Errorf("admin %s: status = %d", target, w.Code)
+		}
+	}
+	if w := send(admin, "GET", "/admin/config", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("admin without token: status = %d", w.Code)
+	}
+	if w := send(admin, "POST", "/hook", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("webhook on admin listener: status = %d", w.Code)
+	}
+}
+
+func TestSeparateAdminLeavesPathsToCatchAllRoutes(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, fmt.Sprintf(catchAllConfig, dest.URL), Options{SeparateAdmin: true})
+
+	if w := send(ws, "GET", "/metrics", ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+
+	// Reloads rebuild the public router without the operational endpoints too
+	if err := ws.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	send(ws, "GET", "/health", "")
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("after reload destination received %d requests", n)
+	}
+}
+
+func TestSharedListenerServesOperationalEndpoints(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, fmt.Sprintf(catchAllConfig, dest.URL), Options{})
+
+	for _, target := range []string{"/health", "/metrics"} {
+		if w := send(ws, "GET", target, ""); w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d", target, w.Code)
+		}
+	}
+	if n := len(dest.received()); n != 0 {
+		t.Errorf("operational endpoints were forwarded %d times", n)
+	}
+
+	// Profiling is never exposed on the webhook port
+	if w := send(ws, "GET", "/debug/pprof/", ""); w.Code != http.StatusNotFound {
+		t.Errorf("pprof: status = %d", w.Code)
+	}
+}
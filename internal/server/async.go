@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// acceptWebhook hands the delivery to the background worker pool and answers
+// the caller right away with the accepted status and the delivery IDs.
+func (ws *WebhookServer) acceptWebhook(w http.ResponseWriter, r *http.Request, route *configApi.Route, destinations []configApi.ResolvedDestination, templateCtx templateRenderer.TemplateContext, meta configApi.RequestMeta, logger *slog.Logger) {
+	start := meta.ReceivedAt
+
+	spanCtx := trace.SpanContextFromContext(r.Context())
+	err := ws.async.Submit(func(ctx context.Context) {
+		ctx = trace.ContextWithSpanContext(ctx, spanCtx)
+		results := ws.forwardToDestinations(ctx, destinations, meta, logger)
+		successCount := ws.recordProcessed(results)
+
+		logger.Info("Async webhook processed",
+			"params", templateCtx.Params,
+			"total_destinations", len(destinations),
+			"successful", successCount,
+			"duration", time.Since(start))
+	})
+	if err != nil {
+		logger.Error("Failed to accept async webhook", "error", err)
+		ws.metrics.WebhooksProcessed.WithLabelValues("rejected").Inc()
+		ws.writeErrorResponse(w, http.StatusServiceUnavailable, "Server is busy, try again later", "ASYNC_QUEUE_FULL")
+		return
+	}
+
+	duration := time.Since(start)
+	logger.Info("Webhook accepted",
+		"params", templateCtx.Params,
+		"total_destinations", len(destinations),
+		"pending", ws.async.Pending(),
+		"duration", duration)
+
+	responseData := &ResponseData{
+		Destinations: destinations,
+		Duration:     duration,
+		Params:       templateCtx.Params,
+		Variables:    templateCtx.Variables,
+		Body:         templateCtx.Body,
+		Parsed:       templateCtx.Parsed,
+		Templates:    templateCtx.Templates,
+		Request:      r,
+		Auth:         templateCtx.Auth,
+		Async:        true,
+		Deliveries:   deliveryRefs(destinations),
+		RequestID:    meta.ID,
+	}
+
+	handler := NewResponseHandler(route, responseData)
+	_, renderSpan := tracer.Start(r.Context(), "render response")
+	err = handler.SendResponse(w)
+	ws.endSpan(renderSpan, err)
+	if err != nil {
+		ws.writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate response", "RESPONSE_ERROR")
+		return
+	}
+}
+
+// WaitIdle waits until the async deliveries accepted so far have been
+// forwarded, or until ctx expires. The server keeps accepting deliveries.
+func (ws *WebhookServer) WaitIdle(ctx context.Context) error {
+	return ws.async.Wait(ctx)
+}
+
+// Shutdown stops accepting async deliveries and waits for the queued ones to
+// be forwarded, or until ctx expires.
+func (ws *WebhookServer) Shutdown(ctx context.Context) error {
+	ws.Drain()
+
+	pending := ws.async.Pending()
+	if pending > 0 {
+		ws.logger.Info("Draining async deliveries", "pending", pending)
+	}
+
+	if err := ws.async.Shutdown(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			ws.logger.Warn("Timed out draining async deliveries", "pending", ws.async.Pending())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// deliveryRefs lists the delivery ID of each destination, the ID its retry
+// queue and dead-letter entries are stored under and signed requests carry
+func deliveryRefs(destinations []configApi.ResolvedDestination) []DeliveryRef {
+	refs := make([]DeliveryRef, len(destinations))
+	for i, dest := range destinations {
+		refs[i] = DeliveryRef{Destination: dest.Name, ID: dest.ID}
+	}
+	return refs
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func asyncConfig(dest string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `"
+    retry:
+      initial_backoff: 1h
+routes:
+  - path: "/hook"
+    mode: async
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestAsyncRouteAcceptsBeforeForwarding(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, asyncConfig(dest.URL), Options{AsyncWorkers: 1, AsyncQueueSize: 10})
+
+	w := send(ws, "POST", "/hook", `{"a":1}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	var response struct {
+		Accepted   bool          `json:"accepted"`
+		Deliveries []DeliveryRef `json:"deliveries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Accepted || len(response.Deliveries) != 1 || response.Deliveries[0].Destination != "echo" || response.Deliveries[0].ID == "" {
+		t.Fatalf("response = %s", w.Body)
+	}
+
+	if requests := dest.waitFor(t, 1); requests[0].Body != `{"a":1}` {
+		t.Errorf("destination received %+v", requests)
+	}
+}
+
+func TestAsyncDeliveryIDMatchesQueuedDelivery(t *testing.T) {
+	dest := newDestination(t, http.StatusServiceUnavailable)
+	ws := newTestServer(t, asyncConfig(dest.URL), Options{AsyncWorkers: 1, AsyncQueueSize: 10})
+
+	w := send(ws, "POST", "/hook", `{}`)
+	var response struct {
+		Deliveries []DeliveryRef `json:"deliveries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || len(response.Deliveries) != 1 {
+		t.Fatalf("response = %s", w.Body)
+	}
+
+	if err := ws.WaitIdle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.retries.Get(response.Deliveries[0].ID); err != nil {
+		t.Errorf("queued delivery %s: %v", response.Deliveries[0].ID, err)
+	}
+}
+
+func TestDispatcherRejectsWhenFull(t *testing.T) {
+	d := newDispatcher(1, 1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := d.Submit(func(context.Context) { close(started); <-release }); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	var ran atomic.Int32
+	if err := d.Submit(func(context.Context) { ran.Add(1) }); err != nil {
+		t.Fatalf("queued job: %v", err)
+	}
+	if err := d.Submit(func(context.Context) { ran.Add(1) }); !errors.Is(err, errDispatcherFull) {
+		t.Fatalf("job over the queue size: %v", err)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ran.Load() != 1 {
+		t.Errorf("queued jobs run = %d", ran.Load())
+	}
+	if err := d.Submit(func(context.Context) {}); !errors.Is(err, errDispatcherClosed) {
+		t.Errorf("Submit after Shutdown: %v", err)
+	}
+}
+
+func TestDispatcherShutdownCancelsAfterTimeout(t *testing.T) {
+	d := newDispatcher(1, 0)
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	job := func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}
+	err := d.Submit(job)
+	for errors.Is(err, errDispatcherFull) {
+		// The worker isn't waiting for jobs yet
+		time.Sleep(time.Millisecond)
+		err = d.Submit(job)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v", err)
+	}
+	<-cancelled
+}
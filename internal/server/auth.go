@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/auth"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"github.com/framjet/go-webhook-middleman/internal/verify"
+	"net/http"
+	"net/url"
+)
+
+// authenticateRequest checks the credentials configured on the route and
+// returns the error code to report when they are rejected.
+func (ws *WebhookServer) authenticateRequest(cfg *configApi.AuthConfig, r *http.Request, ctx templateRenderer.TemplateContext) (*configApi.AuthInfo, string, error) {
+	render := func(tmpl string) (string, error) {
+		return templateRenderer.RenderSecret(tmpl, ctx)
+	}
+
+	info, err := auth.Authenticate(cfg, render, r)
+	switch {
+	case err == nil:
+		return info, "", nil
+	case errors.Is(err, auth.ErrMisconfigured):
+		return nil, "AUTH_CONFIG_ERROR", err
+	case errors.Is(err, auth.ErrMissingCredentials):
+		return nil, "AUTH_MISSING", err
+	default:
+		return nil, "AUTH_INVALID", err
+	}
+}
+
+// stripCredentials removes the credentials the route's auth and verify read
+// from the request passed on to destinations and kept with queued deliveries
+func stripCredentials(route *configApi.Route, meta *configApi.RequestMeta) {
+	var headers []string
+	if route.Auth != nil {
+		headers = append(headers, auth.Headers(route.Auth)...)
+	}
+	if route.Verify != nil {
+		headers = append(headers, verify.Headers(route.Verify)...)
+	}
+	for _, header := range headers {
+		meta.Headers.Del(header)
+	}
+
+	if route.Auth != nil && route.Auth.APIKey != nil && route.Auth.APIKey.Query != "" {
+		if u, err := url.Parse(meta.URL); err == nil && u.Query().Has(route.Auth.APIKey.Query) {
+			query := u.Query()
+			query.Del(route.Auth.APIKey.Query)
+			u.RawQuery = query.Encode()
+			meta.URL = u.String()
+		}
+	}
+}
+
+// writeAuthChallenge announces the accepted schemes on a 401 response
+func writeAuthChallenge(w http.ResponseWriter, cfg *configApi.AuthConfig) {
+	if cfg.Basic != nil {
+		w.Header().Add("WWW-Authenticate", `Basic realm="`+cfg.Basic.GetRealm()+`"`)
+	}
+	if cfg.Bearer != nil || cfg.JWT != nil {
+		w.Header().Add("WWW-Authenticate", "Bearer")
+	}
+}
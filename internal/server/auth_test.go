@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func authConfig(dest, key string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `/{{.auth.subject}}"
+    retry:
+      initial_backoff: 1h
+variables:
+  ci_key: "` + key + `"
+routes:
+  - path: "/deploy"
+    auth:
+      api_key:
+        header: "X-API-Key"
+        query: "api_key"
+        keys:
+          ci: "{{.var.ci_key}}"
+      bearer:
+        tokens:
+          grafana: "t0ken"
+    verify:
+      provider: gitlab
+      secret: "gitlab-token"
+    matchers:
+      - expr: 'auth.method in ["api_key", "bearer"]'
+        to: echo
+`
+}
+
+func TestAuthenticatedCallerIsForwarded(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, authConfig(dest.URL, "k3y"), Options{})
+
+	if w := send(ws, "POST", "/deploy", `{}`, "X-API-Key", "k3y", "X-Gitlab-Token", "gitlab-token"); w.Code != http.StatusOK {
+		t.Fatalf("api key: status = %d, body %s", w.Code, w.Body)
+	}
+	if w := send(ws, "POST", "/deploy", `{}`, "Authorization", "Bearer t0ken", "X-Gitlab-Token", "gitlab-token"); w.Code != http.StatusOK {
+		t.Fatalf("bearer: status = %d, body %s", w.Code, w.Body)
+	}
+
+	requests := dest.received()
+	if len(requests) != 2 || requests[0].Path != "/ci" || requests[1].Path != "/grafana" {
+		t.Fatalf("destination received %+v", requests)
+	}
+}
+
+func TestRejectedCredentials(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, authConfig(dest.URL, "k3y"), Options{})
+
+	w := send(ws, "POST", "/deploy", `{}`, "X-Gitlab-Token", "gitlab-token")
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "AUTH_MISSING" || w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("no credentials: status = %d, challenge %q, body %s", w.Code, w.Header().Get("WWW-Authenticate"), w.Body)
+	}
+	w = send(ws, "POST", "/deploy", `{}`, "X-API-Key", "nope", "X-Gitlab-Token", "gitlab-token")
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "AUTH_INVALID" {
+		t.Errorf("wrong key: status = %d, body %s", w.Code, w.Body)
+	}
+	if n := len(dest.received()); n != 0 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestEmptyAuthKeyIsRejected(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, authConfig(dest.URL, ""), Options{})
+
+	w := send(ws, "POST", "/deploy?api_key=", `{}`, "X-API-Key", "", "X-Gitlab-Token", "gitlab-token")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	w = send(ws, "POST", "/deploy", `{}`, "X-API-Key", "anything", "X-Gitlab-Token", "gitlab-token")
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "AUTH_CONFIG_ERROR" {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestCredentialsAreNotForwarded(t *testing.T) {
+	dest := newDestination(t, http.StatusServiceUnavailable)
+	ws := newTestServer(t, authConfig(dest.URL, "k3y"), Options{})
+
+	send(ws, "POST", "/deploy?api_key=k3y&env=prod", `{}`,
+		"X-API-Key", "k3y",
+		"Authorization", "Bearer t0ken",
+		"X-Gitlab-Token", "gitlab-token",
+		"X-Custom", "kept")
+
+	requests := dest.received()
+	if len(requests) != 1 {
+		t.Fatalf("destination received %+v", requests)
+	}
+	for _, header := range []string{"X-API-Key", "Authorization", "X-Gitlab-Token"} {
+		if value := requests[0].Header.Get(header); value != "" {
+			t.Errorf("%s forwarded as %q", header, value)
+		}
+	}
+	if requests[0].Header.Get("X-Custom") != "kept" {
+		t.Errorf("X-Custom not forwarded: %v", requests[0].Header)
+	}
+
+	queued, err := ws.retries.List()
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("queued = %+v, %v", queued, err)
+	}
+	meta := queued[0].Request
+	if strings.Contains(meta.URL, "k3y") || !strings.Contains(meta.URL, "env=prod") {
+		t.Errorf("queued URL = %s", meta.URL)
+	}
+	for _, header := range []string{"X-API-Key", "Authorization", "X-Gitlab-Token"} {
+		if meta.Headers.Get(header) != "" {
+			t.Errorf("%s kept with the queued delivery", header)
+		}
+	}
+}
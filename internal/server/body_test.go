@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMatchersAndTemplatesSeeParsedBody(t *testing.T) {
+	opened := newDestination(t)
+	deploys := newDestination(t)
+	ws := newTestServer(t, `
+destinations:
+  opened:
+    url: "`+opened.URL+`"
+    body: '{"pr": {{.json.number}}, "by": "{{.json.sender.login}}"}'
+  deploys:
+    url: "`+deploys.URL+`"
+    body: '{"service": "{{index .form "text" 0}}"}'
+routes:
+  - path: "/hook"
+    response:
+      body: '{"action": "{{with .json}}{{.action}}{{end}}{{with .form}}{{index . "command" 0}}{{end}}"}'
+    matchers:
+      - expr: 'request.json?.action == "opened"'
+        to: opened
+      - expr: 'request.form?.command?.[0] == "/deploy"'
+        to: deploys
+`, Options{})
+
+	w := send(ws, "POST", "/hook", `{"action": "opened", "number": 7, "sender": {"login": "octocat"}}`, "Content-Type", "application/json")
+	if w.Code != http.StatusOK || w.Body.String() != `{"action": "opened"}` {
+		t.Errorf("json: status = %d, body %s", w.Code, w.Body)
+	}
+	if got := opened.received(); len(got) != 1 || got[0].Body != `{"pr": 7, "by": "octocat"}` {
+		t.Errorf("opened received %+v", got)
+	}
+
+	w = send(ws, "POST", "/hook", "command=%2Fdeploy&text=api", "Content-Type", "application/x-www-form-urlencoded")
+	if w.Code != http.StatusOK || w.Body.String() != `{"action": "/deploy"}` {
+		t.Errorf("form: status = %d, body %s", w.Code, w.Body)
+	}
+	if got := deploys.received(); len(got) != 1 || got[0].Body != `{"service": "api"}` {
+		t.Errorf("deploys received %+v", got)
+	}
+
+	if w := send(ws, "POST", "/hook", `not json`, "Content-Type", "application/json"); w.Code != http.StatusNotFound {
+		t.Errorf("unparsable body: status = %d", w.Code)
+	}
+	if n := len(opened.received()) + len(deploys.received()); n != 2 {
+		t.Errorf("destinations received %d requests", n)
+	}
+}
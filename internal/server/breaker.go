@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"github.com/framjet/go-webhook-middleman/internal/breaker"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+func (ws *WebhookServer) onCircuitChange(name string, from, to breaker.State) {
+	ws.metrics.CircuitState.WithLabelValues(name).Set(float64(to))
+
+	logger := ws.logger.With("destination", name, "from", from.String(), "to", to.String())
+	if to == breaker.StateOpen {
+		logger.Warn("Circuit breaker opened")
+	} else {
+		logger.Info("Circuit breaker state changed")
+	}
+}
+
+// circuitBreakerConfig returns the breaker settings of a named destination in
+// the active config, or nil if it has none
+func (ws *WebhookServer) circuitBreakerConfig(name string) *configApi.CircuitBreakerConfig {
+	dest, ok := ws.Config().Destinations[name]
+	if !ok || dest.CircuitBreaker == nil {
+		return nil
+	}
+
+	cfg := dest.CircuitBreaker.WithDefaults()
+
+	return &cfg
+}
+
+// circuitBreaker returns the breaker of a named destination, or nil if it has none
+func (ws *WebhookServer) circuitBreaker(name string) *breaker.Breaker {
+	cfg := ws.circuitBreakerConfig(name)
+	if cfg == nil {
+		return nil
+	}
+
+	return ws.breakers.Get(name, breaker.Settings{
+		FailureRatio:     cfg.FailureRatio,
+		MinRequests:      cfg.MinRequests,
+		Window:           cfg.Window,
+		Cooldown:         cfg.Cooldown,
+		HalfOpenRequests: cfg.HalfOpenRequests,
+	})
+}
+
+// forwardToDestination sends the request once the destination's throttle
+// allows it and through its circuit breaker, failing fast while the circuit is
+// open or the destination is paused. Each call is recorded as a client span.
+func (ws *WebhookServer) forwardToDestination(ctx context.Context, dest configApi.ResolvedDestination, headers http.Header, logger *slog.Logger) configApi.ForwardResult {
+	ctx, span := ws.startForwardSpan(ctx, dest)
+	result := ws.forward(ctx, dest, headers, logger)
+	ws.endForwardSpan(span, result)
+
+	return result
+}
+
+func (ws *WebhookServer) forward(ctx context.Context, dest configApi.ResolvedDestination, headers http.Header, logger *slog.Logger) configApi.ForwardResult {
+	if _, paused := ws.paused.pausedSince(dest.Name); paused {
+		return ws.pausedResult(dest, logger)
+	}
+
+	limiter := ws.throttle(dest)
+
+	waitStart := time.Now()
+	release, err := limiter.Acquire(ctx)
+	ws.metrics.ThrottleWait.WithLabelValues(dest.Name).Observe(time.Since(waitStart).Seconds())
+	if err != nil {
+		return ws.throttledResult(dest, limiter, err, logger)
+	}
+	defer release()
+
+	result := ws.sendThroughBreaker(ctx, dest, headers, logger)
+	if !result.CircuitOpen {
+		ws.outcomes.Record(dest.Name, result.Success, time.Now())
+	}
+
+	if result.RetryAfter != nil {
+		limiter.Block(*result.RetryAfter)
+		ws.metrics.ThrottledTotal.WithLabelValues(dest.Name, "upstream").Inc()
+		logger.Warn("Destination asked to slow down",
+			"destination", dest.Name,
+			"url", dest.URL,
+			"retry_after", *result.RetryAfter)
+	}
+
+	return result
+}
+
+func (ws *WebhookServer) sendThroughBreaker(ctx context.Context, dest configApi.ResolvedDestination, headers http.Header, logger *slog.Logger) configApi.ForwardResult {
+	cb := ws.circuitBreaker(dest.Name)
+	if cb == nil {
+		return ws.sendToDestination(ctx, dest, headers, logger)
+	}
+
+	if !cb.Allow() {
+		logger.Warn("Circuit breaker open, skipping destination",
+			"destination", dest.Name,
+			"url", dest.URL,
+			"retry_at", cb.RetryAt())
+		ws.metrics.ForwardingTotal.WithLabelValues(dest.Name, "circuit_open").Inc()
+		return configApi.ForwardResult{
+			Destination: dest.Name,
+			URL:         dest.URL,
+			Method:      dest.Method,
+			Headers:     dest.Headers,
+			Success:     false,
+			Error:       "circuit breaker open",
+			CircuitOpen: true,
+		}
+	}
+
+	result := ws.sendToDestination(ctx, dest, headers, logger)
+
+	// A request cancelled on our side, such as by a caller hanging up on a
+	// sync route, says nothing about the destination
+	if ctx.Err() != nil {
+		cb.Abandon()
+		return result
+	}
+
+	// Only server-side failures count against the destination, a 4xx means it is up
+	cb.Record(result.StatusCode != 0 && result.StatusCode < 500)
+
+	return result
+}
+
+// circuitRetryAt returns the earliest time a delivery to the destination is
+// worth attempting again
+func (ws *WebhookServer) circuitRetryAt(name string, fallback time.Time) time.Time {
+	cb := ws.circuitBreaker(name)
+	if cb == nil {
+		return fallback
+	}
+
+	if retryAt := cb.RetryAt(); retryAt.After(fallback) {
+		return retryAt
+	}
+
+	return fallback
+}
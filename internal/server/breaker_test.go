@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"github.com/framjet/go-webhook-middleman/internal/breaker"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func breakerConfig(dest string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `"
+    circuit_breaker:
+      min_requests: 2
+      cooldown: 1h
+      on_open: fail
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestOpenCircuitSkipsDestination(t *testing.T) {
+	dest := newDestination(t, http.StatusInternalServerError)
+	ws := newTestServer(t, breakerConfig(dest.URL), Options{})
+
+	send(ws, "POST", "/hook", `{}`)
+	send(ws, "POST", "/hook", `{}`)
+	if state := ws.circuitBreaker("echo").State(); state != breaker.StateOpen {
+		t.Fatalf("state = %s", state)
+	}
+
+	send(ws, "POST", "/hook", `{}`)
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("destination received %d requests with the circuit open", n)
+	}
+	if n := ws.retries.Len(); n != 0 {
+		t.Errorf("on_open fail queued %d deliveries", n)
+	}
+}
+
+func TestClientErrorsDontOpenCircuit(t *testing.T) {
+	dest := newDestination(t, http.StatusNotFound)
+	ws := newTestServer(t, breakerConfig(dest.URL), Options{})
+
+	for range 3 {
+		send(ws, "POST", "/hook", `{}`)
+	}
+
+	if state := ws.circuitBreaker("echo").State(); state != breaker.StateClosed {
+		t.Errorf("state = %s", state)
+	}
+}
+
+func TestCancelledRequestsDontOpenCircuit(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, breakerConfig(dest.URL), Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	target := configApi.ResolvedDestination{Name: "echo", URL: dest.URL, Method: "POST"}
+	for range 3 {
+		if result := ws.forwardToDestination(ctx, target, http.Header{}, logger); result.Success {
+			t.Fatal("cancelled request succeeded")
+		}
+	}
+
+	if state := ws.circuitBreaker("echo").State(); state != breaker.StateClosed {
+		t.Errorf("state = %s", state)
+	}
+}
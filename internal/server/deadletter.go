@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/deadletter"
+	"github.com/framjet/go-webhook-middleman/internal/queue"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// DeadLetterDir returns where dead-lettered deliveries are kept inside the data directory
+func DeadLetterDir(dataDir string) string {
+	return filepath.Join(dataDir, "deadletter")
+}
+
+func deadLetterFromDelivery(d *queue.Delivery) *deadletter.Entry {
+	return &deadletter.Entry{
+		ID:          d.ID,
+		Destination: d.Destination,
+		Request:     d.Request,
+		Attempts:    d.Attempts,
+		LastError:   d.LastError,
+		LastStatus:  d.LastStatus,
+		CreatedAt:   d.CreatedAt,
+	}
+}
+
+// deadLetter keeps a delivery that won't be retried any more so it can be inspected and replayed
+func (ws *WebhookServer) deadLetter(entry *deadletter.Entry, logger *slog.Logger) {
+	entry.FailedAt = time.Now()
+
+	if err := ws.deadLetters.Put(entry); err != nil {
+		logger.Error("Failed to store dead letter, delivery is lost",
+			"dead_letter_id", entry.ID,
+			"destination", entry.Destination.Name,
+			"url", entry.Destination.URL,
+			"error", err)
+		return
+	}
+
+	ws.metrics.DeadLettersTotal.WithLabelValues(entry.Destination.Name).Inc()
+	logger.Warn("Delivery dead-lettered",
+		"dead_letter_id", entry.ID,
+		"destination", entry.Destination.Name,
+		"url", entry.Destination.URL,
+		"attempts", entry.Attempts)
+}
+
+// DeadLetters returns the dead-letter store
+func (ws *WebhookServer) DeadLetters() *deadletter.Store {
+	return ws.deadLetters
+}
+
+// ReplayDeadLetter forwards a dead-lettered delivery once more. On success it is
+// removed from the store, otherwise the entry is updated with the new error.
+// Secret values are redacted from the returned result.
+func (ws *WebhookServer) ReplayDeadLetter(ctx context.Context, id string) (configApi.ForwardResult, error) {
+	entry, err := ws.deadLetters.Get(id)
+	if err != nil {
+		return configApi.ForwardResult{}, err
+	}
+
+	logger := ws.logger.With("dead_letter_id", entry.ID)
+	ctx = traceContextFrom(ctx, entry.Request.Headers)
+	dest := entry.Destination
+	var result configApi.ForwardResult
+	if err := ws.restoreSigning(&dest, entry.Request); err != nil {
+		result = ws.requestError(dest, time.Now(), "failed to sign request", err, logger)
+	} else {
+		result = ws.forwardToDestination(ctx, dest, entry.Request.Headers, logger)
+	}
+	entry.Replays++
+	result.Attempts = entry.Attempts + entry.Replays
+
+	if result.Success {
+		logger.Info("Dead letter replayed", "destination", entry.Destination.Name, "url", entry.Destination.URL)
+		if err := ws.deadLetters.Remove(entry.ID); err != nil {
+			return ws.redactResult(result), fmt.Errorf("replayed but failed to remove dead letter: %w", err)
+		}
+		return ws.redactResult(result), nil
+	}
+
+	entry.LastError = result.Error
+	entry.LastStatus = result.StatusCode
+	entry.FailedAt = time.Now()
+	logger.Warn("Dead letter replay failed", "destination", entry.Destination.Name, "url", entry.Destination.URL, "error", result.Error)
+
+	if err := ws.deadLetters.Put(entry); err != nil {
+		return ws.redactResult(result), fmt.Errorf("failed to update dead letter: %w", err)
+	}
+
+	return ws.redactResult(result), nil
+}
+
+// redactResult redacts secret values from the outcome of a delivery for
+// showing it to the caller
+func (ws *WebhookServer) redactResult(result configApi.ForwardResult) configApi.ForwardResult {
+	result.URL = ws.secrets.Redact(result.URL)
+	result.Headers = ws.redactHeaders(result.Headers)
+	result.Error = ws.secrets.Redact(result.Error)
+	return result
+}
+
+func (ws *WebhookServer) redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		redacted[name] = ws.secrets.Header(name, value)
+	}
+
+	return redacted
+}
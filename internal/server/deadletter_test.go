@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testAdminToken = "admin-secret"
+
+func deadLetterConfig(dest string) string {
+	return `
+destinations:
+  echo: "` + dest + `"
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+// sendAdmin serves an admin request carrying the test admin token
+func sendAdmin(ws http.Handler, method, target string) *httptest.ResponseRecorder {
+	return send(ws, method, target, "", "Authorization", "Bearer "+testAdminToken)
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	dest := newDestination(t, http.StatusBadRequest, http.StatusInternalServerError, http.StatusOK)
+	ws := newTestServer(t, deadLetterConfig(dest.URL), Options{AdminToken: testAdminToken})
+
+	send(ws, "POST", "/hook", `{"a":1}`)
+
+	w := sendAdmin(ws, "GET", "/admin/deadletters")
+	var list struct {
+		Count       int `json:"count"`
+		DeadLetters []struct {
+			ID         string `json:"id"`
+			LastStatus int    `json:"last_status"`
+		} `json:"dead_letters"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 1 || list.DeadLetters[0].LastStatus != http.StatusBadRequest {
+		t.Fatalf("dead letters = %s", w.Body)
+	}
+	id := list.DeadLetters[0].ID
+
+	if w := sendAdmin(ws, "GET", "/admin/deadletters/"+id); w.Code != http.StatusOK {
+		t.Errorf("show: status = %d", w.Code)
+	}
+
+	// A failed replay keeps the entry and counts the attempt
+	if w := sendAdmin(ws, "POST", "/admin/deadletters/"+id+"/replay"); w.Code != http.StatusBadGateway {
+		t.Fatalf("failed replay: status = %d, body %s", w.Code, w.Body)
+	}
+	entry, err := ws.deadLetters.Get(id)
+	if err != nil || entry.Replays != 1 || entry.LastStatus != http.StatusInternalServerError {
+		t.Fatalf("entry after failed replay = %+v, %v", entry, err)
+	}
+
+	if w := sendAdmin(ws, "POST", "/admin/deadletters/"+id+"/replay"); w.Code != http.StatusOK {
+		t.Fatalf("replay: status = %d, body %s", w.Code, w.Body)
+	}
+	if w := sendAdmin(ws, "GET", "/admin/deadletters/"+id); w.Code != http.StatusNotFound {
+		t.Errorf("replayed entry: status = %d", w.Code)
+	}
+
+	requests := dest.received()
+	if len(requests) != 3 || requests[2].Body != `{"a":1}` {
+		t.Errorf("destination received %+v", requests)
+	}
+}
+
+func TestDeadLetterDeleteAndPurge(t *testing.T) {
+	dest := newDestination(t, http.StatusBadRequest)
+	ws := newTestServer(t, deadLetterConfig(dest.URL), Options{AdminToken: testAdminToken})
+
+	send(ws, "POST", "/hook", `{}`)
+	send(ws, "POST", "/hook", `{}`)
+	entries, err := ws.deadLetters.List()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("dead letters = %+v, %v", entries, err)
+	}
+
+	if w := sendAdmin(ws, "DELETE", "/admin/deadletters/"+entries[0].ID); w.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", w.Code)
+	}
+	if w := sendAdmin(ws, "DELETE", "/admin/deadletters/"+entries[0].ID); w.Code != http.StatusNotFound {
+		t.Errorf("delete of a missing entry: status = %d", w.Code)
+	}
+
+	w := sendAdmin(ws, "DELETE", "/admin/deadletters")
+	if w.Code != http.StatusOK || w.Body.String() != "{\"purged\":1}\n" {
+		t.Errorf("purge: status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestDeadLetterAdminRedactsSecrets(t *testing.T) {
+	t.Setenv("WEBHOOK_DLQ_TOKEN", "s3cret-token")
+	dest := newDestination(t, http.StatusBadRequest)
+	ws := newTestServer(t, `
+variables:
+  token:
+    secret_env: WEBHOOK_DLQ_TOKEN
+destinations:
+  echo:
+    url: "`+dest.URL+`/hook?token={{.var.token}}"
+    body: '{"token": "{{.var.token}}"}'
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to:
+          - name: echo
+            headers:
+              Authorization: "Bearer {{.var.token}}"
+              X-Api-Key: literal-key
+`, Options{AdminToken: testAdminToken})
+
+	send(ws, "POST", "/hook", `{}`)
+	entries, err := ws.deadLetters.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("dead letters = %+v, %v", entries, err)
+	}
+	id := entries[0].ID
+
+	for _, tc := range []struct{ method, target string }{
+		{"GET", "/admin/deadletters"},
+		{"GET", "/admin/deadletters/" + id},
+		{"POST", "/admin/deadletters/" + id + "/replay"},
+	} {
+		w := sendAdmin(ws, tc.method, tc.target)
+		if strings.Contains(w.Body.String(), "s3cret-token") || strings.Contains(w.Body.String(), "literal-key") {
+			t.Errorf("%s %s shows a secret: %s", tc.method, tc.target, w.Body)
+		}
+		if !strings.Contains(w.Body.String(), "token=[REDACTED]") {
+			t.Errorf("%s %s: body %s", tc.method, tc.target, w.Body)
+		}
+	}
+
+	// The stored delivery keeps the values it is replayed with
+	if got := dest.received(); len(got) != 2 || got[1].Header.Get("Authorization") != "Bearer s3cret-token" {
+		t.Errorf("destination received %+v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/dedupe"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// dedupeRequest looks up the route's idempotency key. Duplicates are answered
+// with the original response and handled is true. Otherwise the response
+// should be written to the returned writer and finish called afterwards, so a
+// successful response is remembered for later duplicates.
+func (ws *WebhookServer) dedupeRequest(w http.ResponseWriter, route *configApi.Route, routeID string, env *configApi.MatcherEnv, requestID string, logger *slog.Logger) (writer http.ResponseWriter, finish func(), handled bool) {
+	cfg := route.Dedupe
+
+	key, err := cfg.EvaluateKey(env)
+	if err != nil {
+		logger.Warn("Failed to compute dedupe key, forwarding without deduplication", "error", err)
+		return w, func() {}, false
+	}
+	if key == "" {
+		return w, func() {}, false
+	}
+
+	namespace := routeID
+	settings := dedupe.Settings{
+		TTL:        cfg.GetTTL(),
+		MaxEntries: cfg.GetMaxEntries(),
+		Persist:    cfg.Persistent(),
+	}
+
+	entry, claimed := ws.dedupe.Claim(namespace, key, settings, time.Now())
+	if !claimed {
+		ws.metrics.DuplicatesTotal.WithLabelValues(routeLabel(route)).Inc()
+		ws.metrics.WebhooksProcessed.WithLabelValues("duplicate").Inc()
+
+		if entry == nil {
+			logger.Info("Duplicate webhook still being processed", "dedupe_key", key)
+			ws.writeErrorResponse(w, http.StatusConflict, "A request with the same key is being processed", "DUPLICATE_IN_PROGRESS")
+			return w, nil, true
+		}
+
+		logger.Info("Duplicate webhook, replaying original response",
+			"dedupe_key", key,
+			"original_request_id", entry.RequestID)
+		replayResponse(w, entry.Response)
+		return w, nil, true
+	}
+
+	recorder := &responseRecorder{ResponseWriter: w}
+	finish = func() {
+		// Failed requests are forgotten so the provider's retry gets through
+		if recorder.status < 200 || recorder.status > 299 {
+			ws.dedupe.Release(namespace, key)
+			return
+		}
+
+		response := dedupe.Response{
+			Status:  recorder.status,
+			Headers: recorder.Header().Clone(),
+			Body:    recorder.body.Bytes(),
+		}
+		if err := ws.dedupe.Complete(namespace, key, requestID, response, settings, time.Now()); err != nil {
+			logger.Error("Failed to store dedupe entry", "dedupe_key", key, "error", err)
+		}
+	}
+
+	return recorder, finish, false
+}
+
+func replayResponse(w http.ResponseWriter, response dedupe.Response) {
+	for name, values := range response.Headers {
+		// Keep the headers of this request, such as its request ID
+		if _, ok := w.Header()[name]; ok {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(response.Body)
+}
+
+// responseRecorder keeps a copy of the response written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
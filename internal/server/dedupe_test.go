@@ -0,0 +1,181 @@
+package server
+
+import (
+	"github.com/framjet/go-webhook-middleman/internal/dedupe"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func dedupeConfig(dest, store string) string {
+	return `
+destinations:
+  echo: "` + dest + `"
+routes:
+  - path: "/hook/{tenant}"
+    dedupe:
+      key: 'request.headers["X-Delivery"]?.[0] ?? ""'
+      store: ` + store + `
+    matchers:
+      - expr: "true"
+        to: echo
+  - path: "/other/{tenant}"
+    dedupe:
+      key: 'request.headers["X-Delivery"]?.[0] ?? ""'
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestDedupeReplaysOriginalResponse(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, dedupeConfig(dest.URL, "memory"), Options{})
+
+	first := send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("first: status = %d, body %s", first.Code, first.Body)
+	}
+
+	second := send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1")
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay isn't marked")
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first response is marked as replayed")
+	}
+	if id := second.Header().Get(DefaultRequestIDHeader); id == "" || id == first.Header().Get(DefaultRequestIDHeader) {
+		t.Errorf("replay request ID = %q", id)
+	}
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestDedupeForwardsAgainAfterFailure(t *testing.T) {
+	dest := newDestination(t, http.StatusInternalServerError, http.StatusOK)
+	ws := newTestServer(t, dedupeConfig(dest.URL, "memory"), Options{})
+
+	if w := send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1"); w.Code < 300 {
+		t.Fatalf("first: status = %d", w.Code)
+	}
+
+	w := send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1")
+	if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry: status = %d, replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestDedupeRejectsDuplicateInProgress(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, dedupeConfig(dest.URL, "memory"), Options{})
+
+	settings := dedupe.Settings{TTL: time.Hour, MaxEntries: 10}
+	if _, claimed := ws.dedupe.Claim(routeKey(ws.Config().Routes, 0), "evt-1", settings, time.Now()); !claimed {
+		t.Fatal("key already claimed")
+	}
+
+	w := send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1")
+	if w.Code != http.StatusConflict || errorCode(t, w) != "DUPLICATE_IN_PROGRESS" {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+	if n := len(dest.received()); n != 0 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestDedupeForwardsEmptyKeys(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, dedupeConfig(dest.URL, "memory"), Options{})
+
+	for range 2 {
+		if w := send(ws, "POST", "/hook/acme", `{}`); w.Header().Get("Idempotent-Replayed") != "" {
+			t.Error("request without a key was replayed")
+		}
+	}
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestDedupeKeysArePerRoute(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, dedupeConfig(dest.URL, "memory"), Options{})
+
+	send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1")
+	if w := send(ws, "POST", "/other/acme", `{}`, "X-Delivery", "evt-1"); w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("key was shared with another route")
+	}
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestDedupeDiskStoreSurvivesRestart(t *testing.T) {
+	dest := newDestination(t)
+	dataDir := filepath.Join(t.TempDir(), "data")
+
+	ws := newTestServer(t, dedupeConfig(dest.URL, "disk"), Options{DataDir: dataDir})
+	first := send(ws, "POST", "/hook/acme", `{"id": 1}`, "X-Delivery", "evt-1")
+
+	restarted := newTestServer(t, dedupeConfig(dest.URL, "disk"), Options{DataDir: dataDir})
+	w := send(restarted, "POST", "/hook/acme", `{"id": 1}`, "X-Delivery", "evt-1")
+	if w.Header().Get("Idempotent-Replayed") != "true" || w.Body.String() != first.Body.String() {
+		t.Errorf("after restart: status = %d, body %s", w.Code, w.Body)
+	}
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+
+	if files, _ := os.ReadDir(filepath.Join(dataDir, "dedupe")); len(files) == 0 {
+		t.Error("no entries under the dedupe data dir")
+	}
+}
+
+func TestDedupeSurvivesReordering(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, dedupeConfig(dest.URL, "memory"), Options{})
+
+	first := send(ws, "POST", "/other/acme", `{}`, "X-Delivery", "evt-1")
+
+	// Swap the routes so /other is now the first one
+	reordered := `
+destinations:
+  echo: "` + dest.URL + `"
+routes:
+  - path: "/other/{tenant}"
+    dedupe:
+      key: 'request.headers["X-Delivery"]?.[0] ?? ""'
+    matchers:
+      - expr: "true"
+        to: echo
+  - path: "/hook/{tenant}"
+    dedupe:
+      key: 'request.headers["X-Delivery"]?.[0] ?? ""'
+    matchers:
+      - expr: "true"
+        to: echo
+`
+	writeFile(t, ws.configPath, reordered)
+	if err := ws.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := send(ws, "POST", "/other/acme", `{}`, "X-Delivery", "evt-1"); w.Header().Get("Idempotent-Replayed") != "true" || w.Body.String() != first.Body.String() {
+		t.Errorf("after reordering: status = %d, body %s", w.Code, w.Body)
+	}
+	if w := send(ws, "POST", "/hook/acme", `{}`, "X-Delivery", "evt-1"); w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("key was handed to the route now at its position")
+	}
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
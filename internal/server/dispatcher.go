@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errDispatcherFull = errors.New("async delivery queue is full")
+var errDispatcherClosed = errors.New("async delivery queue is closed")
+
+// dispatcher runs async deliveries on a bounded pool of background workers
+type dispatcher struct {
+	jobs   chan func(context.Context)
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	active sync.WaitGroup // Jobs submitted and not finished yet
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newDispatcher(workers, queueSize int) *dispatcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &dispatcher{
+		jobs:   make(chan func(context.Context), queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	return d
+}
+
+func (d *dispatcher) work() {
+	defer d.wg.Done()
+
+	for job := range d.jobs {
+		job(d.ctx)
+	}
+}
+
+// Submit enqueues a job without blocking
+func (d *dispatcher) Submit(job func(context.Context)) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return errDispatcherClosed
+	}
+
+	d.active.Add(1)
+	wrapped := func(ctx context.Context) {
+		defer d.active.Done()
+		job(ctx)
+	}
+
+	select {
+	case d.jobs <- wrapped:
+		return nil
+	default:
+		d.active.Done()
+		return errDispatcherFull
+	}
+}
+
+// Wait blocks until every submitted job has finished, or until ctx expires
+func (d *dispatcher) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending returns the number of jobs waiting for a worker
+func (d *dispatcher) Pending() int {
+	return len(d.jobs)
+}
+
+// Shutdown stops accepting jobs and waits for queued ones to finish. If ctx
+// expires first, in-flight deliveries are cancelled.
+func (d *dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.jobs)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"strings"
+	"sync"
+)
+
+// Explanation records how a request was routed. It is filled in while the
+// request is handled when its context carries one, see WithExplanation.
+type Explanation struct {
+	mu sync.Mutex
+
+	RouteIndex   int                             `json:"route_index"` // -1 when no route matched
+	Route        string                          `json:"route,omitempty"`
+	Params       map[string]string               `json:"params,omitempty"`
+	Matchers     []MatcherExplanation            `json:"matchers,omitempty"`
+	Destinations []configApi.ResolvedDestination `json:"destinations,omitempty"`
+	Errors       []string                        `json:"errors,omitempty"` // Destinations that failed to resolve
+}
+
+// MatcherExplanation is how one matcher of the route evaluated
+type MatcherExplanation struct {
+	Index       int                          `json:"index"`
+	Matched     bool                         `json:"matched"`
+	Expressions []configApi.ExpressionResult `json:"expressions"`
+}
+
+type explanationKey struct{}
+
+// WithExplanation returns a context that makes the server record how a
+// request is routed into e
+func WithExplanation(ctx context.Context, e *Explanation) context.Context {
+	e.RouteIndex = -1
+	return context.WithValue(ctx, explanationKey{}, e)
+}
+
+func explanationFrom(ctx context.Context) *Explanation {
+	e, _ := ctx.Value(explanationKey{}).(*Explanation)
+	return e
+}
+
+func (e *Explanation) route(index int, route *configApi.Route, params map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.RouteIndex = index
+	e.Route = routeLabel(route)
+	e.Params = params
+}
+
+func (e *Explanation) matcher(index int, matched bool, results []configApi.ExpressionResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Matchers = append(e.Matchers, MatcherExplanation{Index: index, Matched: matched, Expressions: results})
+}
+
+func (e *Explanation) destinations(destinations []configApi.ResolvedDestination) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Destinations = destinations
+}
+
+func (e *Explanation) error(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Errors = append(e.Errors, err.Error())
+}
+
+// DestinationName returns the name of the resolved destination an outbound
+// request with the method and URL was sent to
+func (e *Explanation) DestinationName(method, url string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, dest := range e.Destinations {
+		if strings.EqualFold(dest.Method, method) && dest.URL == url {
+			return dest.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// destinationLabel names a destination reference by name or, inline, by URL
+func destinationLabel(ref configApi.DestinationRef) string {
+	if ref.Name != "" {
+		return ref.Name
+	}
+	return ref.URL
+}
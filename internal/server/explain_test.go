@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExplanationRecordsRouting(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, `
+destinations:
+  echo: "`+dest.URL+`"
+routes:
+  - path: "/hook/{tenant}"
+    matchers:
+      - expr: 'params.tenant == "other"'
+        to: echo
+      - exprs:
+          - 'params.tenant == "other"'
+          - 'params.tenant == "acme"'
+        to:
+          - echo
+          - url: "`+dest.URL+`/{{index .params.tenant 99}}"
+      - exprs:
+          - 'request.json.missing.field'
+          - 'params.tenant == "acme"'
+        to: echo
+`, Options{})
+
+	explanation := &Explanation{}
+	r := httptest.NewRequest("POST", "/hook/acme", strings.NewReader(`{}`))
+	r = r.WithContext(WithExplanation(context.Background(), explanation))
+	ws.ServeHTTP(httptest.NewRecorder(), r)
+
+	if explanation.RouteIndex != 0 || explanation.Route != "POST /hook/{tenant}" || explanation.Params["tenant"] != "acme" {
+		t.Errorf("route = %d %q %v", explanation.RouteIndex, explanation.Route, explanation.Params)
+	}
+
+	if len(explanation.Matchers) != 3 {
+		t.Fatalf("matchers = %+v", explanation.Matchers)
+	}
+	if m := explanation.Matchers[0]; m.Index != 0 || m.Matched || m.Expressions[0].Result != false {
+		t.Errorf("matcher 0 = %+v", m)
+	}
+	m := explanation.Matchers[1]
+	if m.Index != 1 || !m.Matched || len(m.Expressions) != 2 || m.Expressions[0].Result != false || m.Expressions[1].Result != true {
+		t.Errorf("matcher 1 = %+v", m)
+	}
+
+	// A failing expression fails the matcher even though a later one is true
+	m = explanation.Matchers[2]
+	if m.Index != 2 || m.Matched || m.Expressions[0].Error == "" || m.Expressions[1].Result != true {
+		t.Errorf("matcher 2 = %+v", m)
+	}
+
+	if len(explanation.Destinations) != 1 || explanation.Destinations[0].Name != "echo" {
+		t.Errorf("destinations = %+v", explanation.Destinations)
+	}
+	if len(explanation.Errors) != 1 || !strings.HasPrefix(explanation.Errors[0], "matcher 1 destination "+dest.URL) {
+		t.Errorf("errors = %v", explanation.Errors)
+	}
+
+	if name, ok := explanation.DestinationName("post", dest.URL); !ok || name != "echo" {
+		t.Errorf("DestinationName = %q, %v", name, ok)
+	}
+	if _, ok := explanation.DestinationName(http.MethodPut, dest.URL); ok {
+		t.Error("DestinationName matched another method")
+	}
+}
+
+func TestExplanationWithoutRoute(t *testing.T) {
+	ws := newTestServer(t, reloadConfig("http://localhost", "/a"), Options{})
+
+	explanation := &Explanation{}
+	r := httptest.NewRequest("POST", "/b", nil)
+	ws.ServeHTTP(httptest.NewRecorder(), r.WithContext(WithExplanation(context.Background(), explanation)))
+
+	if explanation.RouteIndex != -1 || len(explanation.Matchers) != 0 {
+		t.Errorf("explanation = %+v", explanation)
+	}
+}
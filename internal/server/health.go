@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// configStatus is the outcome of the latest configuration load
+type configStatus struct {
+	LoadedAt time.Time  `json:"loaded_at"`
+	Status   string     `json:"status"` // "loaded", or "stale" while the latest reload failed and the previous config is in effect
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// ProbeResult is the outcome of the latest readiness probe of a destination
+type ProbeResult struct {
+	OK         bool      `json:"ok"`
+	URL        string    `json:"url"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Readiness is whether the server should be sent webhooks, and why not
+type Readiness struct {
+	Status       string                          `json:"status"` // "ready" or "not_ready"
+	Draining     bool                            `json:"draining"`
+	Config       configStatus                    `json:"config"`
+	Destinations map[string]DestinationReadiness `json:"destinations"`
+	Reasons      []string                        `json:"reasons,omitempty"`
+	Timestamp    time.Time                       `json:"timestamp"`
+}
+
+// DestinationReadiness is the recent health of a global destination
+type DestinationReadiness struct {
+	Ready        bool         `json:"ready"`
+	SuccessRatio float64      `json:"success_ratio"`
+	Requests     int          `json:"requests"` // Deliveries within the window
+	Window       string       `json:"window"`
+	Probe        *ProbeResult `json:"probe,omitempty"`
+}
+
+// probeStates holds the latest probe results and when each probe last started
+type probeStates struct {
+	mu      sync.Mutex
+	started map[string]time.Time
+	running map[string]bool
+	results map[string]ProbeResult
+}
+
+func newProbeStates() *probeStates {
+	return &probeStates{
+		started: make(map[string]time.Time),
+		running: make(map[string]bool),
+		results: make(map[string]ProbeResult),
+	}
+}
+
+// start reports whether a probe is due and marks it running if so
+func (p *probeStates) start(name string, interval time.Duration, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running[name] || now.Sub(p.started[name]) < interval {
+		return false
+	}
+	p.started[name] = now
+	p.running[name] = true
+
+	return true
+}
+
+// finish stores a probe result and returns the previous one
+func (p *probeStates) finish(name string, result ProbeResult) (ProbeResult, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	previous, ok := p.results[name]
+	p.results[name] = result
+	delete(p.running, name)
+
+	return previous, ok
+}
+
+// abort marks a probe done without a result
+func (p *probeStates) abort(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.running, name)
+}
+
+func (p *probeStates) result(name string) (ProbeResult, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result, ok := p.results[name]
+	return result, ok
+}
+
+// Drain marks the server as shutting down, failing readiness so load
+// balancers stop sending webhooks while in-flight ones complete
+func (ws *WebhookServer) Drain() {
+	if ws.draining.CompareAndSwap(false, true) {
+		ws.logger.Info("Draining, reporting not ready")
+	}
+}
+
+// RunProbes runs the readiness probes of destinations as they come due until
+// ctx is cancelled. Probes added or changed by a reload apply from the next pass.
+func (ws *WebhookServer) RunProbes(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		ws.startDueProbes(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ws *WebhookServer) startDueProbes(ctx context.Context) {
+	now := time.Now()
+	for name, dest := range ws.Config().Destinations {
+		if dest.Readiness == nil || dest.Readiness.Probe == nil {
+			continue
+		}
+		if ws.probes.start(name, dest.Readiness.Probe.GetInterval(), now) {
+			go ws.probe(ctx, name, configApi.Destination(dest))
+		}
+	}
+}
+
+func (ws *WebhookServer) probe(ctx context.Context, name string, dest configApi.Destination) {
+	probe := dest.Readiness.Probe
+	result := ProbeResult{URL: probe.URL, CheckedAt: time.Now()}
+	if result.URL == "" {
+		result.URL = dest.URL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probe.GetTimeout())
+	defer cancel()
+
+	err := func() error {
+		client, err := ws.clients.Get(dest.HTTP)
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP client: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, probe.GetMethod(), result.URL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+		result.StatusCode = resp.StatusCode
+		if !probe.Expects(resp.StatusCode) {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}()
+	result.Duration = time.Since(result.CheckedAt).Milliseconds()
+
+	// Don't report probes cut short by shutdown
+	if errors.Is(ctx.Err(), context.Canceled) {
+		ws.probes.abort(name)
+		return
+	}
+
+	result.OK = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	up := 0.0
+	if result.OK {
+		up = 1
+	}
+	ws.metrics.ProbeUp.WithLabelValues(name).Set(up)
+
+	previous, probed := ws.probes.finish(name, result)
+	logger := ws.logger.With("destination", name, "url", result.URL)
+	switch {
+	case !result.OK && (!probed || previous.OK):
+		logger.Warn("Readiness probe failed", "error", result.Error)
+	case result.OK && probed && !previous.OK:
+		logger.Info("Readiness probe recovered")
+	}
+}
+
+// Readiness evaluates whether the server should be sent webhooks. It isn't
+// ready while draining, while a destination's probe hasn't passed or while its
+// recent success ratio is below the configured minimum. A failed reload is
+// reported but keeps the server ready, as the previous config stays in effect.
+func (ws *WebhookServer) Readiness() Readiness {
+	config := ws.Config()
+	now := time.Now()
+
+	readiness := Readiness{
+		Draining:     ws.draining.Load(),
+		Config:       *ws.configStatus.Load(),
+		Destinations: make(map[string]DestinationReadiness),
+		Timestamp:    now.UTC(),
+	}
+	if readiness.Draining {
+		readiness.Reasons = append(readiness.Reasons, "server is shutting down")
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(config.Destinations)) {
+		cfg := config.Destinations[name].Readiness
+		window := cfg.GetWindow()
+		ratio := ws.outcomes.Ratio(name, window, now)
+
+		dest := DestinationReadiness{
+			Ready:        true,
+			SuccessRatio: ratio.Value(),
+			Requests:     ratio.Requests,
+			Window:       window.String(),
+		}
+
+		if cfg != nil && cfg.Probe != nil {
+			result, ok := ws.probes.result(name)
+			switch {
+			case !ok:
+				dest.Ready = false
+				readiness.Reasons = append(readiness.Reasons, fmt.Sprintf("destination '%s' has not been probed yet", name))
+			case !result.OK:
+				dest.Ready = false
+				readiness.Reasons = append(readiness.Reasons, fmt.Sprintf("destination '%s' probe failed: %s", name, ws.secrets.Redact(result.Error)))
+			}
+			if ok {
+				result.URL = ws.secrets.Redact(result.URL)
+				result.Error = ws.secrets.Redact(result.Error)
+				dest.Probe = &result
+			}
+		}
+
+		if cfg != nil && cfg.MinSuccessRatio > 0 && ratio.Requests >= cfg.GetMinRequests() && ratio.Value() < cfg.MinSuccessRatio {
+			dest.Ready = false
+			readiness.Reasons = append(readiness.Reasons, fmt.Sprintf("destination '%s' success ratio %.2f is below %.2f", name, ratio.Value(), cfg.MinSuccessRatio))
+		}
+
+		readiness.Destinations[name] = dest
+	}
+
+	readiness.Status = "ready"
+	if len(readiness.Reasons) > 0 {
+		readiness.Status = "not_ready"
+	}
+
+	return readiness
+}
+
+// livenessCheck reports that the process is up and serving requests
+func (ws *WebhookServer) livenessCheck(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "alive",
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// readinessCheck reports whether the server should be sent webhooks,
+// answering 503 when it shouldn't
+func (ws *WebhookServer) readinessCheck(w http.ResponseWriter, _ *http.Request) {
+	readiness := ws.Readiness()
+
+	status := http.StatusOK
+	if readiness.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, readiness)
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func readinessServerConfig(dest, readiness string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `"
+    readiness:
+` + readiness + `
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func readiness(t *testing.T, ws *WebhookServer) (int, Readiness) {
+	t.Helper()
+
+	w := send(ws, "GET", "/readyz", "")
+	var ready Readiness
+	decodeJSON(t, w.Body, &ready)
+	return w.Code, ready
+}
+
+// probed runs the due probes and waits for their results
+func probed(t *testing.T, ws *WebhookServer, name string) ProbeResult {
+	t.Helper()
+
+	ws.startDueProbes(context.Background())
+	var result ProbeResult
+	eventually(t, func() bool {
+		var ok bool
+		result, ok = ws.probes.result(name)
+		return ok
+	})
+	return result
+}
+
+func TestLiveness(t *testing.T) {
+	ws := newTestServer(t, reloadConfig("http://localhost", "/hook"), Options{})
+	ws.Drain()
+
+	if w := send(ws, "GET", "/livez", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"alive"`) {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestReadyByDefault(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, reloadConfig(dest.URL, "/hook"), Options{})
+
+	send(ws, "POST", "/hook", `{}`)
+
+	code, ready := readiness(t, ws)
+	if code != http.StatusOK || ready.Status != "ready" || ready.Draining || ready.Config.Status != "loaded" {
+		t.Errorf("readiness = %d %+v", code, ready)
+	}
+	echo := ready.Destinations["echo"]
+	if !echo.Ready || echo.Requests != 1 || echo.SuccessRatio != 1 || echo.Window != "5m0s" || echo.Probe != nil {
+		t.Errorf("destination = %+v", echo)
+	}
+}
+
+func TestNotReadyWhileDraining(t *testing.T) {
+	ws := newTestServer(t, reloadConfig("http://localhost", "/hook"), Options{})
+	ws.Drain()
+
+	code, ready := readiness(t, ws)
+	if code != http.StatusServiceUnavailable || ready.Status != "not_ready" || !ready.Draining {
+		t.Errorf("readiness = %d %+v", code, ready)
+	}
+	if len(ready.Reasons) != 1 || ready.Reasons[0] != "server is shutting down" {
+		t.Errorf("reasons = %v", ready.Reasons)
+	}
+}
+
+func TestFailedReloadIsReportedButReady(t *testing.T) {
+	ws := newTestServer(t, reloadConfig("http://localhost", "/hook"), Options{})
+
+	writeFile(t, ws.configPath, "routes: [")
+	if err := ws.Reload("test"); err == nil {
+		t.Fatal("broken config reloaded")
+	}
+
+	code, ready := readiness(t, ws)
+	if code != http.StatusOK || ready.Config.Status != "stale" || ready.Config.FailedAt == nil || ready.Config.Error == "" {
+		t.Errorf("readiness = %d %+v", code, ready)
+	}
+
+	writeFile(t, ws.configPath, reloadConfig("http://localhost", "/hook"))
+	if err := ws.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ready := readiness(t, ws); ready.Config.Status != "loaded" || ready.Config.Error != "" {
+		t.Errorf("config after recovery = %+v", ready.Config)
+	}
+}
+
+func TestNotReadyBelowSuccessRatio(t *testing.T) {
+	dest := newDestination(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+	ws := newTestServer(t, readinessServerConfig(dest.URL, `
+      min_success_ratio: 0.5
+      min_requests: 2`), Options{})
+
+	send(ws, "POST", "/hook", `{}`)
+	if code, _ := readiness(t, ws); code != http.StatusOK {
+		t.Errorf("ready before min_requests: status = %d", code)
+	}
+
+	send(ws, "POST", "/hook", `{}`)
+	code, ready := readiness(t, ws)
+	if code != http.StatusServiceUnavailable || ready.Destinations["echo"].Ready || ready.Destinations["echo"].SuccessRatio != 0 {
+		t.Errorf("readiness = %d %+v", code, ready)
+	}
+	if len(ready.Reasons) != 1 || !strings.Contains(ready.Reasons[0], "success ratio 0.00 is below 0.50") {
+		t.Errorf("reasons = %v", ready.Reasons)
+	}
+
+	send(ws, "POST", "/hook", `{}`)
+	if code, _ := readiness(t, ws); code != http.StatusServiceUnavailable {
+		t.Errorf("ready at a third: status = %d", code)
+	}
+	send(ws, "POST", "/hook", `{}`)
+	if code, _ := readiness(t, ws); code != http.StatusOK {
+		t.Errorf("not ready at a half: status = %d", code)
+	}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	dest := newDestination(t, http.StatusServiceUnavailable, http.StatusOK)
+	ws := newTestServer(t, readinessServerConfig(dest.URL, `
+      probe:
+        url: "`+dest.URL+`/health"
+        interval: 1ms`), Options{})
+
+	code, ready := readiness(t, ws)
+	if code != http.StatusServiceUnavailable || !strings.Contains(strings.Join(ready.Reasons, ";"), "has not been probed yet") {
+		t.Errorf("before probing = %d %+v", code, ready)
+	}
+
+	result := probed(t, ws, "echo")
+	if result.OK || result.StatusCode != http.StatusServiceUnavailable || result.Error != "unexpected status 503" {
+		t.Errorf("failing probe = %+v", result)
+	}
+	code, ready = readiness(t, ws)
+	if code != http.StatusServiceUnavailable || ready.Destinations["echo"].Probe == nil || !strings.Contains(ready.Reasons[0], "probe failed: unexpected status 503") {
+		t.Errorf("after failed probe = %d %+v", code, ready)
+	}
+
+	eventually(t, func() bool {
+		result := probed(t, ws, "echo")
+		return result.OK
+	})
+	if code, ready := readiness(t, ws); code != http.StatusOK || ready.Destinations["echo"].Probe.URL != dest.URL+"/health" {
+		t.Errorf("after recovery = %d %+v", code, ready)
+	}
+
+	got := dest.received()
+	if len(got) < 2 || got[0].Method != "HEAD" || got[0].Path != "/health" {
+		t.Errorf("destination received %+v", got)
+	}
+}
+
+func TestHealthReportsOpenCircuits(t *testing.T) {
+	dest := newDestination(t, http.StatusInternalServerError)
+	ws := newTestServer(t, `
+destinations:
+  echo:
+    url: "`+dest.URL+`"
+    circuit_breaker:
+      min_requests: 1
+      on_open: fail
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`, Options{})
+
+	health := func() map[string]any {
+		var health map[string]any
+		decodeJSON(t, send(ws, "GET", "/health", "").Body, &health)
+		return health
+	}
+	if got := health(); got["status"] != "healthy" {
+		t.Errorf("health = %v", got)
+	}
+
+	send(ws, "POST", "/hook", `{}`)
+	got := health()
+	if got["status"] != "degraded" || got["circuit_breakers"].(map[string]any)["echo"] != "open" {
+		t.Errorf("health = %v", got)
+	}
+}
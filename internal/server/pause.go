@@ -0,0 +1,100 @@
+package server
+
+import (
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// pausedDestinations holds the destinations whose deliveries are held back
+// in the retry queue until they are resumed. Pauses don't survive a restart.
+type pausedDestinations struct {
+	mu    sync.RWMutex
+	since map[string]time.Time
+}
+
+func newPausedDestinations() *pausedDestinations {
+	return &pausedDestinations{since: make(map[string]time.Time)}
+}
+
+// pause reports false when the destination was already paused
+func (p *pausedDestinations) pause(name string, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.since[name]; ok {
+		return false
+	}
+	p.since[name] = now
+
+	return true
+}
+
+// resume reports false when the destination wasn't paused
+func (p *pausedDestinations) resume(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.since[name]; !ok {
+		return false
+	}
+	delete(p.since, name)
+
+	return true
+}
+
+// pausedSince returns when the destination was paused, or false if it isn't
+func (p *pausedDestinations) pausedSince(name string) (time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	since, ok := p.since[name]
+	return since, ok
+}
+
+func (ws *WebhookServer) pausedResult(dest configApi.ResolvedDestination, logger *slog.Logger) configApi.ForwardResult {
+	logger.Info("Destination paused, holding delivery back",
+		"destination", dest.Name,
+		"url", dest.URL)
+	ws.metrics.ForwardingTotal.WithLabelValues(dest.Name, "paused").Inc()
+
+	return configApi.ForwardResult{
+		Destination: dest.Name,
+		URL:         dest.URL,
+		Method:      dest.Method,
+		Headers:     dest.Headers,
+		Success:     false,
+		Error:       "destination paused",
+		Paused:      true,
+	}
+}
+
+// PauseDestination holds back deliveries to a global destination until it's
+// resumed. It reports false when there is no destination of that name.
+func (ws *WebhookServer) PauseDestination(name string) bool {
+	if _, ok := ws.Config().Destinations[name]; !ok {
+		return false
+	}
+
+	if ws.paused.pause(name, time.Now()) {
+		ws.logger.Info("Destination paused", "destination", name)
+	}
+
+	return true
+}
+
+// ResumeDestination lets deliveries to a paused destination through again,
+// the held back ones are sent by the retry worker on its next pass. It
+// reports false when there is no destination of that name.
+func (ws *WebhookServer) ResumeDestination(name string) bool {
+	if _, ok := ws.Config().Destinations[name]; !ok {
+		return false
+	}
+
+	if ws.paused.resume(name) {
+		ws.logger.Info("Destination resumed", "destination", name)
+	}
+
+	return true
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"fmt"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/ratelimit"
+	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTrustedProxies accepts CIDR ranges and single addresses
+func parseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s': %w", value, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %w", value, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+func (ws *WebhookServer) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range ws.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy reports whether the request was sent by a trusted proxy,
+// whose forwarding headers can be believed
+func (ws *WebhookServer) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	return err == nil && ws.isTrustedProxy(remote)
+}
+
+// clientIP returns the address of the client. Behind trusted proxies it is the
+// right-most X-Forwarded-For entry that isn't a trusted proxy itself, since
+// entries further left can be set by the client.
+func (ws *WebhookServer) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !ws.isTrustedProxy(remote) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	client := host
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !ws.isTrustedProxy(addr) {
+			break
+		}
+	}
+
+	return client
+}
+
+// allowRequest checks the rate limits of the route that are checked before
+// the body is read, or with afterAuth the ones keyed by the caller or the
+// body, and rejects the request when it is over one of them
+func (ws *WebhookServer) allowRequest(w http.ResponseWriter, config *configApi.Config, route *configApi.Route, routeID string, params map[string]string, r *http.Request, ctx templateRenderer.TemplateContext, afterAuth bool, logger *slog.Logger) bool {
+	if route.RateLimit == nil {
+		return true
+	}
+
+	env := ws.matcherEnv(config, route, params, r, ctx.Parsed, ctx.Auth)
+	ok, retryAfter := ws.checkRateLimit(route, routeID, env, afterAuth, logger)
+	if ok {
+		return true
+	}
+
+	logger.Warn("Rate limit exceeded",
+		"client_ip", env.Request.ClientIP,
+		"retry_after", retryAfter)
+	ws.metrics.RateLimitedTotal.WithLabelValues(routeLabel(route)).Inc()
+	ws.metrics.WebhooksProcessed.WithLabelValues("rate_limited").Inc()
+	ws.writeRateLimited(w, route, retryAfter, ctx, logger)
+
+	return false
+}
+
+// checkRateLimit takes a token from every bucket of the limits checked before
+// or after authentication that the request counts against. When the request
+// is over a limit it returns false and how long the client should wait.
+func (ws *WebhookServer) checkRateLimit(route *configApi.Route, routeID string, env *configApi.MatcherEnv, afterAuth bool, logger *slog.Logger) (bool, time.Duration) {
+	buckets := make([]ratelimit.Bucket, 0, len(route.RateLimit.Limits))
+	for i, limit := range route.RateLimit.Limits {
+		if limit.AfterAuth() != afterAuth {
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%d|%s", routeID, i, limit.GetBy())
+
+		switch limit.GetBy() {
+		case configApi.RateLimitByIP:
+			key += "|" + env.Request.ClientIP
+		case configApi.RateLimitByKey:
+			value, err := limit.EvaluateKey(env)
+			if err != nil {
+				logger.Warn("Rate limit key evaluation failed, skipping limit", "key", limit.Key, "error", err)
+				continue
+			}
+			if value == "" {
+				continue
+			}
+			key += "|" + value
+		}
+
+		buckets = append(buckets, ratelimit.Bucket{
+			Key:   key,
+			Rate:  limit.RatePerSecond(),
+			Burst: limit.GetBurst(),
+		})
+	}
+
+	return ws.rateLimits.Allow(buckets, time.Now())
+}
+
+// writeRateLimited rejects a request that is over its route's rate limit
+func (ws *WebhookServer) writeRateLimited(w http.ResponseWriter, route *configApi.Route, retryAfter time.Duration, ctx templateRenderer.TemplateContext, logger *slog.Logger) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	status := route.RateLimit.GetStatus()
+	if route.RateLimit.Body == "" {
+		ws.writeErrorResponse(w, status, "Rate limit exceeded", "RATE_LIMITED")
+		return
+	}
+
+	body, err := templateRenderer.RenderTemplate(route.RateLimit.Body, configApi.ResolvedDestination{}, ctx)
+	if err != nil {
+		logger.Error("Failed to render rate limit response", "error", err)
+		ws.writeErrorResponse(w, status, "Rate limit exceeded", "RATE_LIMITED")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+// routeKey identifies a route in state kept across reloads, such as rate
+// limit buckets and idempotency keys. It is made of the route's sorted methods
+// and paths so that adding, removing or reordering other routes doesn't move
+// the state to another route. Routes with the same methods and paths are told
+// apart by their position among each other.
+func routeKey(routes []configApi.Route, routeIndex int) string {
+	key := routeIdentity(&routes[routeIndex])
+
+	same := 1
+	for i := range routeIndex {
+		if routeIdentity(&routes[i]) == key {
+			same++
+		}
+	}
+	if same > 1 {
+		key += fmt.Sprintf(" #%d", same)
+	}
+
+	return key
+}
+
+// routeIdentity is the label of a route with its methods and paths sorted
+func routeIdentity(route *configApi.Route) string {
+	methods := slices.Clone(route.Methods)
+	if route.Method != "" {
+		methods = append(methods, route.Method)
+	}
+	if len(methods) == 0 {
+		methods = []string{"POST"} // default
+	}
+	for i, method := range methods {
+		methods[i] = strings.ToUpper(method)
+	}
+	paths := slices.Clone(route.Paths)
+	if route.Path != "" {
+		paths = append(paths, route.Path)
+	}
+	slices.Sort(methods)
+	slices.Sort(paths)
+
+	return strings.Join(slices.Compact(methods), ",") + " " + strings.Join(slices.Compact(paths), ",")
+}
+
+// routeLabel identifies a route by its methods and paths
+func routeLabel(route *configApi.Route) string {
+	methods := route.Methods
+	if route.Method != "" {
+		methods = append(methods, route.Method)
+	}
+	if len(methods) == 0 {
+		methods = []string{"POST"} // default
+	}
+	paths := route.Paths
+	if route.Path != "" {
+		paths = append(paths, route.Path)
+	}
+
+	return strings.Join(methods, ",") + " " + strings.Join(paths, ",")
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func rateLimitConfig(dest, rateLimit string) string {
+	return `
+destinations:
+  echo: "` + dest + `"
+routes:
+  - path: "/hook/{tenant}"
+    auth:
+      bearer:
+        tokens:
+          alice: "token-a"
+          bob: "token-b"
+    rate_limit:
+` + rateLimit + `
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+// readTracker reports whether the request body was read
+type readTracker struct {
+	io.Reader
+	read bool
+}
+
+func (r *readTracker) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+func TestRateLimitRejectsBeforeReadingBody(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, rateLimitConfig(dest.URL, `
+      body: '{"error": "slow down {{.params.tenant}}"}'
+      limits:
+        - requests: 1
+          per: 1m`), Options{})
+
+	if w := send(ws, "POST", "/hook/acme", `{}`, "Authorization", "Bearer token-a"); w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, body %s", w.Code, w.Body)
+	}
+
+	body := &readTracker{Reader: strings.NewReader(`{}`)}
+	r := httptest.NewRequest("POST", "/hook/acme", body)
+	r.Header.Set("Authorization", "Bearer token-a")
+	w := httptest.NewRecorder()
+	ws.ServeHTTP(w, r)
+
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("status = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w.Body.String() != `{"error": "slow down acme"}` {
+		t.Errorf("body = %s", w.Body)
+	}
+	if body.read {
+		t.Error("body read before the rate limit was checked")
+	}
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestRateLimitByAuthenticatedCaller(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, rateLimitConfig(dest.URL, `
+      limits:
+        - requests: 1
+          per: 1m
+          by: key
+          key: auth.subject`), Options{})
+
+	for _, tt := range []struct {
+		token string
+		want  int
+	}{
+		{"token-a", http.StatusOK},
+		{"token-a", http.StatusTooManyRequests},
+		{"token-b", http.StatusOK},
+		// Rejected credentials don't count against anyone's bucket
+		{"wrong", http.StatusUnauthorized},
+	} {
+		if w := send(ws, "POST", "/hook/acme", `{}`, "Authorization", "Bearer "+tt.token); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.token, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRateLimitByKeyAndIP(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, rateLimitConfig(dest.URL, `
+      limits:
+        - requests: 1
+          per: 1m
+          by: key
+          key: params.tenant
+        - requests: 2
+          per: 1m
+          by: ip`), Options{TrustedProxies: []string{"192.0.2.1"}})
+
+	send := func(tenant, forwardedFor string) int {
+		r := httptest.NewRequest("POST", "/hook/"+tenant, strings.NewReader("{}"))
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("Authorization", "Bearer token-a")
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		ws.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for _, tt := range []struct {
+		tenant, client string
+		want           int
+	}{
+		{"a", "198.51.100.1", http.StatusOK},
+		{"a", "198.51.100.2", http.StatusTooManyRequests}, // tenant a is used up
+		{"b", "198.51.100.1", http.StatusOK},
+		{"c", "198.51.100.1", http.StatusTooManyRequests}, // client 1 is used up
+		{"c", "198.51.100.2", http.StatusOK},
+	} {
+		if got := send(tt.tenant, tt.client); got != tt.want {
+			t.Errorf("tenant %s from %s: status = %d, want %d", tt.tenant, tt.client, got, tt.want)
+		}
+	}
+}
+
+func TestRouteKeyIsStable(t *testing.T) {
+	hook := configApi.Route{Path: "/hook"}
+	other := configApi.Route{Path: "/other"}
+
+	key := routeKey([]configApi.Route{hook}, 0)
+	if got := routeKey([]configApi.Route{other, hook}, 1); got != key {
+		t.Errorf("key moved with the route's position: %q, was %q", got, key)
+	}
+
+	// Equivalent methods and paths in another order are the same route
+	a := configApi.Route{Methods: []string{"put", "POST"}, Paths: []string{"/b", "/a"}}
+	b := configApi.Route{Method: "PUT", Methods: []string{"POST"}, Path: "/a", Paths: []string{"/b"}}
+	if routeKey([]configApi.Route{a}, 0) != routeKey([]configApi.Route{b}, 0) {
+		t.Errorf("keys %q and %q", routeKey([]configApi.Route{a}, 0), routeKey([]configApi.Route{b}, 0))
+	}
+
+	// Routes with the same methods and paths are told apart
+	routes := []configApi.Route{hook, other, hook}
+	if first, second := routeKey(routes, 0), routeKey(routes, 2); first != key || second == first {
+		t.Errorf("keys %q and %q", first, second)
+	}
+}
+
+func TestRateLimitBucketsSurviveReordering(t *testing.T) {
+	limited := `
+  - path: "/hook"
+    rate_limit:
+      limits:
+        - requests: 1
+          per: 1m
+    matchers:
+      - expr: "true"
+        to: echo
+`
+	other := `
+  - path: "/other"
+    rate_limit:
+      limits:
+        - requests: 1
+          per: 1m
+    matchers:
+      - expr: "true"
+        to: echo
+`
+	dest := newDestination(t)
+	head := "\ndestinations:\n  echo: \"" + dest.URL + "\"\nroutes:"
+	ws := newTestServer(t, head+limited+other, Options{})
+
+	if w := send(ws, "POST", "/hook", `{}`); w.Code != http.StatusOK {
+		t.Fatalf("first: status = %d", w.Code)
+	}
+
+	// Moving the route keeps its bucket and doesn't hand it to another route
+	writeFile(t, ws.configPath, head+other+limited)
+	if err := ws.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	if w := send(ws, "POST", "/hook", `{}`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("after reordering: status = %d", w.Code)
+	}
+	if w := send(ws, "POST", "/other", `{}`); w.Code != http.StatusOK {
+		t.Errorf("other route: status = %d", w.Code)
+	}
+}
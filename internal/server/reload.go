@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/cliutil"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"strings"
+	"time"
+)
+
+// Reload loads the configuration file again and, if it validates and compiles,
+// atomically swaps the active config and router. On failure the previous
+// configuration stays in effect.
+func (ws *WebhookServer) Reload(trigger string) error {
+	ws.reloadMu.Lock()
+	defer ws.reloadMu.Unlock()
+
+	logger := ws.logger.With("trigger", trigger, "config", ws.configPath)
+	logger.Info("Reloading configuration")
+
+	config, err := configApi.LoadConfig(ws.configPath)
+	if err != nil {
+		logger.Error("Configuration reload failed, keeping previous configuration", "error", err)
+		ws.metrics.ConfigReloads.WithLabelValues(trigger, "failure").Inc()
+		ws.metrics.ConfigReloadOK.Set(0)
+
+		failed := *ws.configStatus.Load()
+		failedAt := time.Now().UTC()
+		failed.Status = "stale"
+		failed.FailedAt = &failedAt
+		failed.Error = ws.secrets.Redact(err.Error())
+		ws.configStatus.Store(&failed)
+
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	router := ws.buildRouter(config)
+
+	ws.secrets.Set(config.Secrets())
+	ws.config.Store(config)
+	ws.router.Store(router)
+
+	// Rebuild destination clients so changed certificate files are picked up
+	ws.clients.Reset()
+
+	ws.metrics.ConfigReloads.WithLabelValues(trigger, "success").Inc()
+	ws.metrics.ConfigReloadOK.Set(1)
+	ws.configStatus.Store(&configStatus{LoadedAt: time.Now().UTC(), Status: "loaded"})
+
+	logger.Info("Configuration reloaded",
+		"destinations", len(config.Destinations),
+		"routes", len(config.Routes))
+
+	return nil
+}
+
+// WatchConfig polls the configuration files, including the ones added to its
+// directory or matching its globs since, and the secret files they read every
+// interval and triggers a reload whenever their content changes. Polling the
+// checksum rather than relying on filesystem events keeps it working with
+// editors that replace files and with Kubernetes ConfigMap and Secret symlink
+// swaps. It returns when ctx is cancelled.
+func (ws *WebhookServer) WatchConfig(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	lastSum := ws.configChecksum()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sum := ws.configChecksum()
+			if sum == lastSum {
+				continue
+			}
+			lastSum = sum
+
+			_ = ws.Reload("file")
+
+			// The reloaded config may include or read other files
+			lastSum = ws.configChecksum()
+		}
+	}
+}
+
+// configChecksum combines the names and checksums of the files a reload
+// would read, so adding or removing a file counts as a change too
+func (ws *WebhookServer) configChecksum() string {
+	var sum strings.Builder
+	for _, file := range ws.Config().WatchedFiles() {
+		fileSum, err := cliutil.FileChecksum(file)
+		if err != nil {
+			// A removed file is a change too, the reload will report it
+			fileSum = "missing"
+		}
+		fmt.Fprintf(&sum, "%s=%s\n", file, fileSum)
+	}
+
+	return sum.String()
+}
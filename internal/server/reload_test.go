@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func reloadConfig(dest, path string) string {
+	return `
+destinations:
+  echo: "` + dest + `"
+routes:
+  - path: "` + path + `"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestReloadSwapsConfig(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, reloadConfig(dest.URL, "/a"), Options{})
+
+	writeFile(t, ws.configPath, reloadConfig(dest.URL, "/b"))
+	if err := ws.Reload("test"); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if w := send(ws, "POST", "/b", "{}"); w.Code != http.StatusOK {
+		t.Errorf("new route: status = %d", w.Code)
+	}
+	if w := send(ws, "POST", "/a", "{}"); w.Code != http.StatusNotFound {
+		t.Errorf("removed route: status = %d", w.Code)
+	}
+	if status := ws.configStatus.Load(); status.Status != "loaded" {
+		t.Errorf("config status = %q", status.Status)
+	}
+}
+
+func TestReloadKeepsConfigOnError(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, reloadConfig(dest.URL, "/a"), Options{})
+
+	writeFile(t, ws.configPath, "routes: [")
+	if err := ws.Reload("test"); err == nil {
+		t.Fatal("Reload accepted a broken config")
+	}
+
+	if w := send(ws, "POST", "/a", "{}"); w.Code != http.StatusOK {
+		t.Errorf("previous route: status = %d", w.Code)
+	}
+	status := ws.configStatus.Load()
+	if status.Status != "stale" || status.Error == "" || status.FailedAt == nil {
+		t.Errorf("config status = %+v", status)
+	}
+}
+
+func TestWatchConfigReloadsChangedFile(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, reloadConfig(dest.URL, "/a"), Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ws.WatchConfig(ctx, 10*time.Millisecond)
+
+	// Let the watcher take the first checksum before changing the file
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, ws.configPath, reloadConfig(dest.URL, "/b"))
+
+	eventually(t, func() bool {
+		return len(ws.Config().Routes) == 1 && ws.Config().Routes[0].Path == "/b"
+	})
+}
+
+func TestWatchConfigNoticesAddedAndRemovedFiles(t *testing.T) {
+	dest := newDestination(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), reloadConfig(dest.URL, "/a"))
+	ws, err := NewWebhookServer(Options{ConfigPath: dir, DataDir: filepath.Join(t.TempDir(), "data"), Registry: prometheus.NewRegistry()}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewWebhookServer: %v", err)
+	}
+	ws.SetupRoutes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ws.WatchConfig(ctx, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	added := filepath.Join(dir, "b.yaml")
+	writeFile(t, added, `
+routes:
+  - path: "/b"
+    matchers:
+      - expr: "true"
+        to: echo
+`)
+	eventually(t, func() bool { return len(ws.Config().Routes) == 2 })
+	if w := send(ws, "POST", "/b", "{}"); w.Code != http.StatusOK {
+		t.Errorf("added route: status = %d", w.Code)
+	}
+
+	if err := os.Remove(added); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return len(ws.Config().Routes) == 1 })
+	if w := send(ws, "POST", "/b", "{}"); w.Code != http.StatusNotFound {
+		t.Errorf("removed route: status = %d", w.Code)
+	}
+}
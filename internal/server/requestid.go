@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// DefaultRequestIDHeader carries the request ID when no other header is configured
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from callers
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// identifyRequest takes the request ID from the caller's request ID header,
+// or generates one, and returns it in the same header of the response
+func (ws *WebhookServer) identifyRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(ws.requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(ws.requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestIDFrom returns the ID of the request, empty outside of webhook routes
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether a caller's request ID is safe to log and
+// pass on: not too long and only visible ASCII characters
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
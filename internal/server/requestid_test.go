@@ -0,0 +1,139 @@
+package server
+
+import (
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func requestIDConfig(dest, mode string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `"
+    body: '{"request": "{{.requestId}}"}'
+routes:
+  - path: "/hook"
+    mode: ` + mode + `
+    matchers:
+      - expr: 'request.id startsWith "ci-"'
+        to: echo
+`
+}
+
+func TestValidRequestID(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want bool
+	}{
+		{"ci-123", true},
+		{"0af7651916cd43dd8448eb211c80319c", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{"", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"naïve", false},
+	} {
+		if got := validRequestID(tc.id); got != tc.want {
+			t.Errorf("validRequestID(%q) = %v", tc.id, got)
+		}
+	}
+}
+
+func TestRequestIDFromCaller(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, requestIDConfig(dest.URL, "sync"), Options{})
+
+	w := send(ws, "POST", "/hook", `{}`, "X-Request-ID", "ci-42")
+	if w.Code != http.StatusOK || w.Header().Get("X-Request-ID") != "ci-42" {
+		t.Fatalf("status = %d, request ID %q", w.Code, w.Header().Get("X-Request-ID"))
+	}
+
+	var response struct {
+		RequestID string `json:"request_id"`
+	}
+	decodeJSON(t, w.Body, &response)
+	if response.RequestID != "ci-42" {
+		t.Errorf("response = %s", w.Body)
+	}
+
+	got := dest.received()
+	if len(got) != 1 || got[0].Header.Get("X-Request-ID") != "ci-42" || got[0].Body != `{"request": "ci-42"}` {
+		t.Errorf("destination received %+v", got)
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, requestIDConfig(dest.URL, "sync"), Options{})
+
+	for _, sent := range []string{"", "not valid"} {
+		w := send(ws, "POST", "/hook", `{}`, "X-Request-ID", sent)
+
+		id := w.Header().Get("X-Request-ID")
+		if _, err := uuid.Parse(id); err != nil {
+			t.Errorf("sent %q: request ID = %q", sent, id)
+		}
+
+		// Generated IDs don't start with "ci-", so the matcher fails
+		if w.Code != http.StatusNotFound || errorCode(t, w) != "NO_DESTINATIONS" {
+			t.Errorf("sent %q: status = %d, body %s", sent, w.Code, w.Body)
+		}
+		var response ErrorResponse
+		decodeJSON(t, w.Body, &response)
+		if response.RequestID != id {
+			t.Errorf("error response request ID = %q, want %q", response.RequestID, id)
+		}
+	}
+
+	if n := len(dest.received()); n != 0 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestRequestIDCustomHeader(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, requestIDConfig(dest.URL, "async"), Options{
+		RequestIDHeader: "x-correlation-id",
+		AsyncWorkers:    1,
+		AsyncQueueSize:  10,
+	})
+
+	w := send(ws, "POST", "/hook", `{}`, "X-Correlation-ID", "ci-7", "X-Request-ID", "ignored")
+	if w.Code != http.StatusAccepted || w.Header().Get("X-Correlation-ID") != "ci-7" || w.Header().Get("X-Request-ID") != "" {
+		t.Fatalf("status = %d, headers %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"request_id":"ci-7"`) {
+		t.Errorf("response = %s", w.Body)
+	}
+
+	got := dest.waitFor(t, 1)
+	if got[0].Header.Get("X-Correlation-ID") != "ci-7" || got[0].Body != `{"request": "ci-7"}` {
+		t.Errorf("destination received %+v", got)
+	}
+}
+
+func TestRequestIDOnlyOnWebhookRoutes(t *testing.T) {
+	ws := newTestServer(t, requestIDConfig("http://localhost", "sync"), Options{})
+
+	if w := send(ws, "GET", "/health", ""); w.Header().Get("X-Request-ID") != "" {
+		t.Errorf("health request ID = %q", w.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestTestFireSendsRequestID(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, requestIDConfig(dest.URL, "sync"), Options{AdminToken: testAdminToken})
+
+	if w := sendAdmin(ws, "POST", "/admin/destinations/echo/test"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	got := dest.received()
+	id := got[0].Header.Get("X-Request-ID")
+	if _, err := uuid.Parse(id); err != nil || got[0].Body != `{"request": "`+id+`"}` {
+		t.Errorf("destination received %+v", got)
+	}
+}
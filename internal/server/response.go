@@ -7,7 +7,6 @@ import (
 	"github.com/framjet/go-webhook-middleman/internal/config"
 	templateRenderer "github.com/framjet/go-webhook-middleman/internal/templateRenderer"
 	"net/http"
-	"text/template"
 	"time"
 )
 
@@ -21,8 +20,15 @@ type ResponseData struct {
 	Params    map[string]string
 	Variables map[string]string
 	Body      string
+	Parsed    *config.ParsedBody    `json:"-"` // Structured body shared with the matchers
+	Templates *config.TemplateCache `json:"-"` // Templates parsed with the config
 
-	Request *http.Request `json:"-"` // Original request for context
+	Request *http.Request   `json:"-"` // Original request for context
+	Auth    config.AuthInfo // Authenticated caller
+
+	Async      bool          // Forwarding continues in the background after responding
+	Deliveries []DeliveryRef // Deliveries accepted on an async route
+	RequestID  string        // Identifies the request, also sent to the caller and destinations in a header
 
 	// Computed fields for convenience in templates
 	ForwardedTo int   `json:"forwarded_to"`
@@ -30,6 +36,12 @@ type ResponseData struct {
 	Successful  int   `json:"successful"`
 }
 
+// DeliveryRef identifies the delivery to one destination
+type DeliveryRef struct {
+	Destination string `json:"destination"`
+	ID          string `json:"delivery_id"`
+}
+
 // ResponseHandler handles response templating and sending
 type ResponseHandler struct {
 	route *config.Route
@@ -68,6 +80,14 @@ func (rh *ResponseHandler) SendResponse(w http.ResponseWriter) error {
 
 // getStatusCode determines the appropriate status code
 func (rh *ResponseHandler) getStatusCode() int {
+	if rh.data.Async {
+		if rh.route.Response != nil && rh.route.Response.Status != nil && rh.route.Response.Status.Accepted != nil {
+			return *rh.route.Response.Status.Accepted
+		}
+
+		return http.StatusAccepted
+	}
+
 	if rh.data.SuccessCount == rh.data.ForwardedTo {
 		if rh.route.Response != nil && rh.route.Response.Status != nil && rh.route.Response.Status.Success != nil {
 			return *rh.route.Response.Status.Success
@@ -127,11 +147,21 @@ func (rh *ResponseHandler) writeBody(w http.ResponseWriter) error {
 
 // writeDefaultJSONResponse writes the default JSON response
 func (rh *ResponseHandler) writeDefaultJSONResponse(w http.ResponseWriter) error {
+	if rh.data.Async {
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"accepted":     true,
+			"deliveries":   rh.data.Deliveries,
+			"request_id":   rh.data.RequestID,
+			"forwarded_to": rh.data.ForwardedTo,
+		})
+	}
+
 	response := map[string]interface{}{
 		"forwarded_to": rh.data.ForwardedTo,
 		"successful":   rh.data.Successful,
 		"duration_ms":  rh.data.DurationMs,
 		"results":      rh.data.Results,
+		"request_id":   rh.data.RequestID,
 	}
 
 	return json.NewEncoder(w).Encode(response)
@@ -139,16 +169,20 @@ func (rh *ResponseHandler) writeDefaultJSONResponse(w http.ResponseWriter) error
 
 // executeTemplate executes a template string with the response data
 func (rh *ResponseHandler) executeTemplate(templateStr string) (string, error) {
-	tmpl, err := template.New("response").Funcs(templateRenderer.GetTplRenderer().FunctionMap).Parse(templateStr)
+	tmpl, err := rh.data.Templates.Get(templateStr)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return "", err
 	}
 
+	jsonBody, formBody := config.BodyData(tmpl, rh.data.Parsed)
+
 	var buf bytes.Buffer
 	ctx := map[string]interface{}{
 		"params":       rh.data.Params,
 		"var":          rh.data.Variables,
 		"body":         rh.data.Body,
+		"json":         jsonBody,
+		"form":         formBody,
 		"destinations": rh.data.Destinations,
 		"successCount": rh.data.SuccessCount,
 		"duration":     rh.data.Duration,
@@ -157,6 +191,10 @@ func (rh *ResponseHandler) executeTemplate(templateStr string) (string, error) {
 		"forwardedTo":  rh.data.ForwardedTo,
 		"durationMs":   rh.data.DurationMs,
 		"successful":   rh.data.Successful,
+		"async":        rh.data.Async,
+		"deliveries":   rh.data.Deliveries,
+		"requestId":    rh.data.RequestID,
+		"auth":         templateRenderer.AuthContext(rh.data.Auth),
 	}
 	if err := tmpl.Execute(&buf, ctx); err != nil {
 		return "", fmt.Errorf("failed to execute template: %w", err)
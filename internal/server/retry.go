@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/deadletter"
+	"github.com/framjet/go-webhook-middleman/internal/queue"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// forwardWithRetry makes the first delivery attempt inline and, if it fails
+// with a retryable error, persists the delivery to the retry queue. Failures
+// that won't be retried go to the dead-letter store. Both are keyed by the
+// delivery ID of the destination.
+func (ws *WebhookServer) forwardWithRetry(ctx context.Context, dest configApi.ResolvedDestination, meta configApi.RequestMeta, logger *slog.Logger) configApi.ForwardResult {
+	result := ws.forwardToDestination(ctx, dest, meta.Headers, logger)
+	result.Attempts = 1
+
+	if result.Success {
+		return result
+	}
+
+	if result.CircuitOpen {
+		if cfg := ws.circuitBreakerConfig(dest.Name); cfg != nil && cfg.OnOpen == configApi.CircuitOpenFail {
+			return result
+		}
+	}
+
+	// Deliveries to a paused destination wait in the queue however retries are configured
+	attempts := 1
+	if result.Paused {
+		attempts = 0
+	}
+
+	now := time.Now()
+	if !result.Paused && (!dest.Retry.ShouldRetry(result.StatusCode) || dest.Retry.GetMaxAttempts() <= 1) {
+		ws.deadLetter(&deadletter.Entry{
+			ID:          dest.ID,
+			Destination: dest,
+			Request:     meta,
+			Attempts:    1,
+			LastError:   result.Error,
+			LastStatus:  result.StatusCode,
+			CreatedAt:   now,
+		}, logger)
+		return result
+	}
+
+	delivery := &queue.Delivery{
+		ID:          dest.ID,
+		Destination: dest,
+		Request:     meta,
+		Attempts:    attempts,
+		NextAttempt: ws.nextAttemptAt(dest, now.Add(dest.Retry.Backoff(1))),
+		LastError:   result.Error,
+		LastStatus:  result.StatusCode,
+		CreatedAt:   now,
+	}
+
+	if err := ws.retries.Put(delivery); err != nil {
+		logger.Error("Failed to queue delivery for retry", "destination", dest.Name, "url", dest.URL, "error", err)
+		ws.deadLetter(deadLetterFromDelivery(delivery), logger)
+		return result
+	}
+
+	ws.metrics.RetriesTotal.WithLabelValues(dest.Name, "queued").Inc()
+	ws.metrics.RetryQueueDepth.Inc()
+	logger.Info("Delivery queued for retry",
+		"delivery_id", delivery.ID,
+		"destination", dest.Name,
+		"url", dest.URL,
+		"next_attempt", delivery.NextAttempt)
+
+	result.Queued = true
+
+	return result
+}
+
+// RunRetryWorker processes due deliveries from the retry queue every interval
+// until ctx is cancelled. Deliveries left in the queue are picked up again on
+// the next start.
+func (ws *WebhookServer) RunRetryWorker(ctx context.Context, interval time.Duration) {
+	ws.updateRetryQueueDepth()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ws.processRetries(ctx)
+		}
+	}
+}
+
+func (ws *WebhookServer) processRetries(ctx context.Context) {
+	due, err := ws.retries.Due(time.Now())
+	if err != nil {
+		ws.logger.Error("Failed to read retry queue", "error", err)
+		return
+	}
+
+	// Send at most retryWorkers at a time so a backlog built up during an
+	// outage doesn't hit the recovered destination all at once
+	deliveries := make(chan *queue.Delivery)
+	var wg sync.WaitGroup
+	for i := 0; i < min(ws.retryWorkers, len(due)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				ws.retryDelivery(ctx, d)
+			}
+		}()
+	}
+
+	for _, d := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		deliveries <- d
+	}
+	close(deliveries)
+	wg.Wait()
+
+	ws.updateRetryQueueDepth()
+}
+
+func (ws *WebhookServer) retryDelivery(ctx context.Context, d *queue.Delivery) {
+	dest := d.Destination
+	logger := ws.logger.With("delivery_id", d.ID)
+
+	var result configApi.ForwardResult
+	if err := ws.restoreSigning(&dest, d.Request); err != nil {
+		result = ws.requestError(dest, time.Now(), "failed to sign request", err, logger)
+	} else {
+		result = ws.forwardToDestination(traceContextFrom(ctx, d.Request.Headers), dest, d.Request.Headers, logger)
+	}
+
+	// Wait for the circuit to close, the throttle to free up or the destination
+	// to be resumed without using up an attempt
+	if result.CircuitOpen || result.Throttled || result.Paused {
+		d.NextAttempt = ws.nextAttemptAt(dest, time.Now().Add(time.Second))
+		if err := ws.retries.Put(d); err != nil {
+			logger.Error("Failed to update delivery in retry queue", "error", err)
+		}
+		return
+	}
+
+	d.Attempts++
+
+	if result.Success {
+		ws.metrics.RetriesTotal.WithLabelValues(dest.Name, "success").Inc()
+		logger.Info("Retried delivery succeeded", "destination", dest.Name, "url", dest.URL, "attempts", d.Attempts)
+		if err := ws.retries.Remove(d.ID); err != nil {
+			logger.Error("Failed to remove delivery from retry queue", "error", err)
+		}
+		return
+	}
+
+	// Don't count an attempt aborted by shutdown, it will run again on the next start
+	if ctx.Err() != nil {
+		return
+	}
+
+	d.LastError = result.Error
+	d.LastStatus = result.StatusCode
+
+	if d.Attempts >= dest.Retry.GetMaxAttempts() || !dest.Retry.ShouldRetry(result.StatusCode) {
+		ws.metrics.RetriesTotal.WithLabelValues(dest.Name, "exhausted").Inc()
+		logger.Error("Giving up on delivery",
+			"destination", dest.Name,
+			"url", dest.URL,
+			"attempts", d.Attempts,
+			"status_code", d.LastStatus,
+			"error", d.LastError)
+		ws.deadLetter(deadLetterFromDelivery(d), logger)
+		if err := ws.retries.Remove(d.ID); err != nil {
+			logger.Error("Failed to remove delivery from retry queue", "error", err)
+		}
+		return
+	}
+
+	d.NextAttempt = ws.nextAttemptAt(dest, time.Now().Add(dest.Retry.Backoff(d.Attempts)))
+	ws.metrics.RetriesTotal.WithLabelValues(dest.Name, "failed").Inc()
+	logger.Warn("Retried delivery failed",
+		"destination", dest.Name,
+		"url", dest.URL,
+		"attempts", d.Attempts,
+		"next_attempt", d.NextAttempt)
+
+	if err := ws.retries.Put(d); err != nil {
+		logger.Error("Failed to update delivery in retry queue", "error", err)
+	}
+}
+
+// nextAttemptAt pushes a retry back until the destination's circuit allows
+// it and the destination's own rate limit has reset
+func (ws *WebhookServer) nextAttemptAt(dest configApi.ResolvedDestination, fallback time.Time) time.Time {
+	return ws.throttleRetryAt(dest, ws.circuitRetryAt(dest.Name, fallback))
+}
+
+func (ws *WebhookServer) updateRetryQueueDepth() {
+	ws.metrics.RetryQueueDepth.Set(float64(ws.retries.Len()))
+}
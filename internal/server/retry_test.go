@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func retryConfig(dest string, retry string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `"
+    retry:
+` + retry + `
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestFailedDeliveryIsRetriedFromQueue(t *testing.T) {
+	dest := newDestination(t, http.StatusServiceUnavailable, http.StatusOK)
+	ws := newTestServer(t, retryConfig(dest.URL, `
+      initial_backoff: 1ms
+      jitter: 0`), Options{})
+
+	w := send(ws, "POST", "/hook", `{"a":1}`)
+	if !strings.Contains(w.Body.String(), `"queued":true`) {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if ws.retries.Len() != 1 {
+		t.Fatalf("retry queue length = %d", ws.retries.Len())
+	}
+
+	eventually(t, func() bool {
+		ws.processRetries(context.Background())
+		return ws.retries.Len() == 0
+	})
+
+	requests := dest.received()
+	if len(requests) != 2 || requests[1].Body != `{"a":1}` {
+		t.Fatalf("destination received %+v", requests)
+	}
+	if entries, _ := ws.deadLetters.List(); len(entries) != 0 {
+		t.Errorf("dead letters = %+v", entries)
+	}
+}
+
+func TestExhaustedRetriesAreDeadLettered(t *testing.T) {
+	dest := newDestination(t, http.StatusBadGateway)
+	ws := newTestServer(t, retryConfig(dest.URL, `
+      max_attempts: 2
+      initial_backoff: 1ms`), Options{})
+
+	send(ws, "POST", "/hook", `{}`)
+	eventually(t, func() bool {
+		ws.processRetries(context.Background())
+		return ws.retries.Len() == 0
+	})
+
+	entries, err := ws.deadLetters.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Attempts != 2 || entries[0].LastStatus != http.StatusBadGateway {
+		t.Fatalf("dead letters = %+v", entries)
+	}
+	if n := len(dest.received()); n != 2 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestNonRetryableStatusIsNotQueued(t *testing.T) {
+	dest := newDestination(t, http.StatusBadRequest)
+	ws := newTestServer(t, retryConfig(dest.URL, `
+      initial_backoff: 1ms`), Options{})
+
+	send(ws, "POST", "/hook", `{}`)
+
+	if ws.retries.Len() != 0 {
+		t.Errorf("retry queue length = %d", ws.retries.Len())
+	}
+	if entries, _ := ws.deadLetters.List(); len(entries) != 1 {
+		t.Errorf("dead letters = %+v", entries)
+	}
+}
@@ -6,69 +6,194 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/breaker"
 	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/deadletter"
+	"github.com/framjet/go-webhook-middleman/internal/dedupe"
+	"github.com/framjet/go-webhook-middleman/internal/httpclient"
 	metricsApi "github.com/framjet/go-webhook-middleman/internal/metrics"
+	"github.com/framjet/go-webhook-middleman/internal/outcomes"
+	"github.com/framjet/go-webhook-middleman/internal/queue"
+	"github.com/framjet/go-webhook-middleman/internal/ratelimit"
+	"github.com/framjet/go-webhook-middleman/internal/redact"
+	"github.com/framjet/go-webhook-middleman/internal/signing"
 	"github.com/framjet/go-webhook-middleman/internal/sprout"
 	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"github.com/framjet/go-webhook-middleman/internal/throttle"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/pprof"
+	"net/netip"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type WebhookServer struct {
-	Config  *configApi.Config
-	client  *http.Client
-	logger  *slog.Logger
-	metrics *metricsApi.Metrics
+	configPath  string
+	config      atomic.Pointer[configApi.Config]
+	router      atomic.Pointer[mux.Router]
+	reloadMu    sync.Mutex
+	clients     *httpclient.Pool
+	logger      *slog.Logger
+	secrets     *redact.Secrets // Values redacted from logs
+	metrics     *metricsApi.Metrics
+	retries     *queue.Queue
+	deadLetters *deadletter.Store
+	dedupe      *dedupe.Store
+	async       *dispatcher
+	breakers    *breaker.Registry
+	throttles   *throttle.Registry
+	paused      *pausedDestinations
+	outcomes    *outcomes.Tracker // Recent delivery outcomes for readiness
+	probes      *probeStates
+	adminToken  string
+	adminApart  bool // Operational endpoints are served by AdminHandler
+
+	metricsHandler http.Handler // Serves the metrics on /metrics
+
+	configStatus atomic.Pointer[configStatus]
+	draining     atomic.Bool
+	retryWorkers int // Queued retries sent at the same time
+
+	rateLimits     *ratelimit.Store
+	trustedProxies []netip.Prefix
+
+	requestIDHeader string // Header carrying the request ID to and from callers and to destinations
+}
+
+// Options configures a WebhookServer
+type Options struct {
+	ConfigPath string
+	Timeout    time.Duration
+	DataDir    string // Directory for persistent state such as the retry queue and dead letters
+	AdminToken string // Bearer token for the admin endpoints, they are disabled when empty
+
+	SeparateAdmin bool // Serve health, metrics, profiling and admin endpoints from AdminHandler only, not next to the webhook routes
+
+	AsyncWorkers   int // Background workers delivering webhooks for async routes, also bounds concurrent retries
+	AsyncQueueSize int // Async deliveries that may wait for a free worker
+
+	TrustedProxies []string // Addresses and CIDR ranges whose X-Forwarded-For and X-Forwarded-Proto headers are trusted
+
+	RequestIDHeader string // Header carrying the request ID, DefaultRequestIDHeader when empty
+
+	Transport http.RoundTripper // Sends outbound requests instead of the network, used for dry runs
+
+	Registry *prometheus.Registry // Registers and serves the metrics, the global default registry when nil
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	Details   string `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"` // Set on webhook routes
 }
 
-func NewWebhookServer(configPath string, timeout time.Duration, logger *slog.Logger) (*WebhookServer, error) {
-	config, err := configApi.LoadConfig(configPath)
+func NewWebhookServer(opts Options, logger *slog.Logger) (*WebhookServer, error) {
+	config, err := configApi.LoadConfig(opts.ConfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	transport := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
+	retries, err := queue.Open(filepath.Join(opts.DataDir, "queue"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open retry queue: %w", err)
+	}
+
+	deadLetters, err := deadletter.Open(DeadLetterDir(opts.DataDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter store: %w", err)
+	}
+
+	dedupeStore, err := dedupe.Open(filepath.Join(opts.DataDir, "dedupe"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dedupe store: %w", err)
 	}
 
-	client := &http.Client{
-		Timeout:   timeout,
-		Transport: transport,
+	trustedProxies, err := parseTrustedProxies(opts.TrustedProxies)
+	if err != nil {
+		return nil, err
 	}
 
-	metrics := metricsApi.NewMetrics()
+	requestIDHeader := DefaultRequestIDHeader
+	if opts.RequestIDHeader != "" {
+		requestIDHeader = http.CanonicalHeaderKey(opts.RequestIDHeader)
+	}
 
-	return &WebhookServer{
-		Config:  config,
-		client:  client,
-		logger:  logger,
-		metrics: metrics,
-	}, nil
+	secrets := &redact.Secrets{}
+	secrets.Set(config.Secrets())
+	logger = slog.New(redact.NewHandler(logger.Handler(), secrets))
+
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if opts.Registry != nil {
+		registerer, gatherer = opts.Registry, opts.Registry
+	}
+	metrics := metricsApi.NewMetrics(registerer)
+
+	ws := &WebhookServer{
+		configPath:  opts.ConfigPath,
+		clients:     httpclient.NewPool(opts.Timeout),
+		logger:      logger,
+		secrets:     secrets,
+		metrics:     metrics,
+		retries:     retries,
+		deadLetters: deadLetters,
+		dedupe:      dedupeStore,
+		async:       newDispatcher(opts.AsyncWorkers, opts.AsyncQueueSize),
+		adminToken:  opts.AdminToken,
+		adminApart:  opts.SeparateAdmin,
+
+		rateLimits:     ratelimit.NewStore(),
+		trustedProxies: trustedProxies,
+
+		requestIDHeader: requestIDHeader,
+		retryWorkers:    max(opts.AsyncWorkers, 1),
+	}
+	if opts.Transport != nil {
+		ws.clients.UseTransport(opts.Transport)
+	}
+	ws.metricsHandler = promhttp.InstrumentMetricHandler(registerer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	ws.breakers = breaker.NewRegistry(ws.onCircuitChange)
+	ws.throttles = throttle.NewRegistry()
+	ws.paused = newPausedDestinations()
+	ws.outcomes = outcomes.NewTracker()
+	ws.probes = newProbeStates()
+	ws.configStatus.Store(&configStatus{LoadedAt: time.Now().UTC(), Status: "loaded"})
+	ws.config.Store(config)
+
+	return ws, nil
 }
 
-func (ws *WebhookServer) findMatchingRoute(path, method string) *configApi.Route {
-	for _, route := range ws.Config.Routes {
+// Config returns the currently active configuration.
+func (ws *WebhookServer) Config() *configApi.Config {
+	return ws.config.Load()
+}
+
+// ServeHTTP dispatches the request to the router built for the active configuration.
+func (ws *WebhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws.router.Load().ServeHTTP(w, r)
+}
+
+func (ws *WebhookServer) findMatchingRoute(config *configApi.Config, path, method string) (*configApi.Route, int) {
+	for i, route := range config.Routes {
 		if ws.routeMatches(route, path, method) {
-			return &route
+			return &route, i
 		}
 	}
-	return nil
+	return nil, -1
 }
 
 func (ws *WebhookServer) routeMatches(route configApi.Route, path, method string) bool {
@@ -161,16 +286,23 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Generate request ID for tracing
-	requestID := uuid.New().String()
+	// Correlates logs, traces, responses and deliveries, see identifyRequest
+	requestID := requestIDFrom(r.Context())
 	logger := ws.logger.With("request_id", requestID)
 
 	ctx := r.Context()
 	start := time.Now()
 	ws.metrics.WebhooksReceived.Inc()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("webhook.request_id", requestID))
+
+	// Snapshot the config so a concurrent reload can't change it mid-request
+	config := ws.Config()
 
 	// Find matching route
-	route := ws.findMatchingRoute(r.URL.Path, r.Method)
+	_, matchSpan := tracer.Start(ctx, "match route")
+	route, routeIndex := ws.findMatchingRoute(config, r.URL.Path, r.Method)
+	matchSpan.SetAttributes(attribute.Int("webhook.route.index", routeIndex))
+	matchSpan.End()
 	if route == nil {
 		logger.Warn("No matching route found",
 			"path", r.URL.Path,
@@ -181,6 +313,9 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Identifies the route's rate limit buckets and idempotency keys across reloads
+	routeID := routeKey(config.Routes, routeIndex)
+
 	// Extract parameters - use gorilla/mux vars if available, otherwise extract manually
 	params := mux.Vars(r)
 	if len(params) == 0 {
@@ -194,6 +329,23 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 
 	// Record route match
 	ws.metrics.RoutesMatched.WithLabelValues(r.Method, r.URL.Path).Inc()
+	if explanation := explanationFrom(ctx); explanation != nil {
+		explanation.route(routeIndex, route, params)
+	}
+
+	templateCtx := templateRenderer.TemplateContext{
+		Params:    params,
+		Variables: config.Variables,
+		Route:     *route,
+		Request:   *r,
+		Templates: config.Templates(),
+		RequestID: requestID,
+	}
+
+	// Enforce the rate limits that don't need the body or the caller before reading the body
+	if !ws.allowRequest(w, config, route, routeID, params, r, templateCtx, false, logger) {
+		return
+	}
 
 	// Read request body
 	body, err := io.ReadAll(r.Body)
@@ -215,17 +367,59 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 		"body", string(body),
 	)
 
-	// Create template context
-	templateCtx := templateRenderer.TemplateContext{
-		Params:    params,
-		Variables: ws.Config.Variables,
-		Body:      string(body),
-		Route:     *route,
-		Request:   *r,
+	templateCtx.Body = string(body)
+	templateCtx.Parsed = configApi.NewParsedBody(string(body), r.Header.Get("Content-Type"))
+
+	// Authenticate the caller before acting on the payload
+	if route.Auth != nil {
+		info, code, err := ws.authenticateRequest(route.Auth, r, templateCtx)
+		if err != nil {
+			logger.Warn("Authentication failed",
+				"error", err,
+				"remote_addr", r.RemoteAddr)
+			ws.metrics.WebhooksProcessed.WithLabelValues("unauthorized").Inc()
+			writeAuthChallenge(w, route.Auth)
+			ws.writeErrorResponse(w, http.StatusUnauthorized, "Authentication failed", code)
+			return
+		}
+		templateCtx.Auth = *info
+		logger = logger.With("auth_method", info.Method, "auth_subject", info.Subject)
+	}
+
+	// Enforce the rate limits keyed by the caller or the body
+	if !ws.allowRequest(w, config, route, routeID, params, r, templateCtx, true, logger) {
+		return
+	}
+
+	// Verify the request signature before acting on the payload
+	if route.Verify != nil {
+		if code, err := ws.verifyRequest(route.Verify, r, body, templateCtx); err != nil {
+			logger.Warn("Signature verification failed",
+				"provider", route.Verify.Provider,
+				"error", err,
+				"remote_addr", r.RemoteAddr)
+			ws.metrics.WebhooksProcessed.WithLabelValues("unauthorized").Inc()
+			ws.writeErrorResponse(w, http.StatusUnauthorized, "Signature verification failed", code)
+			return
+		}
+	}
+
+	// Answer repeated deliveries of an event with the original response
+	if route.Dedupe != nil {
+		env := ws.matcherEnv(config, route, params, r, templateCtx.Parsed, templateCtx.Auth)
+		recorder, finish, handled := ws.dedupeRequest(w, route, routeID, env, requestID, logger)
+		if handled {
+			return
+		}
+		defer finish()
+		w = recorder
 	}
 
 	// Find matching destinations
-	destinations := ws.findMatchingDestinations(route, params, templateCtx, r, string(body), logger)
+	destinations := ws.findMatchingDestinations(config, route, params, templateCtx, r, logger)
+	if explanation := explanationFrom(ctx); explanation != nil {
+		explanation.destinations(destinations)
+	}
 	if len(destinations) == 0 {
 		logger.Warn("No matching destinations found", "params", params)
 		ws.metrics.WebhooksProcessed.WithLabelValues("no_destinations").Inc()
@@ -238,17 +432,30 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 		"destinations", len(destinations),
 		"body_size", len(body))
 
-	// Forward to all matching destinations
-	results := ws.forwardToDestinations(ctx, destinations, r.Header, logger)
-
-	// Count successful forwards
-	successCount := 0
-	for _, result := range results {
-		if result.Success {
-			successCount++
-		}
+	meta := configApi.RequestMeta{
+		ID:         requestID,
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		RemoteAddr: r.RemoteAddr,
+		Headers:    r.Header.Clone(),
+		Params:     params,
+		ReceivedAt: start,
+	}
+	stripCredentials(route, &meta)
+	// Destinations receive the request ID, and queued retries and dead letters
+	// continue the trace of the request
+	meta.Headers.Set(ws.requestIDHeader, requestID)
+	injectTraceContext(ctx, meta.Headers)
+
+	if route.IsAsync() {
+		ws.acceptWebhook(w, r, route, destinations, templateCtx, meta, logger)
+		return
 	}
 
+	// Forward to all matching destinations
+	results := ws.forwardToDestinations(ctx, destinations, meta, logger)
+	successCount := ws.recordProcessed(results)
+
 	duration := time.Since(start)
 	logger.Info("Webhook processed",
 		"params", params,
@@ -256,15 +463,6 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 		"successful", successCount,
 		"duration", duration)
 
-	// Record processing status
-	if successCount == len(destinations) {
-		ws.metrics.WebhooksProcessed.WithLabelValues("success").Inc()
-	} else if successCount > 0 {
-		ws.metrics.WebhooksProcessed.WithLabelValues("partial").Inc()
-	} else {
-		ws.metrics.WebhooksProcessed.WithLabelValues("failed").Inc()
-	}
-
 	responseData := &ResponseData{
 		Destinations: destinations,
 		SuccessCount: successCount,
@@ -273,28 +471,57 @@ func (ws *WebhookServer) handleDynamicWebhook(w http.ResponseWriter, r *http.Req
 		Params:       templateCtx.Params,
 		Variables:    templateCtx.Variables,
 		Body:         templateCtx.Body,
+		Parsed:       templateCtx.Parsed,
+		Templates:    templateCtx.Templates,
 		Request:      r,
+		Auth:         templateCtx.Auth,
 		ForwardedTo:  len(destinations),
 		DurationMs:   duration.Milliseconds(),
 		Successful:   successCount,
+		RequestID:    requestID,
 	}
 
 	handler := NewResponseHandler(route, responseData)
-	if err := handler.SendResponse(w); err != nil {
+	_, renderSpan := tracer.Start(ctx, "render response")
+	err = handler.SendResponse(w)
+	ws.endSpan(renderSpan, err)
+	if err != nil {
 		// Log error and send fallback response
 		ws.writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate response", "RESPONSE_ERROR")
 		return
 	}
 }
 
-func (ws *WebhookServer) findMatchingDestinations(route *configApi.Route, params map[string]string, ctx templateRenderer.TemplateContext, request *http.Request, body string, logger *slog.Logger) []configApi.ResolvedDestination {
+// recordProcessed counts the successful forwards and records the processing status
+func (ws *WebhookServer) recordProcessed(results []configApi.ForwardResult) int {
+	successCount := 0
+	for _, result := range results {
+		if result.Success {
+			successCount++
+		}
+	}
+
+	if successCount == len(results) {
+		ws.metrics.WebhooksProcessed.WithLabelValues("success").Inc()
+	} else if successCount > 0 {
+		ws.metrics.WebhooksProcessed.WithLabelValues("partial").Inc()
+	} else {
+		ws.metrics.WebhooksProcessed.WithLabelValues("failed").Inc()
+	}
+
+	return successCount
+}
+
+func (ws *WebhookServer) findMatchingDestinations(config *configApi.Config, route *configApi.Route, params map[string]string, ctx templateRenderer.TemplateContext, request *http.Request, logger *slog.Logger) []configApi.ResolvedDestination {
 	var destinations []configApi.ResolvedDestination
 
+	explanation := explanationFrom(request.Context())
+
 	// Process matchers
-	for _, matcher := range route.Matchers {
-		if ws.matcherMatches(route, matcher, params, request, body, logger) {
+	for i, matcher := range route.Matchers {
+		if ws.matcherMatches(config, route, matcher, params, request, ctx.Parsed, ctx.Auth, logger) {
 			for _, destRef := range matcher.To {
-				resolved, err := ws.resolveDestination(destRef, ctx)
+				resolved, err := ws.resolveDestination(config, destRef, ctx)
 				if err != nil {
 					if errors.Is(err, sprout.GetErrTemplateStopped()) {
 						logger.Debug("Template rendering stopped. Skipping destination", "dest", destRef)
@@ -302,6 +529,9 @@ func (ws *WebhookServer) findMatchingDestinations(route *configApi.Route, params
 					}
 
 					logger.Error("Failed to resolve destination", "error", err, "dest", destRef)
+					if explanation != nil {
+						explanation.error(fmt.Errorf("matcher %d destination %s: %w", i, destinationLabel(destRef), err))
+					}
 					continue
 				}
 				destinations = append(destinations, resolved)
@@ -312,19 +542,44 @@ func (ws *WebhookServer) findMatchingDestinations(route *configApi.Route, params
 	return destinations
 }
 
-func (ws *WebhookServer) matcherMatches(route *configApi.Route, matcher *configApi.Matcher, params map[string]string, request *http.Request, body string, logger *slog.Logger) bool {
+func (ws *WebhookServer) matcherMatches(config *configApi.Config, route *configApi.Route, matcher *configApi.Matcher, params map[string]string, request *http.Request, body *configApi.ParsedBody, auth configApi.AuthInfo, logger *slog.Logger) bool {
+	env := ws.matcherEnv(config, route, params, request, body, auth)
+	env.Matcher = *matcher
+
+	_, span := tracer.Start(request.Context(), "evaluate matcher",
+		trace.WithAttributes(attribute.Int("webhook.matcher.index", slices.Index(route.Matchers, matcher))))
+	result, err := matcher.Evaluate(env)
+	span.SetAttributes(attribute.Bool("webhook.matcher.matched", result))
+	ws.endSpan(span, err)
+
+	// Explain with the outcome acted on, an expression failing before a
+	// matching one fails the whole matcher
+	if explanation := explanationFrom(request.Context()); explanation != nil {
+		explanation.matcher(slices.Index(route.Matchers, matcher), result, matcher.EvaluateEach(env))
+	}
+	if err != nil {
+		logger.Warn("Matcher evaluation failed", "matcher", matcher, "error", err)
+
+		return false
+	}
+
+	return result
+}
+
+// matcherEnv builds the expression environment for a request
+func (ws *WebhookServer) matcherEnv(config *configApi.Config, route *configApi.Route, params map[string]string, request *http.Request, body *configApi.ParsedBody, auth configApi.AuthInfo) *configApi.MatcherEnv {
 	userInfo := ""
 	if request.URL.User != nil {
 		userInfo = request.URL.User.String()
 	}
 
 	env := &configApi.MatcherEnv{
-		Params:  params,
-		Var:     ws.Config.Variables,
-		Matcher: *matcher,
-		Config:  *ws.Config,
-		Route:   *route,
+		Params: params,
+		Var:    config.Variables,
+		Config: config.Redacted(),
+		Route:  *route,
 		Request: configApi.RequestData{
+			ID:     requestIDFrom(request.Context()),
 			Method: request.Method,
 			Url: configApi.RequestUrlData{
 				Full:     request.URL.String(),
@@ -339,21 +594,19 @@ func (ws *WebhookServer) matcherMatches(route *configApi.Route, matcher *configA
 			},
 			Headers:     request.Header,
 			Host:        request.Host,
-			Body:        body,
+			Body:        body.Raw(),
 			ContentType: request.Header.Get("content-type"),
 			UserAgent:   request.UserAgent(),
 			RemoteAddr:  request.RemoteAddr,
+			ClientIP:    ws.clientIP(request),
+			TLS:         request.TLS != nil,
+			ClientCert:  clientCertData(request),
 		},
+		Auth: auth,
 	}
+	env.Request.SetBody(body)
 
-	result, err := matcher.Evaluate(env)
-	if err != nil {
-		logger.Warn("Matcher evaluation failed", "matcher", matcher, "error", err)
-
-		return false
-	}
-
-	return result
+	return env
 }
 
 func (ws *WebhookServer) valueMatches(routeValue interface{}, paramValue string, logger *slog.Logger) bool {
@@ -390,16 +643,33 @@ func (ws *WebhookServer) stringMatches(pattern, value string, logger *slog.Logge
 	return pattern == value
 }
 
-func (ws *WebhookServer) resolveDestination(ref configApi.DestinationRef, ctx templateRenderer.TemplateContext) (configApi.ResolvedDestination, error) {
+// resolveDestination renders the templates of a destination for a request
+func (ws *WebhookServer) resolveDestination(config *configApi.Config, ref configApi.DestinationRef, ctx templateRenderer.TemplateContext) (configApi.ResolvedDestination, error) {
+	_, span := tracer.Start(ctx.Request.Context(), "render destination",
+		trace.WithAttributes(attribute.String("webhook.destination", destinationLabel(ref))))
+
+	resolved, err := ws.renderDestination(config, ref, ctx)
+	if errors.Is(err, sprout.GetErrTemplateStopped()) {
+		span.SetAttributes(attribute.Bool("webhook.skipped", true))
+		err = nil
+	}
+	ws.endSpan(span, err)
+
+	return resolved, err
+}
+
+func (ws *WebhookServer) renderDestination(config *configApi.Config, ref configApi.DestinationRef, ctx templateRenderer.TemplateContext) (configApi.ResolvedDestination, error) {
 	resolved := configApi.ResolvedDestination{
+		ID:      uuid.New().String(),
 		Method:  "POST", // default
 		Headers: make(map[string]string),
 		Body:    []byte(ctx.Body),
 	}
+	var signingConfig *configApi.SigningConfig
 
 	if ref.Name != "" {
 		// Look up in global destinations
-		if globalDest, exists := ws.Config.Destinations[ref.Name]; exists {
+		if globalDest, exists := config.Destinations[ref.Name]; exists {
 			var err error
 			resolved.URL, err = templateRenderer.RenderTemplate(globalDest.URL, resolved, ctx)
 			if err != nil {
@@ -414,6 +684,12 @@ func (ws *WebhookServer) resolveDestination(ref configApi.DestinationRef, ctx te
 			if globalDest.Method != "" {
 				resolved.Method = globalDest.Method
 			}
+			resolved.Retry = globalDest.Retry
+			resolved.HTTP = globalDest.HTTP
+			signingConfig = globalDest.Signing
+			if signingConfig != nil {
+				resolved.SigningRef = "destinations." + ref.Name + ".signing"
+			}
 			if globalDest.Body != "" {
 				bodyStr, err := templateRenderer.RenderTemplate(globalDest.Body, resolved, ctx)
 				if err != nil {
@@ -446,6 +722,16 @@ func (ws *WebhookServer) resolveDestination(ref configApi.DestinationRef, ctx te
 	if ref.Method != "" {
 		resolved.Method = ref.Method
 	}
+	if ref.Retry != nil {
+		resolved.Retry = ref.Retry
+	}
+	if ref.Signing != nil {
+		signingConfig = ref.Signing
+		resolved.SigningRef = ref.SigningRef()
+	}
+	if ref.HTTP != nil {
+		resolved.HTTP = ref.HTTP
+	}
 
 	if ref.Headers != nil {
 		for key, value := range ref.Headers {
@@ -477,10 +763,18 @@ func (ws *WebhookServer) resolveDestination(ref configApi.DestinationRef, ctx te
 		return resolved, fmt.Errorf("destination URL is empty after resolution")
 	}
 
+	if signingConfig != nil {
+		signing, err := renderSigning(signingConfig, ctx)
+		if err != nil {
+			return resolved, err
+		}
+		resolved.Signing = signing
+	}
+
 	return resolved, nil
 }
 
-func (ws *WebhookServer) forwardToDestinations(ctx context.Context, destinations []configApi.ResolvedDestination, headers http.Header, logger *slog.Logger) []configApi.ForwardResult {
+func (ws *WebhookServer) forwardToDestinations(ctx context.Context, destinations []configApi.ResolvedDestination, meta configApi.RequestMeta, logger *slog.Logger) []configApi.ForwardResult {
 	var wg sync.WaitGroup
 	results := make([]configApi.ForwardResult, len(destinations))
 
@@ -488,7 +782,7 @@ func (ws *WebhookServer) forwardToDestinations(ctx context.Context, destinations
 		wg.Add(1)
 		go func(index int, destination configApi.ResolvedDestination) {
 			defer wg.Done()
-			results[index] = ws.forwardToDestination(ctx, destination, headers, logger)
+			results[index] = ws.forwardWithRetry(ctx, destination, meta, logger)
 		}(i, dest)
 	}
 
@@ -496,23 +790,17 @@ func (ws *WebhookServer) forwardToDestinations(ctx context.Context, destinations
 	return results
 }
 
-func (ws *WebhookServer) forwardToDestination(ctx context.Context, dest configApi.ResolvedDestination, headers http.Header, logger *slog.Logger) configApi.ForwardResult {
+func (ws *WebhookServer) sendToDestination(ctx context.Context, dest configApi.ResolvedDestination, headers http.Header, logger *slog.Logger) configApi.ForwardResult {
 	start := time.Now()
 
+	client, err := ws.clients.Get(dest.HTTP)
+	if err != nil {
+		return ws.requestError(dest, start, "failed to create HTTP client", err, logger)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, dest.Method, dest.URL, bytes.NewReader(dest.Body))
 	if err != nil {
-		logger.Error("Failed to create request", "destination", dest.Name, "url", dest.URL, "error", err)
-		duration := time.Since(start)
-		ws.metrics.ForwardingTotal.WithLabelValues(dest.Name, "request_error").Inc()
-		ws.metrics.ForwardingDuration.WithLabelValues(dest.Name, "request_error").Observe(duration.Seconds())
-		return configApi.ForwardResult{
-			Destination: dest.Name,
-			URL:         dest.URL,
-			Method:      dest.Method,
-			Success:     false,
-			Error:       fmt.Sprintf("failed to create request: %v", err),
-			Duration:    duration.Milliseconds(),
-		}
+		return ws.requestError(dest, start, "failed to create request", err, logger)
 	}
 
 	// Copy relevant headers
@@ -534,6 +822,20 @@ func (ws *WebhookServer) forwardToDestination(ctx context.Context, dest configAp
 		req.Header.Set(name, value)
 	}
 
+	// Pass the trace on, replacing the caller's traceparent copied above
+	injectTraceContext(ctx, req.Header)
+
+	// Sign the final body, per attempt so the timestamp stays fresh on retries
+	if dest.Signing != nil {
+		signatureHeaders, err := signing.Sign(dest.Signing, dest.ID, time.Now(), dest.Body)
+		if err != nil {
+			return ws.requestError(dest, start, "failed to sign request", err, logger)
+		}
+		for name, value := range signatureHeaders {
+			req.Header.Set(name, value)
+		}
+	}
+
 	logger.Debug("Forwarding request",
 		"destination", dest.Name,
 		"url", dest.URL,
@@ -543,7 +845,7 @@ func (ws *WebhookServer) forwardToDestination(ctx context.Context, dest configAp
 		"body", string(dest.Body),
 	)
 
-	resp, err := ws.client.Do(req)
+	resp, err := client.Do(req)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -620,20 +922,77 @@ func (ws *WebhookServer) forwardToDestination(ctx context.Context, dest configAp
 		Success:     success,
 		StatusCode:  resp.StatusCode,
 		Duration:    duration.Milliseconds(),
+		RetryAfter:  upstreamRetryAfter(resp, time.Now()),
+	}
+}
+
+// requestError reports a failure to build the outbound request, nothing was sent
+func (ws *WebhookServer) requestError(dest configApi.ResolvedDestination, start time.Time, message string, err error, logger *slog.Logger) configApi.ForwardResult {
+	logger.Error("Failed to prepare request", "destination", dest.Name, "url", dest.URL, "error", fmt.Errorf("%s: %w", message, err))
+	duration := time.Since(start)
+	ws.metrics.ForwardingTotal.WithLabelValues(dest.Name, "request_error").Inc()
+	ws.metrics.ForwardingDuration.WithLabelValues(dest.Name, "request_error").Observe(duration.Seconds())
+	return configApi.ForwardResult{
+		Destination: dest.Name,
+		URL:         dest.URL,
+		Method:      dest.Method,
+		Success:     false,
+		Error:       fmt.Sprintf("%s: %v", message, err),
+		Duration:    duration.Milliseconds(),
 	}
 }
 
-func (ws *WebhookServer) SetupRoutes() *mux.Router {
+// SetupRoutes builds the router for the active configuration and returns the
+// server itself as the handler, so that later reloads can swap the router in place.
+func (ws *WebhookServer) SetupRoutes() http.Handler {
+	ws.router.Store(ws.buildRouter(ws.Config()))
+
+	return ws
+}
+
+// AdminHandler returns a handler for a listener separate from the webhook
+// routes, serving health, readiness, metrics, profiling and admin endpoints
+func (ws *WebhookServer) AdminHandler() http.Handler {
 	r := mux.NewRouter()
+	ws.setupOperationalRoutes(r)
 
+	// Profiling is only ever served on the internal listener
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+
+	r.NotFoundHandler = http.HandlerFunc(ws.notFoundHandler)
+
+	return r
+}
+
+// setupOperationalRoutes mounts the endpoints that aren't webhook routes
+func (ws *WebhookServer) setupOperationalRoutes(r *mux.Router) {
 	// Health check endpoint - GET only
 	r.HandleFunc("/health", ws.healthCheck).Methods("GET")
+	r.HandleFunc("/livez", ws.livenessCheck).Methods("GET")
+	r.HandleFunc("/readyz", ws.readinessCheck).Methods("GET")
 
 	// Metrics endpoint - GET only
-	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.Handle("/metrics", ws.metricsHandler).Methods("GET")
+
+	// Admin endpoints, only mounted when an admin token is configured
+	ws.setupAdminRoutes(r)
+}
+
+func (ws *WebhookServer) buildRouter(config *configApi.Config) *mux.Router {
+	r := mux.NewRouter()
+
+	// Without a listener of their own the operational endpoints share the
+	// webhook port and take precedence over the configured routes
+	if !ws.adminApart {
+		ws.setupOperationalRoutes(r)
+	}
 
 	// Dynamic webhook routes
-	for _, route := range ws.Config.Routes {
+	for _, route := range config.Routes {
 		paths := route.Paths
 		if route.Path != "" {
 			paths = append(paths, route.Path)
@@ -648,7 +1007,7 @@ func (ws *WebhookServer) SetupRoutes() *mux.Router {
 		}
 
 		for _, path := range paths {
-			r.HandleFunc(path, ws.handleDynamicWebhook).Methods(methods...)
+			r.Handle(path, ws.traceRequest(path, ws.identifyRequest(http.HandlerFunc(ws.handleDynamicWebhook)))).Methods(methods...)
 		}
 	}
 
@@ -659,11 +1018,23 @@ func (ws *WebhookServer) SetupRoutes() *mux.Router {
 }
 
 func (ws *WebhookServer) healthCheck(w http.ResponseWriter, _ *http.Request) {
+	config := ws.Config()
+
+	health := "healthy"
+	circuits := make(map[string]string)
+	for name, state := range ws.breakers.States() {
+		circuits[name] = state.String()
+		if state == breaker.StateOpen {
+			health = "degraded"
+		}
+	}
+
 	status := map[string]interface{}{
-		"status":       "healthy",
-		"destinations": len(ws.Config.Destinations),
-		"routes":       len(ws.Config.Routes),
-		"timestamp":    time.Now().UTC(),
+		"status":           health,
+		"destinations":     len(config.Destinations),
+		"routes":           len(config.Routes),
+		"circuit_breakers": circuits,
+		"timestamp":        time.Now().UTC(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -694,8 +1065,9 @@ func (ws *WebhookServer) writeErrorResponse(w http.ResponseWriter, statusCode in
 	w.WriteHeader(statusCode)
 
 	response := ErrorResponse{
-		Error: message,
-		Code:  code,
+		Error:     message,
+		Code:      code,
+		RequestID: w.Header().Get(ws.requestIDHeader), // Set by identifyRequest
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer writes config to a temporary directory and creates a server
+// for it, keeping its data next to the config
+func newTestServer(t *testing.T, config string, opts Options) *WebhookServer {
+	t.Helper()
+
+	dir := t.TempDir()
+	opts.ConfigPath = filepath.Join(dir, "config.yaml")
+	writeFile(t, opts.ConfigPath, config)
+	if opts.DataDir == "" {
+		opts.DataDir = filepath.Join(dir, "data")
+	}
+	if opts.Registry == nil {
+		opts.Registry = prometheus.NewRegistry()
+	}
+
+	ws, err := NewWebhookServer(opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewWebhookServer: %v", err)
+	}
+	ws.SetupRoutes()
+
+	return ws
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// send serves a request with the given body and headers as name, value pairs
+func send(ws http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	ws.ServeHTTP(w, r)
+
+	return w
+}
+
+// destination records the requests it receives and answers them with the
+// next of its statuses, repeating the last one
+type destination struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header // Sent with every response
+	requests []recordedRequest
+}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+func newDestination(t *testing.T, statuses ...int) *destination {
+	t.Helper()
+
+	d := &destination{statuses: statuses, header: http.Header{}}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		d.mu.Lock()
+		d.requests = append(d.requests, recordedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: string(body)})
+		status := http.StatusOK
+		if len(d.statuses) > 0 {
+			status = d.statuses[0]
+			if len(d.statuses) > 1 {
+				d.statuses = d.statuses[1:]
+			}
+		}
+		for name, values := range d.header {
+			w.Header()[name] = values
+		}
+		d.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(d.Close)
+
+	return d
+}
+
+// setHeader adds a header to every response from now on
+func (d *destination) setHeader(name, value string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.header.Set(name, value)
+}
+
+// received returns the requests received so far
+func (d *destination) received() []recordedRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]recordedRequest(nil), d.requests...)
+}
+
+// waitFor waits until the destination received n requests
+func (d *destination) waitFor(t *testing.T, n int) []recordedRequest {
+	t.Helper()
+
+	eventually(t, func() bool { return len(d.received()) >= n })
+
+	return d.received()
+}
+
+// eventually fails the test unless condition becomes true within a few seconds
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwardsToMatchingDestination(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, `
+destinations:
+  echo: "`+dest.URL+`/{{.params.service}}"
+routes:
+  - path: "/hook/{service}"
+    matchers:
+      - expr: 'params.service == "ci"'
+        to: echo
+`, Options{})
+
+	if w := send(ws, "POST", "/hook/ci", `{"a":1}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if w := send(ws, "POST", "/hook/other", `{}`); w.Code == http.StatusOK {
+		t.Fatalf("unmatched request got status %d", w.Code)
+	}
+
+	requests := dest.received()
+	if len(requests) != 1 || requests[0].Path != "/ci" || requests[0].Body != `{"a":1}` {
+		t.Fatalf("destination received %+v", requests)
+	}
+}
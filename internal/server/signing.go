@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"net/http"
+	"net/url"
+)
+
+// renderSigning returns a copy of the signing config with its secret rendered
+func renderSigning(cfg *configApi.SigningConfig, ctx templateRenderer.TemplateContext) (*configApi.SigningConfig, error) {
+	secret, err := templateRenderer.RenderSecret(cfg.Secret, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render signing secret: %w", err)
+	}
+
+	rendered := *cfg
+	rendered.Secret = secret
+
+	return &rendered, nil
+}
+
+// restoreSigning renders the signing secret of a delivery loaded from the
+// retry queue or dead-letter store, where it isn't persisted, from the live
+// config. The secret sees the variables, route parameters and headers of the
+// request but not its body or the authenticated caller.
+func (ws *WebhookServer) restoreSigning(dest *configApi.ResolvedDestination, meta configApi.RequestMeta) error {
+	if dest.SigningRef == "" || dest.Signing != nil {
+		return nil
+	}
+
+	config := ws.Config()
+	cfg := config.SigningAt(dest.SigningRef)
+	if cfg == nil {
+		return fmt.Errorf("signing config '%s' is no longer in the config", dest.SigningRef)
+	}
+
+	request := http.Request{Method: meta.Method, Header: meta.Headers}
+	request.URL, _ = url.Parse(meta.URL)
+
+	signing, err := renderSigning(cfg, templateRenderer.TemplateContext{
+		Params:    meta.Params,
+		Variables: config.Variables,
+		Request:   request,
+		Templates: config.Templates(),
+		RequestID: meta.ID,
+	})
+	if err != nil {
+		return err
+	}
+	dest.Signing = signing
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signingConfig(dest, signing string) string {
+	return `
+destinations:
+  echo:
+    url: "` + dest + `"
+    retry:
+      initial_backoff: 1ms
+` + signing + `
+variables:
+  signing_secret: "s3cret-value"
+routes:
+  - path: "/hook/{tenant}"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+const githubSigning = `
+    signing:
+      scheme: github
+      secret: "{{.var.signing_secret}}-{{.params.tenant}}"
+`
+
+func TestRetriedDeliveryIsSignedWithoutPersistingSecret(t *testing.T) {
+	dest := newDestination(t, http.StatusServiceUnavailable, http.StatusOK)
+	ws := newTestServer(t, signingConfig(dest.URL, githubSigning), Options{})
+
+	body := `{"a":1}`
+	send(ws, "POST", "/hook/acme", body)
+	if ws.retries.Len() != 1 {
+		t.Fatalf("retry queue length = %d", ws.retries.Len())
+	}
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(ws.configPath), "data", "queue", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("queue files = %v, %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret-value") {
+		t.Errorf("queued delivery holds the signing secret: %s", data)
+	}
+
+	eventually(t, func() bool {
+		ws.processRetries(context.Background())
+		return ws.retries.Len() == 0
+	})
+
+	want := githubSignature("s3cret-value-acme", body)
+	for i, r := range dest.received() {
+		if got := r.Header.Get("X-Hub-Signature-256"); got != want {
+			t.Errorf("attempt %d signed %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestRetryFailsWhenSigningIsRemoved(t *testing.T) {
+	dest := newDestination(t, http.StatusServiceUnavailable, http.StatusOK)
+	ws := newTestServer(t, signingConfig(dest.URL, githubSigning), Options{})
+
+	send(ws, "POST", "/hook/acme", `{}`)
+
+	writeFile(t, ws.configPath, signingConfig(dest.URL, ""))
+	if err := ws.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sending the delivery unsigned would be worse than not sending it
+	due, err := ws.retries.Due(time.Now().Add(time.Hour))
+	if err != nil || len(due) != 1 {
+		t.Fatalf("due deliveries = %v, %v", due, err)
+	}
+	ws.retryDelivery(context.Background(), due[0])
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+
+	delivery, err := ws.retries.Get(due[0].ID)
+	if err != nil || !strings.Contains(delivery.LastError, "sign") {
+		t.Errorf("delivery after failed signing = %+v, %v", delivery, err)
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"github.com/framjet/go-webhook-middleman/internal/breaker"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	metricsApi "github.com/framjet/go-webhook-middleman/internal/metrics"
+	"maps"
+	"slices"
+	"time"
+)
+
+// RouteStatus describes a configured route along with what it has handled
+// since the process started
+type RouteStatus struct {
+	Index        int        `json:"index"`
+	Methods      []string   `json:"methods"`
+	Paths        []string   `json:"paths"`
+	Mode         string     `json:"mode"`
+	Matchers     int        `json:"matchers"`
+	Destinations []string   `json:"destinations,omitempty"` // Names or URLs the matchers forward to
+	Stats        RouteStats `json:"stats"`
+}
+
+type RouteStats struct {
+	Matched     float64 `json:"matched"`
+	RateLimited float64 `json:"rate_limited"`
+	Duplicates  float64 `json:"duplicates"`
+}
+
+// DestinationStatus describes a global destination and its delivery state
+type DestinationStatus struct {
+	Name        string           `json:"name"`
+	URL         string           `json:"url"`
+	Method      string           `json:"method"`
+	Paused      bool             `json:"paused"`
+	PausedSince *time.Time       `json:"paused_since,omitempty"`
+	Circuit     string           `json:"circuit,omitempty"` // Only known once the destination was used
+	Queued      int              `json:"queued"`            // Deliveries waiting in the retry queue
+	Stats       DestinationStats `json:"stats"`
+}
+
+type DestinationStats struct {
+	Forwarded   map[string]float64 `json:"forwarded"` // Attempts by outcome such as success or http_error
+	Retries     map[string]float64 `json:"retries"`
+	Throttled   map[string]float64 `json:"throttled"`
+	DeadLetters float64            `json:"dead_letters"`
+}
+
+// RouteStatuses returns the routes of the active configuration with their stats
+func (ws *WebhookServer) RouteStatuses() []RouteStatus {
+	config := ws.Config()
+
+	// Matches are counted by request path, attribute them to the route serving it now
+	matched := make(map[int]float64)
+	for _, sample := range metricsApi.Samples(ws.metrics.RoutesMatched) {
+		if _, index := ws.findMatchingRoute(config, sample.Labels["path"], sample.Labels["method"]); index >= 0 {
+			matched[index] += sample.Value
+		}
+	}
+
+	statuses := make([]RouteStatus, len(config.Routes))
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		label := map[string]string{"route": routeLabel(route)}
+
+		methods := slices.Clone(route.Methods)
+		if route.Method != "" {
+			methods = append(methods, route.Method)
+		}
+		if len(methods) == 0 {
+			methods = []string{"POST"} // default
+		}
+		paths := slices.Clone(route.Paths)
+		if route.Path != "" {
+			paths = append(paths, route.Path)
+		}
+
+		status := RouteStatus{
+			Index:    i,
+			Methods:  methods,
+			Paths:    paths,
+			Mode:     route.Mode,
+			Matchers: len(route.Matchers),
+			Stats: RouteStats{
+				Matched:     matched[i],
+				RateLimited: metricsApi.Sum(ws.metrics.RateLimitedTotal, label),
+				Duplicates:  metricsApi.Sum(ws.metrics.DuplicatesTotal, label),
+			},
+		}
+		if status.Mode == "" {
+			status.Mode = configApi.RouteModeSync
+		}
+
+		for _, matcher := range route.Matchers {
+			for _, ref := range matcher.To {
+				target := ref.Name
+				if target == "" {
+					target = ref.URL
+				}
+				if !slices.Contains(status.Destinations, target) {
+					status.Destinations = append(status.Destinations, target)
+				}
+			}
+		}
+
+		statuses[i] = status
+	}
+
+	return statuses
+}
+
+// DestinationStatuses returns the global destinations sorted by name
+func (ws *WebhookServer) DestinationStatuses() ([]DestinationStatus, error) {
+	config := ws.Config()
+
+	queued, err := ws.queuedByDestination()
+	if err != nil {
+		return nil, err
+	}
+	circuits := ws.breakers.States()
+
+	var statuses []DestinationStatus
+	for _, name := range slices.Sorted(maps.Keys(config.Destinations)) {
+		statuses = append(statuses, ws.destinationStatus(config, name, queued, circuits))
+	}
+
+	return statuses, nil
+}
+
+// DestinationStatus returns the state of one global destination, or false
+// when there is no destination of that name
+func (ws *WebhookServer) DestinationStatus(name string) (DestinationStatus, bool, error) {
+	config := ws.Config()
+	if _, ok := config.Destinations[name]; !ok {
+		return DestinationStatus{}, false, nil
+	}
+
+	queued, err := ws.queuedByDestination()
+	if err != nil {
+		return DestinationStatus{}, true, err
+	}
+
+	return ws.destinationStatus(config, name, queued, ws.breakers.States()), true, nil
+}
+
+func (ws *WebhookServer) destinationStatus(config *configApi.Config, name string, queued map[string]int, circuits map[string]breaker.State) DestinationStatus {
+	dest := config.Destinations[name]
+	label := map[string]string{"destination": name}
+
+	status := DestinationStatus{
+		Name:   name,
+		URL:    dest.URL,
+		Method: dest.Method,
+		Queued: queued[name],
+		Stats: DestinationStats{
+			Forwarded:   metricsApi.SumBy(ws.metrics.ForwardingTotal, label, "status"),
+			Retries:     metricsApi.SumBy(ws.metrics.RetriesTotal, label, "status"),
+			Throttled:   metricsApi.SumBy(ws.metrics.ThrottledTotal, label, "reason"),
+			DeadLetters: metricsApi.Sum(ws.metrics.DeadLettersTotal, label),
+		},
+	}
+	if status.Method == "" {
+		status.Method = "POST"
+	}
+	if since, ok := ws.paused.pausedSince(name); ok {
+		status.Paused = true
+		status.PausedSince = &since
+	}
+	if state, ok := circuits[name]; ok {
+		status.Circuit = state.String()
+	}
+
+	return status
+}
+
+func (ws *WebhookServer) queuedByDestination() (map[string]int, error) {
+	deliveries, err := ws.retries.List()
+	if err != nil {
+		return nil, err
+	}
+
+	queued := make(map[string]int)
+	for _, delivery := range deliveries {
+		queued[delivery.Destination.Name]++
+	}
+
+	return queued, nil
+}
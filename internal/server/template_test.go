@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseTemplatesRenderFromConfig(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, `
+destinations:
+  echo:
+    url: "`+dest.URL+`/{{.params.tenant}}"
+routes:
+  - path: "/hook/{tenant}"
+    response:
+      headers:
+        X-Tenant: "{{.params.tenant | toUpper}}"
+      body: '{"tenant": "{{.params.tenant}}", "forwarded": {{.forwardedTo}}}'
+    matchers:
+      - expr: "true"
+        to: echo
+`, Options{})
+
+	w := send(ws, "POST", "/hook/acme", `{}`)
+	if w.Code != http.StatusOK || w.Body.String() != `{"tenant": "acme", "forwarded": 1}` {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("X-Tenant"); got != "ACME" {
+		t.Errorf("X-Tenant = %q", got)
+	}
+	if got := dest.received(); len(got) != 1 || got[0].Path != "/acme" {
+		t.Errorf("destination received %+v", got)
+	}
+}
+
+func TestReloadRejectsBrokenTemplate(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, reloadConfig(dest.URL, "/a"), Options{})
+
+	broken := strings.Replace(reloadConfig(dest.URL, "/b"), `echo: "`+dest.URL+`"`, `echo: "`+dest.URL+`/{{.params.x"`, 1)
+	writeFile(t, ws.configPath, broken)
+	err := ws.Reload("test")
+	if err == nil || !strings.Contains(err.Error(), "failed to compile templates for destination echo url") {
+		t.Fatalf("Reload error = %v", err)
+	}
+
+	if w := send(ws, "POST", "/a", "{}"); w.Code != http.StatusOK {
+		t.Errorf("previous config: status = %d", w.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// TestDelivery is the request sent to a destination by a test fire and what
+// came of it, with secret values redacted
+type TestDelivery struct {
+	Request TestRequest             `json:"request"`
+	Result  configApi.ForwardResult `json:"result"`
+}
+
+type TestRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// errUnknownDestination is returned for test fires at destinations that
+// aren't configured
+var errUnknownDestination = errors.New("destination not found")
+
+// TestDestination renders a global destination for a sample request and sends
+// it straight away. Pauses, circuit breakers, throttles and retries are
+// bypassed so the destination itself is tested, failures aren't dead-lettered.
+func (ws *WebhookServer) TestDestination(ctx context.Context, name string, sample *http.Request, body []byte) (TestDelivery, error) {
+	config := ws.Config()
+	if _, ok := config.Destinations[name]; !ok {
+		return TestDelivery{}, errUnknownDestination
+	}
+
+	// Query parameters stand in for the path parameters of a route
+	params := make(map[string]string)
+	for key := range sample.URL.Query() {
+		params[key] = sample.URL.Query().Get(key)
+	}
+
+	templateCtx := templateRenderer.TemplateContext{
+		Params:    params,
+		Variables: config.Variables,
+		Body:      string(body),
+		Request:   *sample,
+		Parsed:    configApi.NewParsedBody(string(body), sample.Header.Get("Content-Type")),
+		Templates: config.Templates(),
+		RequestID: uuid.New().String(),
+	}
+
+	dest, err := ws.resolveDestination(config, configApi.DestinationRef{Name: name}, templateCtx)
+	if err != nil {
+		return TestDelivery{}, err
+	}
+
+	headers := make(http.Header)
+	headers.Set(ws.requestIDHeader, templateCtx.RequestID)
+	if contentType := sample.Header.Get("Content-Type"); contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+
+	logger := ws.logger.With("test_fire", true, "request_id", templateCtx.RequestID)
+	logger.Info("Test firing destination", "destination", name, "url", dest.URL)
+	result := ws.sendToDestination(ctx, dest, headers, logger)
+
+	delivery := TestDelivery{
+		Request: TestRequest{
+			Method:  dest.Method,
+			URL:     ws.secrets.Redact(dest.URL),
+			Headers: ws.redactHeaders(dest.Headers),
+			Body:    ws.secrets.Redact(string(dest.Body)),
+		},
+		Result: ws.redactResult(result),
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/throttle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// throttle returns the limiter pacing requests to the destination. Every
+// destination has one so that limits announced by the destination itself are
+// honored, the configured limits only apply to named destinations.
+func (ws *WebhookServer) throttle(dest configApi.ResolvedDestination) *throttle.Limiter {
+	cfg := &configApi.ThrottleConfig{}
+	if named, ok := ws.Config().Destinations[dest.Name]; ok && named.Throttle != nil {
+		cfg = named.Throttle
+	}
+
+	return ws.throttles.Get(throttleKey(dest), throttle.Settings{
+		Rate:        cfg.RatePerSecond(),
+		Burst:       cfg.GetBurst(),
+		MaxInFlight: cfg.MaxInFlight,
+		MaxWait:     cfg.GetMaxWait(),
+	})
+}
+
+// throttleKey separates inline destinations by host since they share a name
+func throttleKey(dest configApi.ResolvedDestination) string {
+	if dest.Name != "inline" {
+		return dest.Name
+	}
+
+	u, err := url.Parse(dest.URL)
+	if err != nil {
+		return dest.Name
+	}
+
+	return dest.Name + " " + u.Host
+}
+
+func (ws *WebhookServer) throttledResult(dest configApi.ResolvedDestination, limiter *throttle.Limiter, err error, logger *slog.Logger) configApi.ForwardResult {
+	logger.Warn("Destination throttled, not sending",
+		"destination", dest.Name,
+		"url", dest.URL,
+		"error", err)
+	ws.metrics.ForwardingTotal.WithLabelValues(dest.Name, "throttled").Inc()
+	ws.metrics.ThrottledTotal.WithLabelValues(dest.Name, "max_wait").Inc()
+
+	result := configApi.ForwardResult{
+		Destination: dest.Name,
+		URL:         dest.URL,
+		Method:      dest.Method,
+		Headers:     dest.Headers,
+		Success:     false,
+		Error:       err.Error(),
+		Throttled:   true,
+	}
+	if blocked := limiter.BlockedUntil(); blocked.After(time.Now()) {
+		result.RetryAfter = &blocked
+	}
+
+	return result
+}
+
+// throttleRetryAt returns the earliest time the destination is willing to be
+// called again
+func (ws *WebhookServer) throttleRetryAt(dest configApi.ResolvedDestination, fallback time.Time) time.Time {
+	if blocked := ws.throttle(dest).BlockedUntil(); blocked.After(fallback) {
+		return blocked
+	}
+
+	return fallback
+}
+
+// upstreamRetryAfter reads the rate limit headers of a response: Retry-After
+// on 429 and 503 responses, and the X-RateLimit-* headers Discord and others
+// send once a bucket is exhausted.
+func upstreamRetryAfter(resp *http.Response, now time.Time) *time.Time {
+	var until time.Time
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if value := resp.Header.Get("Retry-After"); value != "" {
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				until = now.Add(time.Duration(seconds * float64(time.Second)))
+			} else if date, err := http.ParseTime(value); err == nil {
+				until = date
+			}
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if seconds, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset-After"), 64); err == nil {
+			until = maxTime(until, now.Add(time.Duration(seconds*float64(time.Second))))
+		} else if epoch, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset"), 64); err == nil {
+			until = maxTime(until, time.UnixMilli(int64(epoch*1000)))
+		}
+	}
+
+	if !until.After(now) {
+		return nil
+	}
+
+	return &until
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
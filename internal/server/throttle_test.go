@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUpstreamRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration // 0 for none
+	}{
+		{"seconds", 429, http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{"date", 503, http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, time.Minute},
+		{"ignored on success", 200, http.Header{"Retry-After": {"30"}}, 0},
+		{"reset after", 200, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset-After": {"1.5"}}, 1500 * time.Millisecond},
+		{"reset epoch", 200, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(now.Unix()+5, 10)}}, 5 * time.Second},
+		{"remaining", 200, http.Header{"X-Ratelimit-Remaining": {"3"}, "X-Ratelimit-Reset-After": {"1.5"}}, 0},
+		{"later of both", 429, http.Header{"Retry-After": {"2"}, "X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset-After": {"10"}}, 10 * time.Second},
+		{"in the past", 429, http.Header{"Retry-After": {"0"}}, 0},
+	}
+
+	for _, tt := range tests {
+		got := upstreamRetryAfter(&http.Response{StatusCode: tt.status, Header: tt.header}, now)
+		switch {
+		case tt.want == 0 && got != nil:
+			t.Errorf("%s: got %s, want none", tt.name, got)
+		case tt.want != 0 && (got == nil || got.Sub(now) != tt.want):
+			t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleKeySeparatesInlineHosts(t *testing.T) {
+	a := throttleKey(configApi.ResolvedDestination{Name: "inline", URL: "https://a.example.com/x"})
+	b := throttleKey(configApi.ResolvedDestination{Name: "inline", URL: "https://b.example.com/x"})
+	if a == b {
+		t.Errorf("inline destinations share throttle key %q", a)
+	}
+	if key := throttleKey(configApi.ResolvedDestination{Name: "api", URL: "https://a.example.com"}); key != "api" {
+		t.Errorf("named destination key = %q", key)
+	}
+}
+
+func TestDestinationAskingToSlowDownIsHeldBack(t *testing.T) {
+	dest := newDestination(t, http.StatusTooManyRequests, http.StatusOK)
+	ws := newTestServer(t, `
+destinations:
+  echo:
+    url: "`+dest.URL+`"
+    retry:
+      initial_backoff: 1ms
+      max_attempts: 2
+    throttle:
+      max_in_flight: 5
+      max_wait: 10ms
+routes:
+  - path: "/hook"
+    matchers:
+      - expr: "true"
+        to: echo
+`, Options{})
+
+	dest.setHeader("Retry-After", "60")
+
+	send(ws, "POST", "/hook", `{}`)
+	send(ws, "POST", "/hook", `{}`)
+
+	if n := len(dest.received()); n != 1 {
+		t.Fatalf("destination received %d requests while it asked to wait", n)
+	}
+	if ws.retries.Len() != 2 {
+		t.Fatalf("retry queue length = %d", ws.retries.Len())
+	}
+
+	// Waiting for the destination doesn't use up attempts
+	ws.processRetries(context.Background())
+	due, _ := ws.retries.Due(time.Now().Add(time.Hour))
+	for _, d := range due {
+		if d.NextAttempt.Before(time.Now().Add(50 * time.Second)) {
+			t.Errorf("delivery %s scheduled at %s, before the destination's Retry-After", d.ID, d.NextAttempt)
+		}
+	}
+	queued, _ := ws.retries.List()
+	for _, d := range queued {
+		if d.Attempts > 1 {
+			t.Errorf("delivery %s used %d attempts", d.ID, d.Attempts)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"net/http"
+)
+
+// clientCertData describes the verified client certificate of the request
+func clientCertData(r *http.Request) configApi.ClientCertData {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return configApi.ClientCertData{}
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+
+	uris := make([]string, 0, len(cert.URIs))
+	for _, u := range cert.URIs {
+		uris = append(uris, u.String())
+	}
+
+	return configApi.ClientCertData{
+		Subject:      cert.Subject.String(),
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		Emails:       cert.EmailAddresses,
+		URIs:         uris,
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+	}
+}
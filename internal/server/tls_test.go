@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchersSeeVerifiedClientCertificate(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, `
+destinations:
+  echo: "`+dest.URL+`"
+routes:
+  - path: "/deploy"
+    matchers:
+      - expr: 'request.clientCert.commonName == "ci-runner" && "Example" in request.clientCert.organization'
+        to: echo
+`, Options{})
+
+	cert := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "ci-runner", Organization: []string{"Example"}},
+		SerialNumber: big.NewInt(7),
+	}
+	for _, state := range []*tls.ConnectionState{
+		{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		// A certificate the server didn't verify doesn't count
+		{PeerCertificates: []*x509.Certificate{cert}},
+		nil,
+	} {
+		r := httptest.NewRequest("POST", "/deploy", strings.NewReader("{}"))
+		r.TLS = state
+		ws.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestClientCertData(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "ci-runner", Organization: []string{"Example"}},
+		Issuer:         pkix.Name{CommonName: "clients-ca"},
+		SerialNumber:   big.NewInt(7),
+		DNSNames:       []string{"ci.example.com"},
+		EmailAddresses: []string{"ci@example.com"},
+	}
+	r := httptest.NewRequest("POST", "/", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	data := clientCertData(r)
+	if data.CommonName != "ci-runner" || data.Subject != "CN=ci-runner,O=Example" || data.Issuer != "CN=clients-ca" ||
+		data.SerialNumber != "7" || data.DNSNames[0] != "ci.example.com" || data.Emails[0] != "ci@example.com" {
+		t.Errorf("clientCertData = %+v", data)
+	}
+
+	if data := clientCertData(httptest.NewRequest("POST", "/", nil)); data.CommonName != "" {
+		t.Errorf("plain HTTP request has client certificate %+v", data)
+	}
+}
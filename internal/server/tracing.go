@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/url"
+)
+
+// tracer records spans through the globally installed tracer provider, see
+// tracing.Setup. Without one spans aren't recorded but trace context still
+// flows from callers to destinations.
+var tracer = otel.Tracer("github.com/framjet/go-webhook-middleman/internal/server")
+
+// statusWriter remembers the status code of a response for its span
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// traceRequest wraps a webhook route in a server span, continuing the trace
+// of the caller when the request carries a traceparent header
+func (ws *WebhookServer) traceRequest(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", path),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", ws.clientIP(r)),
+				attribute.String("user_agent.original", r.UserAgent()),
+			))
+		defer span.End()
+
+		recorder := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// startForwardSpan starts the client span of a delivery attempt
+func (ws *WebhookServer) startForwardSpan(ctx context.Context, dest configApi.ResolvedDestination) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("webhook.destination", dest.Name),
+		attribute.String("http.request.method", dest.Method),
+		attribute.String("url.full", ws.secrets.Redact(dest.URL)),
+	}
+	if u, err := url.Parse(dest.URL); err == nil {
+		attrs = append(attrs, attribute.String("server.address", u.Hostname()))
+	}
+
+	return tracer.Start(ctx, "forward "+dest.Name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endForwardSpan records the outcome of a delivery attempt
+func (ws *WebhookServer) endForwardSpan(span trace.Span, result configApi.ForwardResult) {
+	if result.StatusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode))
+	}
+	span.SetAttributes(
+		attribute.Bool("webhook.success", result.Success),
+		attribute.Bool("webhook.paused", result.Paused),
+		attribute.Bool("webhook.circuit_open", result.CircuitOpen),
+		attribute.Bool("webhook.throttled", result.Throttled),
+	)
+	if !result.Success {
+		span.SetStatus(codes.Error, ws.secrets.Redact(result.Error))
+	}
+	span.End()
+}
+
+// endSpan ends a span, marking it failed when err is set
+func (ws *WebhookServer) endSpan(span trace.Span, err error) {
+	if err != nil {
+		message := ws.secrets.Redact(err.Error())
+		span.AddEvent("exception", trace.WithAttributes(attribute.String("exception.message", message)))
+		span.SetStatus(codes.Error, message)
+	}
+	span.End()
+}
+
+// traceContextFrom continues the trace recorded in the headers of a stored
+// request, for retries and replays that run outside of the request
+func traceContextFrom(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// injectTraceContext adds the trace context of ctx to outbound headers,
+// replacing any the caller sent
+func injectTraceContext(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"github.com/framjet/go-webhook-middleman/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	inboundTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	inboundSpanID      = "00f067aa0ba902b7"
+	inboundTraceparent = "00-" + inboundTraceID + "-" + inboundSpanID + "-01"
+)
+
+var (
+	collectorOnce sync.Once
+	testCollector *tracing.Collector
+)
+
+// traceCollector exports the spans of every server in the test binary to a
+// collector, synchronously so that they are there once a request returns.
+// The package tracer is bound to the first provider installed, so the
+// provider is only set up once.
+func traceCollector(t *testing.T) *tracing.Collector {
+	t.Helper()
+
+	collectorOnce.Do(func() {
+		testCollector = tracing.NewCollector(nil)
+		server := httptest.NewServer(testCollector)
+
+		exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(server.URL+"/v1/traces"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+		if _, err := tracing.Setup(context.Background(), tracing.Options{}, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	return testCollector
+}
+
+// traceSpans returns the spans of a trace by name
+func traceSpans(collector *tracing.Collector, traceID string) map[string]tracing.Span {
+	spans := make(map[string]tracing.Span)
+	for _, span := range collector.Spans() {
+		if span.TraceID == traceID {
+			spans[span.Name] = span
+		}
+	}
+	return spans
+}
+
+func tracingConfig(dest, mode string) string {
+	return `
+destinations:
+  echo: "` + dest + `/{{.params.channel}}"
+routes:
+  - path: "/trace/{channel}"
+    mode: ` + mode + `
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func TestTracingContinuesCallerTrace(t *testing.T) {
+	collector := traceCollector(t)
+	dest := newDestination(t)
+	ws := newTestServer(t, tracingConfig(dest.URL, "sync"), Options{})
+
+	if w := send(ws, "POST", "/trace/general", `{}`, "Traceparent", inboundTraceparent); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	spans := traceSpans(collector, inboundTraceID)
+	server, ok := spans["POST /trace/{channel}"]
+	if !ok || server.Kind != "server" || server.ParentSpanID != inboundSpanID {
+		t.Fatalf("server span = %+v, spans %+v", server, spans)
+	}
+	if server.Attributes["http.route"] != "/trace/{channel}" || server.Attributes["url.path"] != "/trace/general" ||
+		server.Attributes["http.response.status_code"] != int64(200) {
+		t.Errorf("server attributes = %v", server.Attributes)
+	}
+
+	for _, name := range []string{"match route", "evaluate matcher", "render destination", "render response", "forward echo"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("no %q span in %v", name, spans)
+		}
+	}
+
+	forward := spans["forward echo"]
+	if forward.Kind != "client" || forward.Attributes["url.full"] != dest.URL+"/general" || forward.Attributes["webhook.success"] != true {
+		t.Errorf("forward span = %+v", forward)
+	}
+
+	// The destination continues the trace from the forward span
+	got := dest.received()
+	if len(got) != 1 || got[0].Header.Get("Traceparent") != "00-"+inboundTraceID+"-"+forward.SpanID+"-01" {
+		t.Errorf("outbound traceparent = %q", got[0].Header.Get("Traceparent"))
+	}
+}
+
+func TestTracingStartsTraceAndRecordsFailures(t *testing.T) {
+	collector := traceCollector(t)
+	dest := newDestination(t, http.StatusInternalServerError)
+	ws := newTestServer(t, tracingConfig(dest.URL, "sync"), Options{})
+
+	w := send(ws, "POST", "/trace/general", `{}`)
+
+	got := dest.received()
+	if len(got) != 1 {
+		t.Fatalf("destination received %d requests", len(got))
+	}
+	traceparent := strings.Split(got[0].Header.Get("Traceparent"), "-")
+	if len(traceparent) != 4 {
+		t.Fatalf("outbound traceparent = %q", got[0].Header.Get("Traceparent"))
+	}
+
+	spans := traceSpans(collector, traceparent[1])
+	if server := spans["POST /trace/{channel}"]; server.ParentSpanID != "" || server.Attributes["http.response.status_code"] != int64(w.Code) {
+		t.Errorf("server span = %+v", server)
+	}
+	if forward := spans["forward echo"]; forward.Status != "error" || forward.Attributes["webhook.success"] != false ||
+		forward.Attributes["http.response.status_code"] != int64(http.StatusInternalServerError) {
+		t.Errorf("forward span = %+v", forward)
+	}
+}
+
+func TestTracingFollowsAsyncDeliveries(t *testing.T) {
+	collector := traceCollector(t)
+	dest := newDestination(t)
+	ws := newTestServer(t, tracingConfig(dest.URL, "async"), Options{AsyncWorkers: 1, AsyncQueueSize: 10})
+
+	if w := send(ws, "POST", "/trace/general", `{}`, "Traceparent", inboundTraceparent); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if err := ws.WaitIdle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := dest.received()
+	if len(got) != 1 || !strings.HasPrefix(got[0].Header.Get("Traceparent"), "00-"+inboundTraceID+"-") {
+		t.Errorf("destination received %+v", got)
+	}
+	if _, ok := traceSpans(collector, inboundTraceID)["forward echo"]; !ok {
+		t.Error("async delivery isn't part of the caller's trace")
+	}
+}
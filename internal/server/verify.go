@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	configApi "github.com/framjet/go-webhook-middleman/internal/config"
+	"github.com/framjet/go-webhook-middleman/internal/templateRenderer"
+	"github.com/framjet/go-webhook-middleman/internal/verify"
+	"net/http"
+	"strings"
+)
+
+// verifyRequest checks the inbound signature configured on the route and
+// returns the error code to report when it fails.
+func (ws *WebhookServer) verifyRequest(cfg *configApi.VerifyConfig, r *http.Request, body []byte, ctx templateRenderer.TemplateContext) (string, error) {
+	secret, err := templateRenderer.RenderSecret(cfg.Secret, ctx)
+	if err != nil {
+		return "SIGNATURE_CONFIG_ERROR", fmt.Errorf("failed to render secret: %w", err)
+	}
+
+	publicURL := ws.requestURL(r)
+	if cfg.URL != "" {
+		publicURL, err = templateRenderer.RenderTemplate(cfg.URL, configApi.ResolvedDestination{}, ctx)
+		if err != nil {
+			return "SIGNATURE_CONFIG_ERROR", fmt.Errorf("failed to render url: %w", err)
+		}
+	}
+
+	err = verify.Verify(cfg, secret, verify.Request{
+		Header: r.Header,
+		Body:   body,
+		URL:    publicURL,
+	})
+
+	switch {
+	case err == nil:
+		return "", nil
+	case errors.Is(err, verify.ErrMissingSignature):
+		return "SIGNATURE_MISSING", err
+	case errors.Is(err, verify.ErrExpiredSignature):
+		return "SIGNATURE_EXPIRED", err
+	default:
+		return "SIGNATURE_INVALID", err
+	}
+}
+
+// requestURL reconstructs the absolute URL the client used, honoring
+// X-Forwarded-Proto when the request comes from a TLS-terminating proxy
+// listed in --trusted-proxies
+func (ws *WebhookServer) requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if ws.fromTrustedProxy(r) {
+		// The first proxy in a chain saw the client's scheme
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+	}
+
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
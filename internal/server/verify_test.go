@@ -0,0 +1,104 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func verifyConfig(dest, secret string) string {
+	return `
+destinations:
+  echo: "` + dest + `"
+variables:
+  github_secret: "` + secret + `"
+routes:
+  - path: "/github"
+    verify:
+      provider: github
+      secret: "{{.var.github_secret}}"
+    matchers:
+      - expr: "true"
+        to: echo
+`
+}
+
+func githubSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error response %s: %v", w.Body, err)
+	}
+	return response.Code
+}
+
+func TestVerifiedRequestIsForwarded(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, verifyConfig(dest.URL, "s3cret"), Options{})
+
+	body := `{"a":1}`
+	if w := send(ws, "POST", "/github", body, "X-Hub-Signature-256", githubSignature("s3cret", body)); w.Code != http.StatusOK {
+		t.Fatalf("signed request: status = %d, body %s", w.Code, w.Body)
+	}
+
+	w := send(ws, "POST", "/github", body, "X-Hub-Signature-256", githubSignature("other", body))
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "SIGNATURE_INVALID" {
+		t.Errorf("wrongly signed request: status = %d, body %s", w.Code, w.Body)
+	}
+	w = send(ws, "POST", "/github", body)
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "SIGNATURE_MISSING" {
+		t.Errorf("unsigned request: status = %d, body %s", w.Code, w.Body)
+	}
+
+	if n := len(dest.received()); n != 1 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestEmptyVerifySecretIsRejected(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, verifyConfig(dest.URL, ""), Options{})
+
+	// An attacker can sign with the empty key, it must not be accepted
+	body := `{}`
+	w := send(ws, "POST", "/github", body, "X-Hub-Signature-256", githubSignature("", body))
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "SIGNATURE_CONFIG_ERROR" {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+	if n := len(dest.received()); n != 0 {
+		t.Errorf("destination received %d requests", n)
+	}
+}
+
+func TestRequestURLTrustsForwardedProtoOnlyFromProxies(t *testing.T) {
+	dest := newDestination(t)
+	ws := newTestServer(t, verifyConfig(dest.URL, "s3cret"), Options{TrustedProxies: []string{"10.0.0.0/8"}})
+
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"10.1.2.3:4000", "https://example.com/sms?a=1"},
+		{"192.0.2.1:4000", "http://example.com/sms?a=1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "http://example.com/sms?a=1", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set("X-Forwarded-Proto", "https, http")
+
+		if got := ws.requestURL(r); got != tt.want {
+			t.Errorf("requestURL from %s = %s, want %s", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sign computes the signature headers for an outbound request body according
+// to the signing scheme. The delivery ID should stay the same across retries.
+func Sign(cfg *config.SigningConfig, deliveryID string, now time.Time, body []byte) (map[string]string, error) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	switch cfg.Scheme {
+	case config.SigningSchemeStandardWebhooks:
+		return signStandardWebhooks(cfg, deliveryID, timestamp, body)
+	case config.SigningSchemeGitHub:
+		signature := computeHMAC(sha256.New, []byte(cfg.Secret), body)
+		return map[string]string{
+			"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(signature),
+		}, nil
+	default:
+		return signCustom(cfg, deliveryID, timestamp, body)
+	}
+}
+
+// signStandardWebhooks follows https://www.standardwebhooks.com: the content
+// "id.timestamp.body" is signed with a whsec_ HMAC secret (v1) or a whsk_
+// Ed25519 key (v1a).
+func signStandardWebhooks(cfg *config.SigningConfig, deliveryID, timestamp string, body []byte) (map[string]string, error) {
+	content := []byte(deliveryID + "." + timestamp + "." + string(body))
+
+	var signature string
+	if cfg.GetAlgorithm() == config.SigningAlgorithmEd25519 {
+		key, err := decodeEd25519Key(strings.TrimPrefix(cfg.Secret, "whsk_"))
+		if err != nil {
+			return nil, err
+		}
+		signature = "v1a," + base64.StdEncoding.EncodeToString(ed25519.Sign(key, content))
+	} else {
+		secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cfg.Secret, "whsec_"))
+		if err != nil {
+			return nil, fmt.Errorf("standard-webhooks secret is not valid base64: %w", err)
+		}
+		signature = "v1," + base64.StdEncoding.EncodeToString(computeHMAC(sha256.New, secret, content))
+	}
+
+	return map[string]string{
+		"webhook-id":        deliveryID,
+		"webhook-timestamp": timestamp,
+		"webhook-signature": signature,
+	}, nil
+}
+
+// signCustom signs the body, prefixed with the delivery ID and timestamp when
+// their headers are configured, joined by dots.
+func signCustom(cfg *config.SigningConfig, deliveryID, timestamp string, body []byte) (map[string]string, error) {
+	headers := map[string]string{}
+
+	var parts []string
+	if cfg.IDHeader != "" {
+		parts = append(parts, deliveryID)
+		headers[cfg.IDHeader] = deliveryID
+	}
+	if cfg.TimestampHeader != "" {
+		parts = append(parts, timestamp)
+		headers[cfg.TimestampHeader] = timestamp
+	}
+	parts = append(parts, string(body))
+	content := []byte(strings.Join(parts, "."))
+
+	var signature []byte
+	switch cfg.GetAlgorithm() {
+	case config.SigningAlgorithmEd25519:
+		key, err := decodeEd25519Key(cfg.Secret)
+		if err != nil {
+			return nil, err
+		}
+		signature = ed25519.Sign(key, content)
+	case config.SigningAlgorithmHMACSHA512:
+		signature = computeHMAC(sha512.New, []byte(cfg.Secret), content)
+	default:
+		signature = computeHMAC(sha256.New, []byte(cfg.Secret), content)
+	}
+
+	encoded := hex.EncodeToString(signature)
+	if cfg.Encoding == "base64" {
+		encoded = base64.StdEncoding.EncodeToString(signature)
+	}
+
+	header := cfg.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	headers[header] = cfg.Prefix + encoded
+
+	return headers, nil
+}
+
+// decodeEd25519Key accepts a base64 encoded 32 byte seed or 64 byte private key
+func decodeEd25519Key(encoded string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("ed25519 key is not valid base64: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("ed25519 key must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+func computeHMAC(h func() hash.Hash, secret, data []byte) []byte {
+	mac := hmac.New(h, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
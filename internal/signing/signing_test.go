@@ -0,0 +1,145 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"testing"
+	"time"
+)
+
+var now = time.Unix(1700000000, 0)
+
+func TestSignStandardWebhooksHMAC(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	cfg := &config.SigningConfig{Scheme: config.SigningSchemeStandardWebhooks, Secret: "whsec_" + base64.StdEncoding.EncodeToString(key)}
+
+	headers, err := Sign(cfg, "msg_1", now, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(`msg_1.1700000000.{"a":1}`))
+	want := "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if headers["webhook-id"] != "msg_1" || headers["webhook-timestamp"] != "1700000000" || headers["webhook-signature"] != want {
+		t.Errorf("headers = %v, want signature %s", headers, want)
+	}
+
+	cfg.Secret = "whsec_not base64"
+	if _, err := Sign(cfg, "msg_1", now, nil); err == nil {
+		t.Error("Sign accepted a secret that isn't base64")
+	}
+}
+
+func TestSignStandardWebhooksEd25519(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	cfg := &config.SigningConfig{
+		Scheme:    config.SigningSchemeStandardWebhooks,
+		Algorithm: config.SigningAlgorithmEd25519,
+		Secret:    "whsk_" + base64.StdEncoding.EncodeToString(seed),
+	}
+
+	headers, err := Sign(cfg, "msg_1", now, []byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(headers["webhook-signature"][len("v1a,"):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte("msg_1.1700000000.body"), signature) {
+		t.Errorf("signature %s doesn't verify", headers["webhook-signature"])
+	}
+}
+
+func TestSignGitHub(t *testing.T) {
+	cfg := &config.SigningConfig{Scheme: config.SigningSchemeGitHub, Secret: "s3cret"}
+
+	headers, err := Sign(cfg, "id", now, []byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("body"))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); headers["X-Hub-Signature-256"] != want || len(headers) != 1 {
+		t.Errorf("headers = %v, want %s", headers, want)
+	}
+}
+
+func TestSignCustom(t *testing.T) {
+	mac := func(content string) []byte {
+		m := hmac.New(sha512.New, []byte("s3cret"))
+		m.Write([]byte(content))
+		return m.Sum(nil)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.SigningConfig
+		want map[string]string
+	}{
+		{
+			"body only",
+			config.SigningConfig{Algorithm: config.SigningAlgorithmHMACSHA512, Secret: "s3cret"},
+			map[string]string{"X-Signature": hex.EncodeToString(mac("body"))},
+		},
+		{
+			"id and timestamp",
+			config.SigningConfig{
+				Algorithm:       config.SigningAlgorithmHMACSHA512,
+				Secret:          "s3cret",
+				Header:          "X-Sig",
+				Prefix:          "v1=",
+				Encoding:        "base64",
+				IDHeader:        "X-Id",
+				TimestampHeader: "X-Ts",
+			},
+			map[string]string{
+				"X-Sig": "v1=" + base64.StdEncoding.EncodeToString(mac("id.1700000000.body")),
+				"X-Id":  "id",
+				"X-Ts":  "1700000000",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, err := Sign(&tt.cfg, "id", now, []byte("body"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(headers) != len(tt.want) {
+				t.Fatalf("headers = %v, want %v", headers, tt.want)
+			}
+			for name, value := range tt.want {
+				if headers[name] != value {
+					t.Errorf("%s = %s, want %s", name, headers[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeEd25519Key(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	private := ed25519.NewKeyFromSeed(seed)
+
+	for _, encoded := range []string{base64.StdEncoding.EncodeToString(seed), base64.StdEncoding.EncodeToString(private)} {
+		key, err := decodeEd25519Key(encoded)
+		if err != nil || !key.Equal(private) {
+			t.Errorf("decodeEd25519Key(%s) = %v", encoded, err)
+		}
+	}
+
+	if _, err := decodeEd25519Key(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("decodeEd25519Key accepted a short key")
+	}
+}
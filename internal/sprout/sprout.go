@@ -4,11 +4,26 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-sprout/sprout"
+	"github.com/go-sprout/sprout/group/all"
+	"github.com/go-sprout/sprout/registry/backward"
 	"net/url"
+	"sync"
+	"text/template"
 )
 
 var ErrTemplateStopped = errors.New("template stopped")
 
+// FuncMap returns the functions available to every template: all sprout
+// registries plus the middleman ones. It is built once and shared.
+var FuncMap = sync.OnceValue(func() template.FuncMap {
+	handler := sprout.New()
+	handler.AddGroups(all.RegistryGroup())
+	handler.AddRegistry(backward.NewRegistry())
+	handler.AddRegistry(NewRegistry())
+
+	return handler.Build()
+})
+
 func GetErrTemplateStopped() error {
 	return ErrTemplateStopped
 }
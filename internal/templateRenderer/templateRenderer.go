@@ -6,10 +6,8 @@ import (
 	"fmt"
 	"github.com/framjet/go-webhook-middleman/internal/config"
 	wmSprout "github.com/framjet/go-webhook-middleman/internal/sprout"
-	"github.com/go-sprout/sprout"
-	"github.com/go-sprout/sprout/group/all"
-	"github.com/go-sprout/sprout/registry/backward"
 	"net/http"
+	"strings"
 	"text/template"
 )
 
@@ -17,18 +15,17 @@ var (
 	tplRenderer = NewTemplateRenderer()
 )
 
+// ErrInvalidSecret is returned by RenderSecret for secrets that render empty
+// or from a missing value
+var ErrInvalidSecret = errors.New("secret rendered empty or from a missing value")
+
 type TemplateRenderer struct {
 	FunctionMap template.FuncMap
 }
 
 func NewTemplateRenderer() *TemplateRenderer {
-	handler := sprout.New()
-	handler.AddGroups(all.RegistryGroup())
-	handler.AddRegistry(backward.NewRegistry())
-	handler.AddRegistry(wmSprout.NewRegistry())
-
 	return &TemplateRenderer{
-		FunctionMap: handler.Build(),
+		FunctionMap: wmSprout.FuncMap(),
 	}
 }
 
@@ -38,37 +35,37 @@ type TemplateContext struct {
 	Body      string
 	Route     config.Route
 	Request   http.Request
+	Auth      config.AuthInfo
+	Parsed    *config.ParsedBody // Structured body, parsed on first use
+	Templates *config.TemplateCache
+	RequestID string // Identifies the request in logs and to the caller and destinations
 }
 
 func GetTplRenderer() *TemplateRenderer {
 	return tplRenderer
 }
 
+// AuthContext exposes the authenticated caller to templates as .auth
+func AuthContext(info config.AuthInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"method":  info.Method,
+		"subject": info.Subject,
+		"claims":  info.Claims,
+	}
+}
+
 func RenderTemplate(tmpl string, resolved config.ResolvedDestination, ctx TemplateContext) (string, error) {
 	if tmpl == "" {
 		return "", nil
 	}
 
-	t, err := template.New("webhook").Funcs(GetTplRenderer().FunctionMap).Parse(tmpl)
+	t, err := ctx.Templates.Get(tmpl)
 	if err != nil {
-		return "", fmt.Errorf("template parse error: %w", err)
+		return "", err
 	}
 
 	var buf bytes.Buffer
-	data := map[string]interface{}{
-		"params":  ctx.Params,
-		"var":     ctx.Variables,
-		"body":    ctx.Body,
-		"route":   ctx.Route,
-		"request": ctx.Request,
-		"resolved": map[string]interface{}{
-			"method":  resolved.Method,
-			"headers": resolved.Headers,
-			"body":    string(resolved.Body),
-		},
-	}
-
-	err = t.Execute(&buf, data)
+	err = t.Execute(&buf, templateData(t, resolved, ctx))
 	if err != nil {
 		if errors.Is(err, wmSprout.GetErrTemplateStopped()) {
 			return "", err
@@ -79,3 +76,46 @@ func RenderTemplate(tmpl string, resolved config.ResolvedDestination, ctx Templa
 
 	return buf.String(), nil
 }
+
+// RenderSecret renders the template of a key, token or secret. Unlike
+// RenderTemplate a reference to a missing key is an error, and so is a secret
+// that renders empty, so a mistake in the config never yields a known secret.
+func RenderSecret(tmpl string, ctx TemplateContext) (string, error) {
+	t, err := ctx.Templates.GetSecret(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, templateData(t, config.ResolvedDestination{}, ctx)); err != nil {
+		return "", fmt.Errorf("template execute error: %w", err)
+	}
+
+	secret := buf.String()
+	if strings.TrimSpace(secret) == "" || strings.Contains(secret, "<no value>") {
+		return "", ErrInvalidSecret
+	}
+
+	return secret, nil
+}
+
+func templateData(t *template.Template, resolved config.ResolvedDestination, ctx TemplateContext) map[string]interface{} {
+	jsonBody, formBody := config.BodyData(t, ctx.Parsed)
+
+	return map[string]interface{}{
+		"params":    ctx.Params,
+		"var":       ctx.Variables,
+		"body":      ctx.Body,
+		"json":      jsonBody,
+		"form":      formBody,
+		"route":     ctx.Route,
+		"request":   ctx.Request,
+		"auth":      AuthContext(ctx.Auth),
+		"requestId": ctx.RequestID,
+		"resolved": map[string]interface{}{
+			"method":  resolved.Method,
+			"headers": resolved.Headers,
+			"body":    string(resolved.Body),
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package templateRenderer
+
+import (
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"testing"
+)
+
+func render(t *testing.T, tmpl string, ctx TemplateContext) string {
+	t.Helper()
+
+	out, err := RenderTemplate(tmpl, config.ResolvedDestination{}, ctx)
+	if err != nil {
+		t.Fatalf("RenderTemplate(%q): %v", tmpl, err)
+	}
+	return out
+}
+
+func TestRenderTemplateParsedBody(t *testing.T) {
+	ctx := TemplateContext{
+		Body:   `{"repo": {"name": "api"}}`,
+		Parsed: config.NewParsedBody(`{"repo": {"name": "api"}}`, "application/json"),
+	}
+	if out := render(t, "{{.json.repo.name}}", ctx); out != "api" {
+		t.Errorf("json = %q", out)
+	}
+
+	ctx = TemplateContext{Parsed: config.NewParsedBody("team=ops", "application/x-www-form-urlencoded")}
+	if out := render(t, `{{index .form "team" 0}}`, ctx); out != "ops" {
+		t.Errorf("form = %q", out)
+	}
+
+	if out := render(t, "{{with .json}}json{{else}}none{{end}}", TemplateContext{}); out != "none" {
+		t.Errorf("without a body = %q", out)
+	}
+}
+
+func TestRenderSecret(t *testing.T) {
+	ctx := TemplateContext{
+		Params:    map[string]string{"tenant": "acme"},
+		Variables: map[string]string{"token": "s3cret"},
+	}
+
+	if secret, err := RenderSecret("{{.var.token}}-{{.params.tenant}}", ctx); err != nil || secret != "s3cret-acme" {
+		t.Errorf("RenderSecret = %q, %v", secret, err)
+	}
+	if _, err := RenderSecret("{{.params.region}}", ctx); err == nil {
+		t.Error("secret from a missing key rendered")
+	}
+	for _, tmpl := range []string{"  ", "{{.var.empty}}", "key-{{.auth.claims.kid}}"} {
+		if _, err := RenderSecret(tmpl, ctx); err == nil {
+			t.Errorf("RenderSecret(%q) succeeded", tmpl)
+		}
+	}
+}
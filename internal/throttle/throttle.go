@@ -0,0 +1,181 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var ErrWaitExceeded = errors.New("destination busy, wait would exceed max_wait")
+
+// Settings control how requests to a destination are paced
+type Settings struct {
+	Rate        float64       // Requests per second, 0 for no rate limit
+	Burst       int           // Requests that may be sent back to back
+	MaxInFlight int           // Concurrent requests, 0 for unlimited
+	MaxWait     time.Duration // Longest a request waits for its turn
+}
+
+// Limiter paces requests to one destination. Besides the configured limits it
+// holds requests back while the destination asked us to, see Block.
+type Limiter struct {
+	mu           sync.Mutex
+	settings     Settings
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+	sem          chan struct{}
+}
+
+func New(settings Settings) *Limiter {
+	l := &Limiter{last: time.Now()}
+	l.update(settings)
+	return l
+}
+
+// Update applies new settings, keeping the current state where possible
+func (l *Limiter) Update(settings Settings) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.settings != settings {
+		l.update(settings)
+	}
+}
+
+func (l *Limiter) update(settings Settings) {
+	if settings.Burst != l.settings.Burst || l.settings.Rate == 0 {
+		l.tokens = float64(settings.Burst)
+	}
+	// Requests in flight keep the old semaphore and release into it
+	if settings.MaxInFlight != l.settings.MaxInFlight || l.sem == nil {
+		l.sem = nil
+		if settings.MaxInFlight > 0 {
+			l.sem = make(chan struct{}, settings.MaxInFlight)
+		}
+	}
+	l.settings = settings
+}
+
+// Acquire waits until the request may be sent and returns a function that
+// must be called once it completes. It fails with ErrWaitExceeded without
+// waiting when the request wouldn't get its turn within MaxWait.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	sem := l.sem
+	deadline := time.Now().Add(l.settings.MaxWait)
+	l.mu.Unlock()
+
+	release := func() {}
+	if sem != nil {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+
+		select {
+		case sem <- struct{}{}:
+			release = func() { <-sem }
+		case <-timer.C:
+			return nil, ErrWaitExceeded
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	delay, ok := l.reserve(time.Now(), deadline)
+	if !ok {
+		release()
+		return nil, ErrWaitExceeded
+	}
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+// reserve takes a token, which may be one that only becomes available in the
+// future, and returns how long to wait for it
+func (l *Limiter) reserve(now, deadline time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delay := max(l.blockedUntil.Sub(now), 0)
+
+	if l.settings.Rate > 0 {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(float64(l.settings.Burst), l.tokens+elapsed*l.settings.Rate)
+		l.last = now
+
+		if l.tokens < 1 {
+			delay = max(delay, time.Duration((1-l.tokens)/l.settings.Rate*float64(time.Second)))
+		}
+	}
+
+	if now.Add(delay).After(deadline) {
+		return 0, false
+	}
+
+	if l.settings.Rate > 0 {
+		l.tokens--
+	}
+
+	return delay, true
+}
+
+// Block holds back requests until the given time, for example when the
+// destination answered with Retry-After
+func (l *Limiter) Block(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// BlockedUntil returns until when the destination asked us to hold back
+func (l *Limiter) BlockedUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.blockedUntil
+}
+
+// Registry holds one limiter per destination
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Get returns the limiter for the destination, creating it on first use and
+// applying the settings otherwise
+func (r *Registry) Get(name string, settings Settings) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.limiters[name]
+	if !ok {
+		l = New(settings)
+		r.limiters[name] = l
+		return l
+	}
+
+	l.Update(settings)
+
+	return l
+}
@@ -0,0 +1,113 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRatePacesRequests(t *testing.T) {
+	l := New(Settings{Rate: 20, Burst: 2, MaxWait: time.Second})
+
+	start := time.Now()
+	for range 4 {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	// Two requests go out in the burst, the other two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests at 20/s with burst 2 took %s", elapsed)
+	}
+}
+
+func TestMaxWaitFailsFast(t *testing.T) {
+	l := New(Settings{Rate: 1, Burst: 1, MaxWait: 100 * time.Millisecond})
+
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrWaitExceeded) {
+		t.Fatalf("Acquire = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("waited %s before failing", elapsed)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	l := New(Settings{MaxInFlight: 1, MaxWait: 50 * time.Millisecond})
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrWaitExceeded) {
+		t.Fatalf("second request in flight: %v", err)
+	}
+
+	release()
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Errorf("after release: %v", err)
+	}
+}
+
+func TestAcquireHonorsContext(t *testing.T) {
+	l := New(Settings{MaxInFlight: 1, MaxWait: time.Minute})
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire = %v", err)
+	}
+}
+
+func TestBlockHoldsRequestsBack(t *testing.T) {
+	l := New(Settings{MaxWait: time.Second})
+
+	until := time.Now().Add(50 * time.Millisecond)
+	l.Block(until)
+	l.Block(time.Now()) // An earlier block doesn't shorten it
+	if !l.BlockedUntil().Equal(until) {
+		t.Fatalf("BlockedUntil = %s", l.BlockedUntil())
+	}
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if time.Now().Before(until) {
+		t.Error("request sent while blocked")
+	}
+
+	l.Block(time.Now().Add(time.Hour))
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrWaitExceeded) {
+		t.Errorf("block beyond max_wait: %v", err)
+	}
+}
+
+func TestRegistryUpdatesSettings(t *testing.T) {
+	r := NewRegistry()
+
+	l := r.Get("api", Settings{MaxInFlight: 1, MaxWait: 10 * time.Millisecond})
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Get("api", Settings{MaxInFlight: 2, MaxWait: 10 * time.Millisecond}) != l {
+		t.Fatal("registry replaced the limiter")
+	}
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Errorf("raised max_in_flight not applied: %v", err)
+	}
+}
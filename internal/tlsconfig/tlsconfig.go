@@ -0,0 +1,184 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/cliutil"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ClientAuthNone          = "none"
+	ClientAuthVerifyIfGiven = "verify-if-given"
+	ClientAuthRequire       = "require"
+)
+
+// Options configures the TLS listener
+type Options struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // CA bundle client certificates are verified against, enables mTLS
+	ClientAuth   string // none, verify-if-given or require, default require when ClientCAFile is set
+}
+
+// Reloader serves the certificate and client CA pool from files and swaps
+// them when the files change, so certificates can rotate without a restart.
+type Reloader struct {
+	opts   Options
+	logger *slog.Logger
+
+	cert     atomic.Pointer[tls.Certificate]
+	clientCA atomic.Pointer[x509.CertPool]
+
+	mu   sync.Mutex
+	sums string // Checksums of the loaded files
+}
+
+// NewReloader loads the certificate files and fails if they are unusable
+func NewReloader(opts Options, logger *slog.Logger) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("both a TLS certificate and key are required")
+	}
+	if opts.ClientAuth == "" {
+		opts.ClientAuth = ClientAuthNone
+		if opts.ClientCAFile != "" {
+			opts.ClientAuth = ClientAuthRequire
+		}
+	}
+	switch opts.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthVerifyIfGiven, ClientAuthRequire:
+		if opts.ClientCAFile == "" {
+			return nil, fmt.Errorf("client auth '%s' needs a client CA file", opts.ClientAuth)
+		}
+	default:
+		return nil, fmt.Errorf("unknown client auth '%s'", opts.ClientAuth)
+	}
+
+	r := &Reloader{opts: opts, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the certificate files again. On failure the previous
+// certificates stay in use.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Checksum first so a change while loading is picked up on the next poll
+	sums := r.checksums()
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", r.opts.ClientCAFile)
+		}
+	}
+
+	r.cert.Store(&cert)
+	r.clientCA.Store(pool)
+	r.sums = sums
+
+	return nil
+}
+
+// TLSConfig returns a server config that always uses the latest certificates
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert.Load()},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+
+			switch r.opts.ClientAuth {
+			case ClientAuthRequire:
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = r.clientCA.Load()
+			case ClientAuthVerifyIfGiven:
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+				config.ClientCAs = r.clientCA.Load()
+			}
+
+			return config, nil
+		},
+	}
+}
+
+// Watch polls the certificate files every interval and reloads them when
+// their content changes. It returns when ctx is cancelled.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Certificate and key are often replaced one after the other,
+			// retry on the next tick until they match up
+			if !r.changed() {
+				continue
+			}
+
+			if err := r.Reload(); err != nil {
+				r.logger.Warn("TLS certificate reload failed, keeping previous certificate", "error", err)
+				continue
+			}
+
+			r.logger.Info("TLS certificate reloaded", "cert", r.opts.CertFile)
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.checksums() != r.sums
+}
+
+func (r *Reloader) checksums() string {
+	var sums []string
+	for _, path := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		sum, err := cliutil.FileChecksum(path)
+		if err != nil {
+			sum = "error"
+		}
+		sums = append(sums, sum)
+	}
+
+	return strings.Join(sums, ",")
+}
@@ -0,0 +1,234 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate signed by parent, or a self-signed CA when parent is nil
+func issue(t *testing.T, name string, serial int64, parent *keyPair) *keyPair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &keyPair{cert: cert, key: key}
+}
+
+// write stores the certificate and key as PEM files named after prefix
+func (p *keyPair) write(t *testing.T, dir, prefix string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, prefix+".crt")
+	keyFile = filepath.Join(dir, prefix+".key")
+	writePEM(t, certFile, "CERTIFICATE", p.cert.Raw)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	return certFile, keyFile
+}
+
+func (p *keyPair) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{p.cert.Raw}, PrivateKey: p.key}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// serve starts an HTTPS server with the reloader's config
+func serve(t *testing.T, r *Reloader) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.TLS = r.TLSConfig()
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// handshake connects to the server and returns the serial of its certificate
+func handshake(server *httptest.Server, ca *keyPair, client *keyPair) (int64, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if client != nil {
+		// Present the certificate even when the server doesn't list its CA
+		cert := client.tlsCertificate()
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert, nil
+		}
+	}
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), config)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// TLS 1.3 reports a rejected client certificate on the first read
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			return 0, err
+		}
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestNewReloaderValidatesOptions(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", 1, nil)
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir, "server")
+
+	for name, opts := range map[string]Options{
+		"no key":           {CertFile: certFile},
+		"require no ca":    {CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire},
+		"unknown auth":     {CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"},
+		"missing cert":     {CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile},
+		"mismatched files": {CertFile: keyFile, KeyFile: certFile},
+	} {
+		if _, err := NewReloader(opts, discardLogger()); err == nil {
+			t.Errorf("%s: NewReloader succeeded", name)
+		}
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", 1, nil)
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir, "server")
+	caFile, _ := ca.write(t, dir, "ca")
+
+	client := issue(t, "client", 3, ca)
+	stranger := issue(t, "stranger", 4, issue(t, "other ca", 5, nil))
+
+	tests := []struct {
+		clientAuth string
+		client     *keyPair
+		succeed    bool
+	}{
+		{"", nil, false}, // require is the default with a client CA
+		{"", client, true},
+		{ClientAuthRequire, stranger, false},
+		{ClientAuthVerifyIfGiven, nil, true},
+		{ClientAuthVerifyIfGiven, stranger, false},
+	}
+	for _, tt := range tests {
+		r, err := NewReloader(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: tt.clientAuth}, discardLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = handshake(serve(t, r), ca, tt.client)
+		if (err == nil) != tt.succeed {
+			name := "no client certificate"
+			if tt.client != nil {
+				name = tt.client.cert.Subject.CommonName
+			}
+			t.Errorf("client auth %q with %s: %v", tt.clientAuth, name, err)
+		}
+	}
+}
+
+func TestReloadSwapsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", 1, nil)
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir, "server")
+
+	r, err := NewReloader(Options{CertFile: certFile, KeyFile: keyFile}, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := serve(t, r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	// A half written pair keeps the previous certificate
+	writePEM(t, certFile, "CERTIFICATE", []byte("garbage"))
+	time.Sleep(50 * time.Millisecond)
+	if serial, err := handshake(server, ca, nil); err != nil || serial != 2 {
+		t.Fatalf("after a broken update: serial %d, %v", serial, err)
+	}
+
+	issue(t, "server", 6, ca).write(t, dir, "server")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		serial, err := handshake(server, ca, nil)
+		if err == nil && serial == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate not reloaded: serial %d, %v", serial, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,197 @@
+package tracing
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a span received by the Collector
+type Span struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	Service      string         `json:"service,omitempty"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Status       string         `json:"status,omitempty"` // "ok" or "error", empty when unset
+	Message      string         `json:"message,omitempty"`
+}
+
+// Collector is a stand-in for an OpenTelemetry collector that accepts spans
+// over OTLP/HTTP, in protobuf or JSON, and keeps them in memory. Point the
+// exporter at it to see the spans a request produces.
+type Collector struct {
+	mu     sync.Mutex
+	spans  []Span
+	onSpan func(Span)
+}
+
+// NewCollector returns an empty collector. onSpan, if set, is called for
+// every span received.
+func NewCollector(onSpan func(Span)) *Collector {
+	return &Collector{onSpan: onSpan}
+}
+
+// Spans returns the spans received so far
+func (c *Collector) Spans() []Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Span(nil), c.spans...)
+}
+
+// Reset forgets the spans received so far
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.spans = nil
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var request coltracepb.ExportTraceServiceRequest
+	switch contentType {
+	case "application/x-protobuf":
+		err = proto.Unmarshal(data, &request)
+	case "application/json":
+		err = protojson.Unmarshal(data, &request)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported content type '%s'", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.add(&request)
+
+	response, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	if contentType == "application/json" {
+		response, _ = protojson.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(response)
+}
+
+func (c *Collector) add(request *coltracepb.ExportTraceServiceRequest) {
+	var received []Span
+	for _, resourceSpans := range request.GetResourceSpans() {
+		service := ""
+		for _, attr := range resourceSpans.GetResource().GetAttributes() {
+			if attr.GetKey() == "service.name" {
+				service = attr.GetValue().GetStringValue()
+			}
+		}
+
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			for _, span := range scopeSpans.GetSpans() {
+				received = append(received, convertSpan(span, service))
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.spans = append(c.spans, received...)
+	c.mu.Unlock()
+
+	if c.onSpan != nil {
+		for _, span := range received {
+			c.onSpan(span)
+		}
+	}
+}
+
+func convertSpan(span *tracepb.Span, service string) Span {
+	converted := Span{
+		TraceID: hex.EncodeToString(span.GetTraceId()),
+		SpanID:  hex.EncodeToString(span.GetSpanId()),
+		Name:    span.GetName(),
+		Kind:    strings.ToLower(strings.TrimPrefix(span.GetKind().String(), "SPAN_KIND_")),
+		Service: service,
+		Start:   time.Unix(0, int64(span.GetStartTimeUnixNano())).UTC(),
+		End:     time.Unix(0, int64(span.GetEndTimeUnixNano())).UTC(),
+		Message: span.GetStatus().GetMessage(),
+	}
+	if parent := span.GetParentSpanId(); len(parent) > 0 {
+		converted.ParentSpanID = hex.EncodeToString(parent)
+	}
+
+	switch span.GetStatus().GetCode() {
+	case tracepb.Status_STATUS_CODE_OK:
+		converted.Status = "ok"
+	case tracepb.Status_STATUS_CODE_ERROR:
+		converted.Status = "error"
+	}
+
+	if attrs := span.GetAttributes(); len(attrs) > 0 {
+		converted.Attributes = make(map[string]any, len(attrs))
+		for _, attr := range attrs {
+			converted.Attributes[attr.GetKey()] = attributeValue(attr.GetValue())
+		}
+	}
+
+	return converted
+}
+
+func attributeValue(value *commonpb.AnyValue) any {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]any, len(v.ArrayValue.GetValues()))
+		for i, item := range v.ArrayValue.GetValues() {
+			values[i] = attributeValue(item)
+		}
+		return values
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Options configures span export
+type Options struct {
+	Endpoint       string // OTLP/HTTP endpoint such as http://localhost:4318, export is disabled when empty
+	ServiceName    string // Overridden by OTEL_SERVICE_NAME
+	ServiceVersion string
+	SampleRatio    float64 // Share of new traces recorded, traces started by callers follow their sampling decision
+}
+
+// Setup installs the W3C trace context propagator and, when an endpoint is
+// configured, a tracer provider exporting spans over OTLP/HTTP. Without an
+// endpoint spans aren't recorded but trace context is still passed on from
+// callers to destinations. The returned function flushes pending spans.
+func Setup(ctx context.Context, opts Options, logger *slog.Logger) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, err := EndpointURL(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			attribute.String("service.name", opts.ServiceName),
+			attribute.String("service.version", opts.ServiceVersion),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("Tracing error", "error", err)
+	}))
+
+	return provider.Shutdown, nil
+}
+
+// EndpointURL returns the URL spans are posted to. Like
+// OTEL_EXPORTER_OTLP_ENDPOINT an endpoint without a path gets /v1/traces.
+func EndpointURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid OTLP endpoint '%s': %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid OTLP endpoint '%s': must be an http:// or https:// URL", endpoint)
+	}
+
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/v1/traces"
+	}
+
+	return u.String(), nil
+}
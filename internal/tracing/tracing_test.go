@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointURL(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		want     string
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/traces"},
+		{"http://localhost:4318/", "http://localhost:4318/v1/traces"},
+		{"https://collector.example.com/otlp/v1/traces", "https://collector.example.com/otlp/v1/traces"},
+	} {
+		if got, err := EndpointURL(tc.endpoint); err != nil || got != tc.want {
+			t.Errorf("EndpointURL(%q) = %q, %v, want %q", tc.endpoint, got, err, tc.want)
+		}
+	}
+
+	for _, endpoint := range []string{"localhost:4318", "grpc://localhost:4317", "http://%zz"} {
+		if _, err := EndpointURL(endpoint); err == nil {
+			t.Errorf("EndpointURL(%q) succeeded", endpoint)
+		}
+	}
+}
+
+func TestSetupExportsToCollector(t *testing.T) {
+	var received []Span
+	collector := NewCollector(func(span Span) { received = append(received, span) })
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	shutdown, err := Setup(context.Background(), Options{Endpoint: server.URL, ServiceName: "middleman", SampleRatio: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := otel.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "parent", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "child", trace.WithAttributes(attribute.String("webhook.destination", "echo"), attribute.Int("attempt", 2)))
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	parent.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := collector.Spans()
+	if len(spans) != 2 || len(received) != 2 {
+		t.Fatalf("spans = %+v", spans)
+	}
+	got, root := spans[0], spans[1]
+	if got.Name != "child" || got.TraceID != parent.SpanContext().TraceID().String() || got.ParentSpanID != root.SpanID {
+		t.Errorf("child = %+v", got)
+	}
+	if got.Service != "middleman" || got.Kind != "internal" || got.Status != "error" || got.Message != "boom" {
+		t.Errorf("child = %+v", got)
+	}
+	if got.Attributes["webhook.destination"] != "echo" || got.Attributes["attempt"] != int64(2) {
+		t.Errorf("attributes = %v", got.Attributes)
+	}
+	if root.Name != "parent" || root.Kind != "server" || root.ParentSpanID != "" || root.End.Before(root.Start) {
+		t.Errorf("parent = %+v", root)
+	}
+
+	collector.Reset()
+	if spans := collector.Spans(); len(spans) != 0 {
+		t.Errorf("spans after reset = %+v", spans)
+	}
+}
+
+func TestSetupWithoutEndpointPropagates(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	header := http.Header{"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	outbound := http.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(outbound))
+	if got := outbound.Get("Traceparent"); got != header.Get("Traceparent") {
+		t.Errorf("traceparent = %q", got)
+	}
+
+	if _, err := Setup(context.Background(), Options{Endpoint: "localhost:4318"}, slog.Default()); err == nil {
+		t.Error("invalid endpoint accepted")
+	}
+}
+
+func exportRequest() *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Spans: []*tracepb.Span{{
+					TraceId: bytes.Repeat([]byte{1}, 16),
+					SpanId:  bytes.Repeat([]byte{2}, 8),
+					Name:    "forward echo",
+					Kind:    tracepb.Span_SPAN_KIND_CLIENT,
+					Status:  &tracepb.Status{Code: tracepb.Status_STATUS_CODE_OK},
+				}},
+			}},
+		}},
+	}
+}
+
+func TestCollectorAcceptsJSONAndGzip(t *testing.T) {
+	collector := NewCollector(nil)
+	data, err := protojson.Marshal(exportRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/v1/traces", bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	collector.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(data)
+	gz.Close()
+
+	r = httptest.NewRequest("POST", "/v1/traces", &compressed)
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	collector.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("gzip: status = %d, body %s", w.Code, w.Body)
+	}
+
+	spans := collector.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %+v", spans)
+	}
+	if got := spans[0]; got.TraceID != "01010101010101010101010101010101" || got.SpanID != "0202020202020202" || got.Kind != "client" || got.Status != "ok" {
+		t.Errorf("span = %+v", got)
+	}
+}
+
+func TestCollectorRejectsOtherRequests(t *testing.T) {
+	collector := NewCollector(nil)
+
+	for _, tc := range []struct {
+		method, target, contentType, body string
+		want                              int
+	}{
+		{"GET", "/v1/traces", "application/json", "", http.StatusNotFound},
+		{"POST", "/v1/metrics", "application/json", "{}", http.StatusNotFound},
+		{"POST", "/v1/traces", "text/plain", "{}", http.StatusUnsupportedMediaType},
+		{"POST", "/v1/traces", "application/json", "{not json", http.StatusBadRequest},
+		{"POST", "/v1/traces", "application/x-protobuf", "\xff\xff", http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest(tc.method, tc.target, bytes.NewBufferString(tc.body))
+		r.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		collector.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s %s %s: status = %d, want %d", tc.method, tc.target, tc.contentType, w.Code, tc.want)
+		}
+	}
+
+	if spans := collector.Spans(); len(spans) != 0 {
+		t.Errorf("spans = %+v", spans)
+	}
+}
@@ -0,0 +1,283 @@
+package verify
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"hash"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature timestamp outside tolerance")
+)
+
+// Request carries the parts of an inbound request a signature is computed over
+type Request struct {
+	Header http.Header
+	Body   []byte
+	URL    string // Public URL of the request as seen by the sender
+	Now    time.Time
+}
+
+// Verify checks the signature of the request according to the provider preset.
+// The secret is passed separately since it may be rendered from a template.
+func Verify(cfg *config.VerifyConfig, secret string, req Request) error {
+	if req.Now.IsZero() {
+		req.Now = time.Now()
+	}
+
+	switch cfg.Provider {
+	case config.VerifyProviderGitHub:
+		return verifyGitHub(secret, req)
+	case config.VerifyProviderStripe:
+		return verifyStripe(secret, cfg.GetTolerance(), req)
+	case config.VerifyProviderSlack:
+		return verifySlack(secret, cfg.GetTolerance(), req)
+	case config.VerifyProviderGitLab:
+		return verifyGitLab(secret, req)
+	case config.VerifyProviderShopify:
+		return verifyShopify(secret, req)
+	case config.VerifyProviderTwilio:
+		return verifyTwilio(secret, req)
+	case config.VerifyProviderHMAC:
+		return verifyHMAC(cfg, secret, req)
+	default:
+		return fmt.Errorf("unknown provider '%s'", cfg.Provider)
+	}
+}
+
+// Headers returns the headers the provider reads the signature or token from
+func Headers(cfg *config.VerifyConfig) []string {
+	switch cfg.Provider {
+	case config.VerifyProviderGitHub:
+		return []string{"X-Hub-Signature-256"}
+	case config.VerifyProviderStripe:
+		return []string{"Stripe-Signature"}
+	case config.VerifyProviderSlack:
+		return []string{"X-Slack-Signature"}
+	case config.VerifyProviderGitLab:
+		return []string{"X-Gitlab-Token"}
+	case config.VerifyProviderShopify:
+		return []string{"X-Shopify-Hmac-Sha256"}
+	case config.VerifyProviderTwilio:
+		return []string{"X-Twilio-Signature"}
+	case config.VerifyProviderHMAC:
+		return []string{cfg.Header}
+	default:
+		return nil
+	}
+}
+
+// verifyGitHub checks X-Hub-Signature-256: sha256=<hex hmac of body>
+func verifyGitHub(secret string, req Request) error {
+	signature, ok := strings.CutPrefix(req.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return ErrMissingSignature
+	}
+
+	return compareHex(signature, computeHMAC(sha256.New, secret, req.Body))
+}
+
+// verifyStripe checks Stripe-Signature: t=<unix>,v1=<hex hmac of "t.body">[,v1=...]
+func verifyStripe(secret string, tolerance time.Duration, req Request) error {
+	header := req.Header.Get("Stripe-Signature")
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+
+	if err := checkTimestamp(timestamp, tolerance, req.Now); err != nil {
+		return err
+	}
+
+	expected := computeHMAC(sha256.New, secret, []byte(timestamp+"."+string(req.Body)))
+	for _, signature := range signatures {
+		if compareHex(signature, expected) == nil {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// verifySlack checks X-Slack-Signature: v0=<hex hmac of "v0:timestamp:body">
+func verifySlack(secret string, tolerance time.Duration, req Request) error {
+	timestamp := req.Header.Get("X-Slack-Request-Timestamp")
+	signature, ok := strings.CutPrefix(req.Header.Get("X-Slack-Signature"), "v0=")
+	if !ok || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	if err := checkTimestamp(timestamp, tolerance, req.Now); err != nil {
+		return err
+	}
+
+	return compareHex(signature, computeHMAC(sha256.New, secret, []byte("v0:"+timestamp+":"+string(req.Body))))
+}
+
+// verifyGitLab checks that X-Gitlab-Token equals the secret
+func verifyGitLab(secret string, req Request) error {
+	token := req.Header.Get("X-Gitlab-Token")
+	if token == "" {
+		return ErrMissingSignature
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// verifyShopify checks X-Shopify-Hmac-Sha256: <base64 hmac of body>
+func verifyShopify(secret string, req Request) error {
+	signature := req.Header.Get("X-Shopify-Hmac-Sha256")
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	return compareBase64(signature, computeHMAC(sha256.New, secret, req.Body))
+}
+
+// verifyTwilio checks X-Twilio-Signature: <base64 hmac-sha1 of the URL followed
+// by the sorted form parameters>. Other requests are signed over the URL only,
+// one with a body must carry a bodySHA256 query parameter matching it.
+func verifyTwilio(secret string, req Request) error {
+	signature := req.Header.Get("X-Twilio-Signature")
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	payload := req.URL
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(req.Body))
+		if err != nil {
+			return fmt.Errorf("failed to parse form body: %w", err)
+		}
+
+		keys := make([]string, 0, len(form))
+		for key := range form {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var sb strings.Builder
+		sb.WriteString(payload)
+		for _, key := range keys {
+			for _, value := range form[key] {
+				sb.WriteString(key)
+				sb.WriteString(value)
+			}
+		}
+		payload = sb.String()
+	} else if len(req.Body) > 0 {
+		// The body isn't part of the signature, only the hash in the URL is
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		bodyHash := u.Query().Get("bodySHA256")
+		if bodyHash == "" {
+			return ErrMissingSignature
+		}
+		sum := sha256.Sum256(req.Body)
+		if compareHex(bodyHash, sum[:]) != nil {
+			return ErrInvalidSignature
+		}
+	}
+
+	return compareBase64(signature, computeHMAC(sha1.New, secret, []byte(payload)))
+}
+
+// verifyHMAC checks a configurable header holding an HMAC of the body
+func verifyHMAC(cfg *config.VerifyConfig, secret string, req Request) error {
+	signature, ok := strings.CutPrefix(req.Header.Get(cfg.Header), cfg.Prefix)
+	if !ok || signature == "" {
+		return ErrMissingSignature
+	}
+
+	var h func() hash.Hash
+	switch cfg.Algorithm {
+	case "sha1":
+		h = sha1.New
+	case "sha512":
+		h = sha512.New
+	default:
+		h = sha256.New
+	}
+
+	expected := computeHMAC(h, secret, req.Body)
+	if cfg.Encoding == "base64" {
+		return compareBase64(signature, expected)
+	}
+
+	return compareHex(signature, expected)
+}
+
+func computeHMAC(h func() hash.Hash, secret string, data []byte) []byte {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func compareHex(signature string, expected []byte) error {
+	decoded, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || !hmac.Equal(decoded, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func compareBase64(signature string, expected []byte) error {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || !hmac.Equal(decoded, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func checkTimestamp(timestamp string, tolerance time.Duration, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package verify
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/framjet/go-webhook-middleman/internal/config"
+	"hash"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const secret = "s3cret"
+
+var now = time.Unix(1700000000, 0)
+
+func mac(h func() hash.Hash, data string) []byte {
+	m := hmac.New(h, []byte(secret))
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func headers(pairs ...string) http.Header {
+	header := http.Header{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		header.Set(pairs[i], pairs[i+1])
+	}
+	return header
+}
+
+func TestVerifyProviders(t *testing.T) {
+	body := `{"a":1}`
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+	bodyHash := sha256.Sum256([]byte(body))
+	hashedURL := "https://example.com/sms?bodySHA256=" + hex.EncodeToString(bodyHash[:])
+
+	tests := []struct {
+		name   string
+		cfg    config.VerifyConfig
+		header http.Header
+		body   string
+		url    string
+		want   error
+	}{
+		{"github", config.VerifyConfig{Provider: "github"},
+			headers("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac(sha256.New, body))), body, "", nil},
+		{"github wrong secret", config.VerifyConfig{Provider: "github"},
+			headers("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac(sha256.New, "other"))), body, "", ErrInvalidSignature},
+		{"github missing", config.VerifyConfig{Provider: "github"}, headers(), body, "", ErrMissingSignature},
+
+		{"stripe", config.VerifyConfig{Provider: "stripe"},
+			headers("Stripe-Signature", "t="+ts+",v1=00,v1="+hex.EncodeToString(mac(sha256.New, ts+"."+body))), body, "", nil},
+		{"stripe expired", config.VerifyConfig{Provider: "stripe"},
+			headers("Stripe-Signature", "t="+stale+",v1="+hex.EncodeToString(mac(sha256.New, stale+"."+body))), body, "", ErrExpiredSignature},
+		{"stripe tolerance", config.VerifyConfig{Provider: "stripe", Tolerance: 2 * time.Hour},
+			headers("Stripe-Signature", "t="+stale+",v1="+hex.EncodeToString(mac(sha256.New, stale+"."+body))), body, "", nil},
+		{"stripe without signature", config.VerifyConfig{Provider: "stripe"},
+			headers("Stripe-Signature", "t="+ts), body, "", ErrMissingSignature},
+
+		{"slack", config.VerifyConfig{Provider: "slack"},
+			headers("X-Slack-Request-Timestamp", ts, "X-Slack-Signature", "v0="+hex.EncodeToString(mac(sha256.New, "v0:"+ts+":"+body))), body, "", nil},
+		{"slack expired", config.VerifyConfig{Provider: "slack"},
+			headers("X-Slack-Request-Timestamp", stale, "X-Slack-Signature", "v0="+hex.EncodeToString(mac(sha256.New, "v0:"+stale+":"+body))), body, "", ErrExpiredSignature},
+
+		{"gitlab", config.VerifyConfig{Provider: "gitlab"}, headers("X-Gitlab-Token", secret), body, "", nil},
+		{"gitlab wrong token", config.VerifyConfig{Provider: "gitlab"}, headers("X-Gitlab-Token", "nope"), body, "", ErrInvalidSignature},
+
+		{"shopify", config.VerifyConfig{Provider: "shopify"},
+			headers("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(mac(sha256.New, body))), body, "", nil},
+
+		{"twilio form", config.VerifyConfig{Provider: "twilio"},
+			headers("Content-Type", "application/x-www-form-urlencoded",
+				"X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac(sha1.New, "https://example.com/sms"+"Bodyhi"+"From+1"))),
+			"From=%2B1&Body=hi", "https://example.com/sms", nil},
+		{"twilio wrong url", config.VerifyConfig{Provider: "twilio"},
+			headers("Content-Type", "application/x-www-form-urlencoded",
+				"X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac(sha1.New, "https://example.com/sms"+"Bodyhi"+"From+1"))),
+			"From=%2B1&Body=hi", "http://example.com/sms", ErrInvalidSignature},
+		{"twilio json body hash", config.VerifyConfig{Provider: "twilio"},
+			headers("Content-Type", "application/json",
+				"X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac(sha1.New, "https://example.com/sms?bodySHA256=00"))),
+			body, "https://example.com/sms?bodySHA256=00", ErrInvalidSignature},
+		{"twilio json", config.VerifyConfig{Provider: "twilio"},
+			headers("Content-Type", "application/json",
+				"X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac(sha1.New, hashedURL))),
+			body, hashedURL, nil},
+		{"twilio json without body hash", config.VerifyConfig{Provider: "twilio"},
+			headers("Content-Type", "application/json",
+				"X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac(sha1.New, "https://example.com/sms"))),
+			body, "https://example.com/sms", ErrMissingSignature},
+		{"twilio without body", config.VerifyConfig{Provider: "twilio"},
+			headers("X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac(sha1.New, "https://example.com/sms?From=%2B1"))),
+			"", "https://example.com/sms?From=%2B1", nil},
+
+		{"hmac sha512 base64", config.VerifyConfig{Provider: "hmac", Header: "X-Sig", Algorithm: "sha512", Encoding: "base64", Prefix: "v1,"},
+			headers("X-Sig", "v1,"+base64.StdEncoding.EncodeToString(mac(sha512.New, body))), body, "", nil},
+		{"hmac wrong prefix", config.VerifyConfig{Provider: "hmac", Header: "X-Sig", Prefix: "sha256="},
+			headers("X-Sig", hex.EncodeToString(mac(sha256.New, body))), body, "", ErrMissingSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(&tt.cfg, secret, Request{Header: tt.header, Body: []byte(tt.body), URL: tt.url, Now: now})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	if got := Headers(&config.VerifyConfig{Provider: "github"}); len(got) != 1 || got[0] != "X-Hub-Signature-256" {
+		t.Errorf("github headers = %v", got)
+	}
+	if got := Headers(&config.VerifyConfig{Provider: "hmac", Header: "X-Sig"}); len(got) != 1 || got[0] != "X-Sig" {
+		t.Errorf("hmac headers = %v", got)
+	}
+}